@@ -0,0 +1,66 @@
+package axe
+
+import (
+	"github.com/256dpi/xo"
+
+	"github.com/256dpi/fire"
+	"github.com/256dpi/fire/coal"
+)
+
+// DenormalizeJob is the job enqueued to propagate the denormalized fields of a
+// changed source document.
+type DenormalizeJob struct {
+	Base `json:"-" axe:"axe/denormalize"`
+
+	// The resource type and id of the source document.
+	Type   string  `json:"type"`
+	Source coal.ID `json:"source"`
+}
+
+// Validate implements the Job interface.
+func (j *DenormalizeJob) Validate() error {
+	// check type and id
+	if j.Type == "" || j.Source == "" {
+		return xo.F("missing type or source")
+	}
+
+	return nil
+}
+
+// DenormalizeTask returns a task that propagates the denormalized fields of
+// the sources referenced by the enqueued jobs.
+func DenormalizeTask(denormalizer *fire.Denormalizer) *Task {
+	return &Task{
+		Job: &DenormalizeJob{},
+		Handler: func(ctx *Context) error {
+			// get job
+			job := ctx.Job.(*DenormalizeJob)
+
+			// propagate fields
+			_, err := denormalizer.PropagateID(ctx, ctx.Queue.options.Store, job.Type, job.Source)
+			if err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+}
+
+// DenormalizeHandler returns a handler that may be passed to the denormalizer
+// propagator to enqueue a job that propagates the changes asynchronously. The
+// job is enqueued as part of the request transaction.
+func DenormalizeHandler() fire.Handler {
+	return func(ctx *fire.Context) error {
+		// enqueue job
+		_, err := Enqueue(ctx, ctx.Store, &DenormalizeJob{
+			Type:   coal.GetMeta(ctx.Model).PluralName,
+			Source: ctx.Model.ID(),
+		}, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+}
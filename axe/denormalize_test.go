@@ -0,0 +1,70 @@
+package axe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire"
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+type sourceModel struct {
+	coal.Base          `json:"-" bson:",inline" coal:"sources"`
+	Name               string `json:"name"`
+	stick.NoValidation `json:"-" bson:"-"`
+}
+
+type dependentModel struct {
+	coal.Base          `json:"-" bson:",inline" coal:"dependents"`
+	SourceName         string  `json:"source-name" bson:"source_name"`
+	Source             coal.ID `json:"-" bson:"source_id" coal:"source:sources"`
+	stick.NoValidation `json:"-" bson:"-"`
+}
+
+func TestDenormalizeTask(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *fire.Tester) {
+		denormalizer := fire.NewDenormalizer(&fire.Denormalization{
+			Model:        &dependentModel{},
+			Field:        "SourceName",
+			Relationship: "Source",
+			Source:       &sourceModel{},
+			SourceField:  "Name",
+		})
+
+		queue := NewQueue(Options{
+			Store:    tester.Store,
+			Reporter: xo.Panic,
+		})
+
+		queue.Add(DenormalizeTask(denormalizer))
+
+		<-queue.Run()
+
+		source := tester.Insert(&sourceModel{Name: "Foo"}).(*sourceModel)
+		dependent := tester.Insert(&dependentModel{SourceName: "Foo", Source: source.ID()})
+
+		tester.Update(source, bson.M{"$set": bson.M{"Name": "Bar"}})
+
+		ctx := &fire.Context{
+			Context: context.Background(),
+			Store:   tester.Store,
+			Model:   source,
+		}
+
+		n, err := Await(tester.Store, time.Second, func() error {
+			return DenormalizeHandler()(ctx)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		assert.Equal(t, "Bar", tester.Fetch(&dependentModel{}, dependent.ID()).(*dependentModel).SourceName)
+
+		queue.Close()
+	})
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/256dpi/fire"
 	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/smoke"
 	"github.com/256dpi/fire/stick"
 )
 
@@ -60,6 +61,9 @@ type Options struct {
 
 	// The callback that is called with job errors.
 	Reporter func(error)
+
+	// The recorder that receives job metrics.
+	Recorder smoke.Recorder
 }
 
 // Queue manages job queueing.
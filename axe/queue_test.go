@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/256dpi/fire"
+	"github.com/256dpi/fire/smoke"
 	"github.com/256dpi/fire/stick"
 )
 
@@ -617,11 +618,14 @@ func TestQueueTimeout(t *testing.T) {
 		done := make(chan struct{})
 		errs := make(chan error, 1)
 
+		collector := smoke.NewCollector()
+
 		queue := NewQueue(Options{
 			Store: tester.Store,
 			Reporter: func(err error) {
 				errs <- err
 			},
+			Recorder: collector,
 		})
 
 		queue.Add(&Task{
@@ -688,6 +692,15 @@ func TestQueueTimeout(t *testing.T) {
 		err = <-errs
 		assert.Equal(t, `task "test" ran longer than the specified lifetime`, err.Error())
 
+		assert.Equal(t, 1.0, collector.Value(JobsMetric, smoke.Labels{
+			"task":   "test",
+			"result": "expired",
+		}))
+		assert.Equal(t, 1.0, collector.Value(JobsMetric, smoke.Labels{
+			"task":   "test",
+			"result": "completed",
+		}))
+
 		queue.Close()
 	})
 }
@@ -817,3 +830,46 @@ func TestQueuePeriodically(t *testing.T) {
 		queue.Close()
 	})
 }
+
+func TestQueueInstrument(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *fire.Tester) {
+		done := make(chan struct{})
+
+		collector := smoke.NewCollector()
+
+		queue := NewQueue(Options{
+			Store:    tester.Store,
+			Reporter: xo.Panic,
+			Recorder: collector,
+		})
+
+		queue.Add(&Task{
+			Job: &testJob{},
+			Handler: func(ctx *Context) error {
+				return nil
+			},
+			Notifier: func(ctx *Context, cancelled bool, reason string) error {
+				close(done)
+				return nil
+			},
+		})
+
+		<-queue.Run()
+
+		enqueued, err := queue.Enqueue(nil, &testJob{}, 0, 0)
+		assert.NoError(t, err)
+		assert.True(t, enqueued)
+
+		<-done
+
+		assert.Equal(t, 1.0, collector.Value(JobsMetric, smoke.Labels{
+			"task":   "test",
+			"result": "completed",
+		}))
+		assert.Equal(t, 1.0, collector.Value(JobDurationMetric, smoke.Labels{
+			"task": "test",
+		}))
+
+		queue.Close()
+	})
+}
@@ -9,9 +9,22 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/smoke"
 	"github.com/256dpi/fire/stick"
 )
 
+// The metrics recorded by a queue.
+const (
+	// JobsMetric counts the executed jobs by task and result. The result is
+	// either "completed", "failed", "cancelled" or "expired" if the handler
+	// ran longer than the lifetime of the task.
+	JobsMetric = "axe_jobs_total"
+
+	// JobDurationMetric observes the duration of job handlers in seconds by
+	// task.
+	JobDurationMetric = "axe_job_duration_seconds"
+)
+
 // Error is used to control retry a cancellation. These errors are expected and
 // are not forwarded to the reporter.
 type Error struct {
@@ -333,9 +346,13 @@ func (t *Task) execute(queue *Queue, name string, id coal.ID) error {
 		return t.Handler(ctx)
 	})
 
+	// record duration
+	t.observe(queue, name, time.Since(start))
+
 	// return immediately if lifetime has been reached. another worker might
 	// already have dequeued the job
 	if time.Since(start) > t.Lifetime {
+		t.count(queue, name, "expired")
 		return xo.F(`task "%s" ran longer than the specified lifetime`, name)
 	}
 
@@ -351,6 +368,9 @@ func (t *Task) execute(queue *Queue, name string, id coal.ID) error {
 				return err
 			}
 
+			// count job
+			t.count(queue, name, "failed")
+
 			return nil
 		}
 
@@ -360,6 +380,9 @@ func (t *Task) execute(queue *Queue, name string, id coal.ID) error {
 			return err
 		}
 
+		// count job
+		t.count(queue, name, "cancelled")
+
 		// call notifier if available
 		if t.Notifier != nil {
 			err = t.Notifier(ctx, true, anError.Reason)
@@ -379,12 +402,18 @@ func (t *Task) execute(queue *Queue, name string, id coal.ID) error {
 			delay := stick.Backoff(t.MinDelay, t.MaxDelay, t.DelayFactor, attempt)
 			_ = Fail(outerContext, queue.options.Store, job, err.Error(), delay)
 
+			// count job
+			t.count(queue, name, "failed")
+
 			return err
 		}
 
 		// cancel job
 		_ = Cancel(outerContext, queue.options.Store, job, err.Error())
 
+		// count job
+		t.count(queue, name, "cancelled")
+
 		// call notifier if available
 		if t.Notifier != nil {
 			_ = t.Notifier(ctx, true, err.Error())
@@ -399,6 +428,9 @@ func (t *Task) execute(queue *Queue, name string, id coal.ID) error {
 		return err
 	}
 
+	// count job
+	t.count(queue, name, "completed")
+
 	// call notifier if available
 	if t.Notifier != nil {
 		err = t.Notifier(ctx, false, "")
@@ -409,3 +441,22 @@ func (t *Task) execute(queue *Queue, name string, id coal.ID) error {
 
 	return nil
 }
+
+func (t *Task) count(queue *Queue, name, result string) {
+	// count job if available
+	if queue.options.Recorder != nil {
+		queue.options.Recorder.Count(JobsMetric, smoke.Labels{
+			"task":   name,
+			"result": result,
+		}, 1)
+	}
+}
+
+func (t *Task) observe(queue *Queue, name string, duration time.Duration) {
+	// record duration if available
+	if queue.options.Recorder != nil {
+		queue.options.Recorder.Observe(JobDurationMetric, smoke.Labels{
+			"task": name,
+		}, duration.Seconds())
+	}
+}
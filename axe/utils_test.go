@@ -12,7 +12,7 @@ import (
 var mongoStore = coal.MustConnect("mongodb://0.0.0.0/test-fire-axe", xo.Panic)
 var lungoStore = coal.MustOpen(nil, "test-fire-axe", xo.Panic)
 
-var modelList = []coal.Model{&Model{}}
+var modelList = []coal.Model{&Model{}, &sourceModel{}, &dependentModel{}}
 
 type testJob struct {
 	Base `json:"-" axe:"test"`
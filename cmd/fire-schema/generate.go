@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/256dpi/xo"
+
+	"github.com/256dpi/fire"
+)
+
+const header = "// Code generated by fire-schema. DO NOT EDIT.\n"
+
+func loadSchema(source string) (*fire.Schema, error) {
+	// prepare reader
+	var reader io.Reader
+	switch {
+	case source == "" || source == "-":
+		reader = os.Stdin
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		res, err := http.Get(source)
+		if err != nil {
+			return nil, xo.W(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, xo.F("unexpected status: %d", res.StatusCode)
+		}
+		reader = res.Body
+	default:
+		file, err := os.Open(source)
+		if err != nil {
+			return nil, xo.W(err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	// decode schema
+	var schema fire.Schema
+	err := json.NewDecoder(reader).Decode(&schema)
+	if err != nil {
+		return nil, xo.W(err)
+	}
+
+	return &schema, nil
+}
+
+func generateTypeScript(schema *fire.Schema) string {
+	// prepare builder
+	var b strings.Builder
+	b.WriteString(header)
+
+	// write interfaces
+	for _, res := range schema.Resources {
+		b.WriteString("\nexport interface " + className(res.Model) + " {\n")
+		b.WriteString("  id: string;\n")
+
+		// write attributes
+		for _, attr := range res.Attributes {
+			b.WriteString("  " + tsMember(attr.Name, attr.Optional) + ": " + tsType(attr) + ";\n")
+		}
+
+		// write properties
+		for _, prop := range res.Properties {
+			b.WriteString("  readonly " + tsMember(prop.Name, prop.Optional) + ": " + tsType(prop) + ";\n")
+		}
+
+		// write relationships
+		for _, rel := range res.Relationships {
+			switch rel.Kind {
+			case "to-one":
+				b.WriteString("  " + tsMember(rel.Name, rel.Optional) + ": string" + nullable(rel.Optional) + ";\n")
+			case "to-many":
+				b.WriteString("  " + tsMember(rel.Name, false) + ": string[];\n")
+			case "has-one":
+				b.WriteString("  readonly " + tsMember(rel.Name, true) + ": string | null;\n")
+			case "has-many":
+				b.WriteString("  readonly " + tsMember(rel.Name, true) + ": string[];\n")
+			}
+		}
+
+		b.WriteString("}\n")
+	}
+
+	// write resource map
+	b.WriteString("\nexport interface Resources {\n")
+	for _, res := range schema.Resources {
+		b.WriteString("  '" + res.Type + "': " + className(res.Model) + ";\n")
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+func generateEmber(schema *fire.Schema) map[string]string {
+	// index model names
+	names := map[string]string{}
+	for _, res := range schema.Resources {
+		names[res.Type] = modelName(res.Model)
+	}
+
+	// generate files
+	files := map[string]string{}
+	for _, res := range schema.Resources {
+		// prepare body
+		var body strings.Builder
+		imports := map[string]bool{}
+
+		// write attributes and properties
+		for _, attr := range append(append([]fire.SchemaAttribute{}, res.Attributes...), res.Properties...) {
+			imports["attr"] = true
+			body.WriteString("  @attr(" + emberTransform(attr) + ") " + camelize(attr.Name) + ";\n")
+		}
+
+		// write relationships
+		for _, rel := range res.Relationships {
+			// get related model name
+			related := names[rel.Type]
+			if related == "" {
+				related = rel.Type
+			}
+
+			// get inverse
+			inverse := "null"
+			if rel.Inverse != "" {
+				inverse = "'" + camelize(rel.Inverse) + "'"
+			}
+
+			// get function
+			fn := "belongsTo"
+			if rel.Kind == "to-many" || rel.Kind == "has-many" {
+				fn = "hasMany"
+			}
+			imports[fn] = true
+
+			body.WriteString("  @" + fn + "('" + related + "', { async: true, inverse: " + inverse + " }) " + camelize(rel.Name) + ";\n")
+		}
+
+		// sort imports
+		list := make([]string, 0, len(imports))
+		for name := range imports {
+			list = append(list, name)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return emberImportOrder(list[i]) < emberImportOrder(list[j])
+		})
+
+		// write file
+		var b strings.Builder
+		b.WriteString(header + "\n")
+		if len(list) > 0 {
+			b.WriteString("import Model, { " + strings.Join(list, ", ") + " } from '@ember-data/model';\n\n")
+		} else {
+			b.WriteString("import Model from '@ember-data/model';\n\n")
+		}
+		b.WriteString("export default class " + emberClassName(res.Model) + " extends Model {\n")
+		b.WriteString(body.String())
+		b.WriteString("}\n")
+
+		// add file
+		files[modelName(res.Model)+".js"] = b.String()
+	}
+
+	return files
+}
+
+func tsType(attr fire.SchemaAttribute) string {
+	// get type
+	typ := strings.TrimPrefix(attr.Type, "*")
+
+	// handle times, which are encoded as RFC3339 strings
+	if typ == "time.Time" {
+		return "string" + nullable(attr.Optional)
+	}
+
+	// handle byte slices, which are encoded as base64 strings
+	if attr.Kind == "slice" && (attr.Elem == "uint8" || typ == "[]byte" || typ == "[]uint8") {
+		return "string" + nullable(attr.Optional)
+	}
+
+	// handle kinds
+	switch attr.Kind {
+	case "slice", "array":
+		// get element type
+		elem := strings.TrimLeft(typ, "[]0123456789*")
+		if elem == "time.Time" {
+			return "string[]" + nullable(attr.Optional)
+		}
+
+		// prefer element kind
+		if attr.Elem != "" {
+			elem = attr.Elem
+		}
+
+		return tsElement(elem) + "[]" + nullable(attr.Optional)
+	default:
+		return tsElement(attr.Kind) + nullable(attr.Optional)
+	}
+}
+
+func tsElement(kind string) string {
+	switch kind {
+	case "string":
+		return "string"
+	case "bool":
+		return "boolean"
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64":
+		return "number"
+	case "map", "struct":
+		return "Record<string, unknown>"
+	default:
+		return "unknown"
+	}
+}
+
+func emberTransform(attr fire.SchemaAttribute) string {
+	// handle times
+	if strings.TrimPrefix(attr.Type, "*") == "time.Time" {
+		return "'date'"
+	}
+
+	// handle kinds
+	switch tsElement(attr.Kind) {
+	case "string":
+		return "'string'"
+	case "boolean":
+		return "'boolean'"
+	case "number":
+		return "'number'"
+	default:
+		return ""
+	}
+}
+
+func emberImportOrder(name string) int {
+	switch name {
+	case "attr":
+		return 0
+	case "belongsTo":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func tsMember(name string, optional bool) string {
+	// camelize name
+	name = camelize(name)
+
+	// mark optional
+	if optional {
+		name += "?"
+	}
+
+	return name
+}
+
+func nullable(optional bool) string {
+	if optional {
+		return " | null"
+	}
+
+	return ""
+}
+
+func className(model string) string {
+	// capitalize first letter
+	runes := []rune(model)
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+
+	return string(runes)
+}
+
+func emberClassName(model string) string {
+	// get class name
+	name := className(model)
+
+	// add suffix
+	if !strings.HasSuffix(name, "Model") {
+		name += "Model"
+	}
+
+	return name
+}
+
+func modelName(model string) string {
+	// dasherize model name
+	var b strings.Builder
+	runes := []rune(model)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteRune('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func camelize(name string) string {
+	// split name
+	segments := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_'
+	})
+
+	// capitalize segments
+	for i := 1; i < len(segments); i++ {
+		segments[i] = className(segments[i])
+	}
+
+	return strings.Join(segments, "")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/256dpi/fire"
+)
+
+var testSchema = &fire.Schema{
+	Resources: []fire.SchemaResource{
+		{
+			Type:  "car-wheels",
+			Model: "CarWheel",
+			Attributes: []fire.SchemaAttribute{
+				{Name: "tire-size", Field: "TireSize", Kind: "int", Type: "int"},
+				{Name: "label", Field: "Label", Kind: "string", Type: "*string", Optional: true},
+				{Name: "tags", Field: "Tags", Kind: "slice", Type: "[]string", Elem: "string"},
+				{Name: "states", Field: "States", Kind: "slice", Type: "[]main.State", Elem: "string"},
+				{Name: "checks", Field: "Checks", Kind: "slice", Type: "[]time.Time", Elem: "struct"},
+				{Name: "payload", Field: "Payload", Kind: "slice", Type: "[]uint8", Elem: "uint8"},
+				{Name: "checked-at", Field: "CheckedAt", Kind: "struct", Type: "time.Time"},
+				{Name: "data", Field: "Data", Kind: "map", Type: "stick.Map"},
+			},
+			Relationships: []fire.SchemaRelationship{
+				{Name: "car", Field: "Car", Type: "cars", Kind: "to-one", Inverse: "wheels"},
+				{Name: "spare", Field: "Spare", Type: "car-wheels", Kind: "to-one", Optional: true},
+			},
+			Properties: []fire.SchemaAttribute{
+				{Name: "worn", Field: "Worn", Kind: "bool", Type: "bool"},
+			},
+		},
+		{
+			Type:  "cars",
+			Model: "Car",
+			Relationships: []fire.SchemaRelationship{
+				{Name: "wheels", Field: "Wheels", Type: "car-wheels", Kind: "has-many", Inverse: "car"},
+			},
+		},
+	},
+}
+
+func TestLoadSchema(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "schema.json")
+	err := os.WriteFile(file, []byte(`{"resources":[{"type":"cars","model":"Car"}]}`), 0644)
+	assert.NoError(t, err)
+
+	schema, err := loadSchema(file)
+	assert.NoError(t, err)
+	assert.Equal(t, &fire.Schema{
+		Resources: []fire.SchemaResource{
+			{Type: "cars", Model: "Car"},
+		},
+	}, schema)
+
+	schema, err = loadSchema(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+	assert.Nil(t, schema)
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+	assert.Equal(t, `// Code generated by fire-schema. DO NOT EDIT.
+
+export interface CarWheel {
+  id: string;
+  tireSize: number;
+  label?: string | null;
+  tags: string[];
+  states: string[];
+  checks: string[];
+  payload: string;
+  checkedAt: string;
+  data: Record<string, unknown>;
+  readonly worn: boolean;
+  car: string;
+  spare?: string | null;
+}
+
+export interface Car {
+  id: string;
+  readonly wheels?: string[];
+}
+
+export interface Resources {
+  'car-wheels': CarWheel;
+  'cars': Car;
+}
+`, generateTypeScript(testSchema))
+}
+
+func TestGenerateEmber(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"car-wheel.js": `// Code generated by fire-schema. DO NOT EDIT.
+
+import Model, { attr, belongsTo } from '@ember-data/model';
+
+export default class CarWheelModel extends Model {
+  @attr('number') tireSize;
+  @attr('string') label;
+  @attr() tags;
+  @attr() states;
+  @attr() checks;
+  @attr() payload;
+  @attr('date') checkedAt;
+  @attr() data;
+  @attr('boolean') worn;
+  @belongsTo('car', { async: true, inverse: 'wheels' }) car;
+  @belongsTo('car-wheel', { async: true, inverse: null }) spare;
+}
+`,
+		"car.js": `// Code generated by fire-schema. DO NOT EDIT.
+
+import Model, { hasMany } from '@ember-data/model';
+
+export default class CarModel extends Model {
+  @hasMany('car-wheel', { async: true, inverse: 'car' }) wheels;
+}
+`,
+	}, generateEmber(testSchema))
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, "car-wheel", modelName("CarWheel"))
+	assert.Equal(t, "api-key", modelName("APIKey"))
+	assert.Equal(t, "post-model", modelName("postModel"))
+	assert.Equal(t, "tireSize", camelize("tire-size"))
+	assert.Equal(t, "CarWheelModel", emberClassName("CarWheel"))
+	assert.Equal(t, "PostModel", emberClassName("postModel"))
+}
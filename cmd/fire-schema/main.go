@@ -0,0 +1,66 @@
+// Command fire-schema generates TypeScript interfaces and Ember Data models
+// from the schema emitted by a fire group schema action.
+//
+//	fire-schema -source http://localhost:8000/api/schema -ts app/schema.ts -ember app/models
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var source = flag.String("source", "-", "The schema file, URL or '-' for stdin.")
+var tsFile = flag.String("ts", "", "The file to write the TypeScript interfaces to.")
+var emberDir = flag.String("ember", "", "The directory to write the Ember Data models to.")
+
+func main() {
+	// parse flags
+	flag.Parse()
+
+	// run generator
+	err := run()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	// load schema
+	schema, err := loadSchema(*source)
+	if err != nil {
+		return err
+	}
+
+	// print TypeScript interfaces if no output has been requested
+	if *tsFile == "" && *emberDir == "" {
+		_, err = fmt.Print(generateTypeScript(schema))
+		return err
+	}
+
+	// write TypeScript interfaces
+	if *tsFile != "" {
+		err = os.WriteFile(*tsFile, []byte(generateTypeScript(schema)), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	// write Ember Data models
+	if *emberDir != "" {
+		err = os.MkdirAll(*emberDir, 0755)
+		if err != nil {
+			return err
+		}
+		for name, content := range generateEmber(schema) {
+			err = os.WriteFile(filepath.Join(*emberDir, name), []byte(content), 0644)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
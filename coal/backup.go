@@ -0,0 +1,621 @@
+package coal
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BackupFormat defines the encoding of the documents in a backup archive.
+type BackupFormat string
+
+// The available backup formats.
+const (
+	// BackupJSON encodes documents as canonical extended JSON, one document
+	// per line.
+	BackupJSON BackupFormat = "json"
+
+	// BackupBSON encodes documents as a sequence of BSON documents.
+	BackupBSON BackupFormat = "bson"
+)
+
+// BackupVersion is the current version of the backup archive layout.
+const BackupVersion = 1
+
+const backupManifest = "manifest.json"
+
+// The maximum line size of JSON encoded documents. Extended JSON may be
+// considerably larger than the BSON document limit of 16MB, e.g. due to the
+// base64 encoding of binary data.
+const backupMaxLine = 64 * 1024 * 1024
+
+// BackupManifest describes the contents of a backup archive.
+type BackupManifest struct {
+	// The archive layout version.
+	Version int `json:"version"`
+
+	// The used document format.
+	Format BackupFormat `json:"format"`
+
+	// The time the backup has been created.
+	Created time.Time `json:"created"`
+
+	// The backed up collections.
+	Collections []BackupCollection `json:"collections"`
+}
+
+// BackupCollection describes a backed up collection.
+type BackupCollection struct {
+	// The model name.
+	Model string `json:"model"`
+
+	// The collection name.
+	Collection string `json:"collection"`
+
+	// The file in the archive.
+	File string `json:"file"`
+
+	// The number of documents.
+	Documents int64 `json:"documents"`
+}
+
+// BackupOptions defines options for backups.
+type BackupOptions struct {
+	// The document format.
+	//
+	// Default: BackupJSON.
+	Format BackupFormat
+
+	// The function that returns the filter for a model. Filters use the field
+	// names of the model. If nil is returned, all documents are backed up.
+	Filter func(model Model) bson.M
+
+	// The relationships that are ignored when the models are verified.
+	Ignored []string
+}
+
+// RestoreOptions defines options for restores.
+type RestoreOptions struct {
+	// The function that returns the filter for a model. Filters use the field
+	// names of the model. If nil is returned, all documents are restored.
+	Filter func(model Model) bson.M
+
+	// Whether existing documents should be replaced. By default, restoring an
+	// existing document fails and aborts the restore. Documents that have been
+	// inserted before are not removed in that case.
+	Replace bool
+
+	// Whether dangling references of optional to-one and to-many relationships
+	// should be removed after the documents have been restored.
+	Repair bool
+
+	// The relationships that are ignored when the models are verified.
+	Ignored []string
+}
+
+// TenantFilter returns a filter function that matches the documents of models
+// that have the specified field with the provided id. Models without the field
+// are not filtered.
+func TenantFilter(field string, id ID) func(Model) bson.M {
+	return func(model Model) bson.M {
+		// check field
+		if GetMeta(model).Fields[field] == nil {
+			return nil
+		}
+
+		return bson.M{field: id}
+	}
+}
+
+// Backup will write the documents of the specified models as a gzip compressed
+// tar archive to the provided writer. The archive starts with a manifest that
+// is followed by one file per collection. The models are verified before the
+// backup to ensure all related models are included. To include file metadata
+// of the blaze package, the blaze.File model may be added to the list of
+// models.
+//
+// The documents of the collections are streamed to temporary files first, as
+// the manifest and the archive require the counts and file sizes upfront.
+// Memory usage therefore does not grow with the size of the collections.
+func Backup(ctx context.Context, store *Store, w io.Writer, models []Model, opts BackupOptions) (*BackupManifest, error) {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Backup")
+	defer span.End()
+
+	// set default format
+	if opts.Format == "" {
+		opts.Format = BackupJSON
+	}
+
+	// check format
+	if opts.Format != BackupJSON && opts.Format != BackupBSON {
+		return nil, xo.F("unsupported format %q", opts.Format)
+	}
+
+	// verify models
+	err := Verify(models, opts.Ignored...)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare manifest
+	manifest := &BackupManifest{
+		Version: BackupVersion,
+		Format:  opts.Format,
+		Created: time.Now().UTC(),
+	}
+
+	// spool collections
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}()
+	for _, model := range models {
+		// get meta
+		meta := GetMeta(model)
+
+		// prepare filter
+		var filter bson.M
+		if opts.Filter != nil {
+			filter = opts.Filter(model)
+		}
+
+		// spool collection
+		file, count, err := spoolCollection(ctx, store, model, filter, opts.Format)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+
+		// add collection
+		manifest.Collections = append(manifest.Collections, BackupCollection{
+			Model:      meta.Name,
+			Collection: meta.Collection,
+			File:       meta.Collection + "." + string(opts.Format),
+			Documents:  count,
+		})
+	}
+
+	// prepare archive
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	// encode manifest
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, xo.W(err)
+	}
+
+	// write manifest first to allow validation before restoring
+	err = writeArchiveFile(tw, backupManifest, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// write collections
+	for i, coll := range manifest.Collections {
+		err = copyArchiveFile(tw, coll.File, files[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// close archive
+	err = tw.Close()
+	if err != nil {
+		return nil, xo.W(err)
+	}
+	err = gw.Close()
+	if err != nil {
+		return nil, xo.W(err)
+	}
+
+	return manifest, nil
+}
+
+// Restore will read a backup archive from the provided reader and insert the
+// documents of the specified models into the store. Document ids are preserved.
+// The models are verified and the manifest is checked before any document is
+// inserted. The archive must not contain collections of other models.
+//
+// After the documents have been inserted, the relationships of the models are
+// checked using CheckIntegrity. An error is returned if dangling or duplicate
+// references remain, e.g. due to a filter that omitted referenced documents.
+// The inserted documents are not removed in that case. Dangling references of
+// optional relationships may be removed using the Repair option.
+func Restore(ctx context.Context, store *Store, r io.Reader, models []Model, opts RestoreOptions) (*BackupManifest, error) {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Restore")
+	defer span.End()
+
+	// verify models
+	err := Verify(models, opts.Ignored...)
+	if err != nil {
+		return nil, err
+	}
+
+	// index models
+	index := map[string]Model{}
+	for _, model := range models {
+		index[GetMeta(model).Collection] = model
+	}
+
+	// open archive
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, xo.W(err)
+	}
+	tr := tar.NewReader(gr)
+
+	// read manifest
+	header, err := tr.Next()
+	if err != nil && err != io.EOF {
+		return nil, xo.W(err)
+	} else if err == io.EOF || header.Name != backupManifest {
+		return nil, xo.F("missing manifest")
+	}
+	manifest := &BackupManifest{}
+	err = json.NewDecoder(tr).Decode(manifest)
+	if err != nil {
+		return nil, xo.W(err)
+	}
+
+	// check version
+	if manifest.Version != BackupVersion {
+		return nil, xo.F("unsupported version %d", manifest.Version)
+	}
+
+	// check collections
+	files := map[string]string{}
+	for _, coll := range manifest.Collections {
+		if index[coll.Collection] == nil {
+			return nil, xo.F("unknown collection %s", coll.Collection)
+		}
+		files[coll.File] = coll.Collection
+	}
+
+	// read files
+	counts := map[string]int64{}
+	for {
+		// get next file
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, xo.W(err)
+		}
+
+		// get collection
+		coll, ok := files[header.Name]
+		if !ok {
+			return nil, xo.F("unexpected file %s", header.Name)
+		}
+
+		// get format
+		format := BackupFormat(strings.TrimPrefix(path.Ext(header.Name), "."))
+
+		// get model
+		model := index[coll]
+
+		// prepare filter
+		var filter bson.M
+		if opts.Filter != nil {
+			filter = opts.Filter(model)
+		}
+
+		// restore collection
+		count, err := restoreCollection(ctx, store, model, filter, format, opts.Replace, tr)
+		if err != nil {
+			return nil, err
+		}
+
+		// set count
+		counts[coll] = count
+	}
+
+	// check collections
+	for _, coll := range manifest.Collections {
+		if _, ok := counts[coll.Collection]; !ok {
+			return nil, xo.F("missing collection %s", coll.Collection)
+		}
+	}
+
+	// check relationships
+	report, err := CheckIntegrity(ctx, store, models, opts.Repair)
+	if err != nil {
+		return nil, err
+	}
+	for _, issue := range report.Issues {
+		if issue.Kind != InvalidDocument && !issue.Repaired {
+			return nil, xo.F("integrity issue: %s", issue)
+		}
+	}
+
+	return manifest, nil
+}
+
+func spoolCollection(ctx context.Context, store *Store, model Model, filter bson.M, format BackupFormat) (*os.File, int64, error) {
+	// create temporary file as the archive requires the size upfront
+	file, err := os.CreateTemp("", "coal-backup-*")
+	if err != nil {
+		return nil, 0, xo.W(err)
+	}
+
+	// backup collection
+	bw := bufio.NewWriter(file)
+	count, err := backupCollection(ctx, store, model, filter, format, bw)
+	if err == nil {
+		err = xo.W(bw.Flush())
+	}
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return nil, 0, err
+	}
+
+	return file, count, nil
+}
+
+func backupCollection(ctx context.Context, store *Store, model Model, filter bson.M, format BackupFormat, w io.Writer) (int64, error) {
+	// translate filter
+	query := bson.D{}
+	if filter != nil {
+		var err error
+		query, err = NewTranslator(model).Document(filter)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// find documents
+	iter, err := store.C(model).Find(ctx, query, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+
+	// ensure close
+	defer iter.Close()
+
+	// write documents
+	var count int64
+	for iter.Next() {
+		// decode document
+		var doc bson.Raw
+		err = iter.Decode(&doc)
+		if err != nil {
+			return 0, err
+		}
+
+		// encode document
+		if format == BackupJSON {
+			data, err := bson.MarshalExtJSON(doc, true, false)
+			if err != nil {
+				return 0, xo.W(err)
+			}
+			_, err = w.Write(append(data, '\n'))
+			if err != nil {
+				return 0, xo.W(err)
+			}
+		} else {
+			_, err = w.Write(doc)
+			if err != nil {
+				return 0, xo.W(err)
+			}
+		}
+
+		// increment
+		count++
+	}
+
+	// check error
+	err = iter.Error()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func restoreCollection(ctx context.Context, store *Store, model Model, filter bson.M, format BackupFormat, replace bool, r io.Reader) (int64, error) {
+	// prepare query
+	var query bsonkit.Doc
+	if filter != nil {
+		// translate filter
+		doc, err := NewTranslator(model).Document(filter)
+		if err != nil {
+			return 0, err
+		}
+
+		// transform filter
+		query, err = bsonkit.Transform(doc)
+		if err != nil {
+			return 0, xo.W(err)
+		}
+	}
+
+	// prepare reader
+	var next func() (bson.Raw, error)
+	switch format {
+	case BackupJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), backupMaxLine)
+		next = func() (bson.Raw, error) {
+			// scan line
+			if !scanner.Scan() {
+				if scanner.Err() != nil {
+					return nil, xo.W(scanner.Err())
+				}
+				return nil, io.EOF
+			}
+
+			// decode document
+			var doc bson.D
+			err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &doc)
+			if err != nil {
+				return nil, xo.W(err)
+			}
+
+			// encode document
+			raw, err := bson.Marshal(doc)
+			if err != nil {
+				return nil, xo.W(err)
+			}
+
+			return raw, nil
+		}
+	case BackupBSON:
+		next = func() (bson.Raw, error) {
+			return bson.NewFromIOReader(r)
+		}
+	default:
+		return 0, xo.F("unsupported format %q", format)
+	}
+
+	// prepare batch
+	var batch []interface{}
+	flush := func() error {
+		// check batch
+		if len(batch) == 0 {
+			return nil
+		}
+
+		// insert documents
+		_, err := store.C(model).InsertMany(ctx, batch)
+		if err != nil {
+			return err
+		}
+
+		// reset batch
+		batch = nil
+
+		return nil
+	}
+
+	// read documents
+	var count int64
+	for {
+		// get document
+		doc, err := next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, xo.W(err)
+		}
+
+		// check filter
+		if query != nil {
+			value, err := bsonkit.Transform(doc)
+			if err != nil {
+				return 0, xo.W(err)
+			}
+			ok, err := mongokit.Match(value, query)
+			if err != nil {
+				return 0, xo.W(err)
+			} else if !ok {
+				continue
+			}
+		}
+
+		// increment
+		count++
+
+		// replace document if requested
+		if replace {
+			_, err = store.C(model).ReplaceOne(ctx, bson.M{
+				"_id": doc.Lookup("_id"),
+			}, doc, options.Replace().SetUpsert(true))
+			if err != nil {
+				return 0, err
+			}
+
+			continue
+		}
+
+		// add document
+		batch = append(batch, doc)
+		if len(batch) >= 100 {
+			err = flush()
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// flush batch
+	err := flush()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func copyArchiveFile(tw *tar.Writer, name string, file *os.File) error {
+	// get size
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return xo.W(err)
+	}
+
+	// rewind file
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return xo.W(err)
+	}
+
+	// write header
+	err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return xo.W(err)
+	}
+
+	// copy data
+	_, err = io.Copy(tw, file)
+	if err != nil {
+		return xo.W(err)
+	}
+
+	return nil
+}
+
+func writeArchiveFile(tw *tar.Writer, name string, data []byte) error {
+	// write header
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return xo.W(err)
+	}
+
+	// write data
+	_, err = tw.Write(data)
+	if err != nil {
+		return xo.W(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package coal
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBackup(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		models := []Model{&postModel{}, &commentModel{}, &selectionModel{}, &noteModel{}}
+		ignored := []string{"coal.commentModel#children"}
+
+		fixture, err := ParseFixture([]byte(testFixture))
+		assert.NoError(t, err)
+
+		for _, format := range []BackupFormat{BackupJSON, BackupBSON} {
+			tester.Clean()
+			refs := tester.Seed(fixture)
+			snapshot := tester.Snapshot()
+
+			var buf bytes.Buffer
+			manifest, err := Backup(nil, tester.Store, &buf, models, BackupOptions{
+				Format:  format,
+				Ignored: ignored,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, BackupVersion, manifest.Version)
+			assert.Equal(t, format, manifest.Format)
+			assert.Equal(t, []BackupCollection{
+				{Model: "coal.postModel", Collection: "posts", File: "posts." + string(format), Documents: 2},
+				{Model: "coal.commentModel", Collection: "comments", File: "comments." + string(format), Documents: 2},
+				{Model: "coal.selectionModel", Collection: "selections", File: "selections." + string(format), Documents: 1},
+				{Model: "coal.noteModel", Collection: "notes", File: "notes." + string(format), Documents: 1},
+			}, manifest.Collections)
+
+			tester.Clean()
+
+			restored, err := Restore(nil, tester.Store, bytes.NewReader(buf.Bytes()), models, RestoreOptions{
+				Ignored: ignored,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, manifest.Collections, restored.Collections)
+
+			fixture1, err := snapshot.Fixture()
+			assert.NoError(t, err)
+			fixture2, err := tester.Snapshot().Fixture()
+			assert.NoError(t, err)
+			assert.Equal(t, fixture1, fixture2)
+
+			comment := tester.FindLast(&commentModel{}, bson.M{"_id": bson.M{"$ne": refs["comment1"]}}).(*commentModel)
+			assert.Equal(t, refs["post1"], comment.Post)
+			assert.Equal(t, refs["comment1"], *comment.Parent)
+
+			_, err = Restore(nil, tester.Store, bytes.NewReader(buf.Bytes()), models, RestoreOptions{
+				Ignored: ignored,
+			})
+			assert.Error(t, err)
+
+			_, err = Restore(nil, tester.Store, bytes.NewReader(buf.Bytes()), models, RestoreOptions{
+				Replace: true,
+				Ignored: ignored,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 2, tester.Count(&postModel{}))
+		}
+	})
+}
+
+func TestBackupLargeDocument(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		models := []Model{&postModel{}, &commentModel{}, &selectionModel{}, &noteModel{}}
+		ignored := []string{"coal.commentModel#children"}
+
+		// control characters are escaped as \u0001 in JSON
+		title := strings.Repeat("\x01", 3*1024*1024)
+		post := tester.Insert(&postModel{Title: title})
+
+		var buf bytes.Buffer
+		_, err := Backup(nil, tester.Store, &buf, models, BackupOptions{
+			Ignored: ignored,
+		})
+		assert.NoError(t, err)
+
+		tester.Clean()
+
+		_, err = Restore(nil, tester.Store, &buf, models, RestoreOptions{
+			Ignored: ignored,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, title, tester.Fetch(&postModel{}, post.ID()).(*postModel).Title)
+	})
+}
+
+func TestBackupFilter(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		models := []Model{&postModel{}, &commentModel{}, &selectionModel{}, &noteModel{}}
+		ignored := []string{"coal.commentModel#children"}
+
+		fixture, err := ParseFixture([]byte(testFixture))
+		assert.NoError(t, err)
+
+		refs := tester.Seed(fixture)
+
+		filter := func(model Model) bson.M {
+			if _, ok := model.(*postModel); ok {
+				return bson.M{"_id": refs["post1"]}
+			}
+			return TenantFilter("Post", refs["post1"])(model)
+		}
+
+		var buf bytes.Buffer
+		manifest, err := Backup(nil, tester.Store, &buf, models, BackupOptions{
+			Filter:  filter,
+			Ignored: ignored,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), manifest.Collections[0].Documents)
+		assert.Equal(t, int64(2), manifest.Collections[1].Documents)
+		assert.Equal(t, int64(1), manifest.Collections[2].Documents)
+		assert.Equal(t, int64(0), manifest.Collections[3].Documents)
+
+		buf.Reset()
+		_, err = Backup(nil, tester.Store, &buf, models, BackupOptions{
+			Ignored: ignored,
+		})
+		assert.NoError(t, err)
+
+		tester.Clean()
+
+		_, err = Restore(nil, tester.Store, bytes.NewReader(buf.Bytes()), models, RestoreOptions{
+			Filter:  filter,
+			Ignored: ignored,
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "integrity issue: dangling-reference coal.selectionModel/")
+
+		tester.Clean()
+
+		_, err = Restore(nil, tester.Store, bytes.NewReader(buf.Bytes()), models, RestoreOptions{
+			Filter:  filter,
+			Repair:  true,
+			Ignored: ignored,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, tester.Count(&postModel{}))
+		assert.Equal(t, 2, tester.Count(&commentModel{}))
+		assert.Equal(t, 1, tester.Count(&selectionModel{}))
+		assert.Equal(t, 0, tester.Count(&noteModel{}))
+
+		selection := tester.FindLast(&selectionModel{}).(*selectionModel)
+		assert.Equal(t, []ID{refs["post1"]}, selection.Posts)
+	})
+}
+
+func TestBackupErrors(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		models := []Model{&postModel{}, &commentModel{}, &selectionModel{}, &noteModel{}}
+		ignored := []string{"coal.commentModel#children"}
+
+		var buf bytes.Buffer
+		_, err := Backup(nil, tester.Store, &buf, models, BackupOptions{
+			Format: "xml",
+		})
+		assert.Error(t, err)
+		assert.Equal(t, `unsupported format "xml"`, err.Error())
+
+		_, err = Backup(nil, tester.Store, &buf, []Model{&commentModel{}}, BackupOptions{})
+		assert.Error(t, err)
+
+		_, err = Backup(nil, tester.Store, &buf, models, BackupOptions{
+			Ignored: ignored,
+		})
+		assert.NoError(t, err)
+
+		_, err = Restore(nil, tester.Store, bytes.NewReader(buf.Bytes()), []Model{&postModel{}, &commentModel{}, &noteModel{}}, RestoreOptions{
+			Ignored: append([]string{"coal.postModel#selections"}, ignored...),
+		})
+		assert.Error(t, err)
+		assert.Equal(t, "unknown collection selections", err.Error())
+		assert.Equal(t, 0, tester.Count(&postModel{}))
+
+		var archive bytes.Buffer
+		gw := gzip.NewWriter(&archive)
+		tw := tar.NewWriter(gw)
+		assert.NoError(t, writeArchiveFile(tw, "posts.json", []byte(`{"_id":"`+New()+`","title":"foo"}`+"\n")))
+		assert.NoError(t, tw.Close())
+		assert.NoError(t, gw.Close())
+
+		_, err = Restore(nil, tester.Store, &archive, models, RestoreOptions{
+			Ignored: ignored,
+		})
+		assert.Error(t, err)
+		assert.Equal(t, "missing manifest", err.Error())
+		assert.Equal(t, 0, tester.Count(&postModel{}))
+	})
+}
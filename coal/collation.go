@@ -0,0 +1,55 @@
+package coal
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type collationKey struct{}
+
+// WithCollation will return a context that carries the specified collation.
+// Manager operations that match, sort or update documents using a filter will
+// use the collation. Queries must use the same collation as an index to be
+// able to use it.
+//
+// Note: Collations are not supported by lungo and therefore ignored.
+func WithCollation(ctx context.Context, collation *options.Collation) context.Context {
+	// ensure context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, collationKey{}, collation)
+}
+
+// GetCollation will return the collation carried by the context, if any.
+func GetCollation(ctx context.Context) *options.Collation {
+	// check context
+	if ctx == nil {
+		return nil
+	}
+
+	// get collation
+	collation, _ := ctx.Value(collationKey{}).(*options.Collation)
+
+	return collation
+}
+
+// CaseInsensitive returns a collation for the specified locale that compares
+// strings case-insensitively.
+func CaseInsensitive(locale string) *options.Collation {
+	return &options.Collation{
+		Locale:   locale,
+		Strength: 2,
+	}
+}
+
+func (m *Manager) collation(ctx context.Context) *options.Collation {
+	// collations are not supported by lungo
+	if m.store.Lungo() {
+		return nil
+	}
+
+	return GetCollation(ctx)
+}
@@ -0,0 +1,51 @@
+package coal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestCollation(t *testing.T) {
+	assert.Nil(t, GetCollation(nil))
+
+	ctx := WithCollation(nil, CaseInsensitive("en"))
+	assert.Equal(t, &options.Collation{
+		Locale:   "en",
+		Strength: 2,
+	}, GetCollation(ctx))
+}
+
+func TestManagerCollation(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Insert(&postModel{Title: "b"})
+		tester.Insert(&postModel{Title: "B"})
+		tester.Insert(&postModel{Title: "a"})
+
+		ctx := WithCollation(nil, CaseInsensitive("en"))
+		m := tester.Store.M(&postModel{})
+
+		var posts []*postModel
+		err := m.FindAll(ctx, &posts, nil, []string{"Title"}, 0, 0, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Len(t, posts, 3)
+
+		count, err := m.Count(ctx, bson.M{"Title": "b"}, 0, 0, false, NoTransaction)
+		assert.NoError(t, err)
+
+		n, err := m.UpdateAll(ctx, bson.M{"Title": "b"}, bson.M{"$set": bson.M{"Published": true}}, false)
+		assert.NoError(t, err)
+
+		if tester.Store.Lungo() {
+			assert.Equal(t, []string{"B", "a", "b"}, []string{posts[0].Title, posts[1].Title, posts[2].Title})
+			assert.Equal(t, int64(1), count)
+			assert.Equal(t, int64(1), n)
+		} else {
+			assert.Equal(t, "a", posts[0].Title)
+			assert.Equal(t, int64(2), count)
+			assert.Equal(t, int64(2), n)
+		}
+	})
+}
@@ -61,6 +61,9 @@ func (c *Collection) BulkWrite(ctx context.Context, models []mongo.WriteModel, o
 	span.Tag("collection", c.coll.Name())
 	defer span.End()
 
+	// mark write
+	markWritten(ctx)
+
 	// bulk write
 	res, err := c.coll.BulkWrite(ctx, models, opts...)
 	if err != nil {
@@ -103,6 +106,9 @@ func (c *Collection) DeleteMany(ctx context.Context, filter interface{}, opts ..
 	span.Tag("collection", c.coll.Name())
 	defer span.End()
 
+	// mark write
+	markWritten(ctx)
+
 	// delete many
 	res, err := c.coll.DeleteMany(ctx, filter, opts...)
 	if err != nil {
@@ -122,6 +128,9 @@ func (c *Collection) DeleteOne(ctx context.Context, filter interface{}, opts ...
 	span.Tag("collection", c.coll.Name())
 	defer span.End()
 
+	// mark write
+	markWritten(ctx)
+
 	// delete one
 	res, err := c.coll.DeleteOne(ctx, filter, opts...)
 	if err != nil {
@@ -212,6 +221,9 @@ func (c *Collection) FindOneAndDelete(ctx context.Context, filter interface{}, o
 	span.Tag("collection", c.coll.Name())
 	defer span.End()
 
+	// mark write
+	markWritten(ctx)
+
 	// find one and delete
 	res := c.coll.FindOneAndDelete(ctx, filter, opts...)
 
@@ -225,6 +237,9 @@ func (c *Collection) FindOneAndReplace(ctx context.Context, filter interface{},
 	span.Tag("collection", c.coll.Name())
 	defer span.End()
 
+	// mark write
+	markWritten(ctx)
+
 	// find and replace one
 	res := c.coll.FindOneAndReplace(ctx, filter, replacement, opts...)
 
@@ -238,6 +253,9 @@ func (c *Collection) FindOneAndUpdate(ctx context.Context, filter interface{}, u
 	span.Tag("collection", c.coll.Name())
 	defer span.End()
 
+	// mark write
+	markWritten(ctx)
+
 	// find one and update
 	res := c.coll.FindOneAndUpdate(ctx, filter, update, opts...)
 
@@ -252,6 +270,9 @@ func (c *Collection) InsertMany(ctx context.Context, documents []interface{}, op
 	span.Tag("count", len(documents))
 	defer span.End()
 
+	// mark write
+	markWritten(ctx)
+
 	// insert many
 	res, err := c.coll.InsertMany(ctx, documents, opts...)
 	if err != nil {
@@ -268,6 +289,9 @@ func (c *Collection) InsertOne(ctx context.Context, document interface{}, opts .
 	span.Tag("collection", c.coll.Name())
 	defer span.End()
 
+	// mark write
+	markWritten(ctx)
+
 	// insert one
 	res, err := c.coll.InsertOne(ctx, document, opts...)
 	if err != nil {
@@ -284,6 +308,9 @@ func (c *Collection) ReplaceOne(ctx context.Context, filter interface{}, replace
 	span.Tag("collection", c.coll.Name())
 	defer span.End()
 
+	// mark write
+	markWritten(ctx)
+
 	// replace one
 	res, err := c.coll.ReplaceOne(ctx, filter, replacement, opts...)
 	if err != nil {
@@ -300,6 +327,9 @@ func (c *Collection) UpdateMany(ctx context.Context, filter interface{}, update
 	span.Tag("collection", c.coll.Name())
 	defer span.End()
 
+	// mark write
+	markWritten(ctx)
+
 	// update many
 	res, err := c.coll.UpdateMany(ctx, filter, update, opts...)
 	if err != nil {
@@ -321,6 +351,9 @@ func (c *Collection) UpdateOne(ctx context.Context, filter interface{}, update i
 	span.Tag("collection", c.coll.Name())
 	defer span.End()
 
+	// mark write
+	markWritten(ctx)
+
 	// update one
 	res, err := c.coll.UpdateOne(ctx, filter, update, opts...)
 	if err != nil {
@@ -340,6 +373,7 @@ type Iterator struct {
 	ctx     context.Context
 	cursor  lungo.ICursor
 	spans   []xo.Span
+	hooks   []func()
 	counter int64
 	error   error
 }
@@ -370,6 +404,9 @@ func (i *Iterator) All(list interface{}) error {
 		span.End()
 	}
 
+	// run hooks
+	i.finish()
+
 	return xo.W(err)
 }
 
@@ -423,6 +460,17 @@ func (i *Iterator) Close() {
 
 	// unset spans
 	i.spans = nil
+
+	// run hooks
+	i.finish()
+}
+
+func (i *Iterator) finish() {
+	// run and unset hooks
+	for _, hook := range i.hooks {
+		hook()
+	}
+	i.hooks = nil
 }
 
 // SingleResult wraps a single operation result.
@@ -0,0 +1,468 @@
+package coal
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrUnsupportedOperation is returned if an operation is not supported by the
+// type of the collection.
+var ErrUnsupportedOperation = xo.BF("operation not supported by collection")
+
+// TimeSeries defines the options of a time-series collection.
+type TimeSeries struct {
+	// The time field name.
+	TimeField string
+
+	// The time field BSON key.
+	TimeKey string
+
+	// The optional meta field name.
+	MetaField string
+
+	// The optional meta field BSON key.
+	MetaKey string
+
+	// The optional granularity: "seconds", "minutes" or "hours".
+	Granularity string
+}
+
+// Capped defines the options of a capped collection.
+type Capped struct {
+	// The maximum size in bytes.
+	Size int64
+
+	// The optional maximum number of documents.
+	Max int64
+}
+
+// ShardKey defines the shard key of a sharded collection.
+type ShardKey struct {
+	// The un-prefixed shard key fields.
+	Fields []string
+
+	// The translated keys of the shard key.
+	Keys bson.D
+}
+
+// CollectionOptions defines the options of a model collection. They are
+// declared using additional options in the tag of the embedded base:
+//
+//	Base `json:"-" bson:",inline" coal:"events,timeseries=Time:Meta:minutes"`
+//	Base `json:"-" bson:",inline" coal:"logs,capped=1048576:1000"`
+//	Base `json:"-" bson:",inline" coal:"users,collation=en:2"`
+//	Base `json:"-" bson:",inline" coal:"items,shard=Tenant:#ID"`
+//
+// The time-series option expects the time field and optionally the meta field
+// and granularity. The capped option expects the size in bytes and optionally
+// the maximum number of documents. The collation option expects the locale and
+// optionally the strength. The shard option expects the shard key fields where
+// a "#" prefix denotes a hashed key.
+type CollectionOptions struct {
+	// The time-series options.
+	TimeSeries *TimeSeries
+
+	// The capped options.
+	Capped *Capped
+
+	// The default collation.
+	Collation *options.Collation
+
+	// The shard key.
+	ShardKey *ShardKey
+}
+
+// Empty returns whether no options are set.
+func (o CollectionOptions) Empty() bool {
+	return o.TimeSeries == nil && o.Capped == nil && o.Collation == nil && o.ShardKey == nil
+}
+
+// Compile will compile the options to create collection options. The shard
+// key is not included as it is applied separately.
+func (o CollectionOptions) Compile() *options.CreateCollectionOptions {
+	// prepare options
+	opts := options.CreateCollection()
+
+	// set time-series
+	if o.TimeSeries != nil {
+		ts := options.TimeSeries().SetTimeField(o.TimeSeries.TimeKey)
+		if o.TimeSeries.MetaKey != "" {
+			ts.SetMetaField(o.TimeSeries.MetaKey)
+		}
+		if o.TimeSeries.Granularity != "" {
+			ts.SetGranularity(o.TimeSeries.Granularity)
+		}
+		opts.SetTimeSeriesOptions(ts)
+	}
+
+	// set capped
+	if o.Capped != nil {
+		opts.SetCapped(true).SetSizeInBytes(o.Capped.Size)
+		if o.Capped.Max > 0 {
+			opts.SetMaxDocuments(o.Capped.Max)
+		}
+	}
+
+	// set collation
+	if o.Collation != nil {
+		opts.SetCollation(o.Collation)
+	}
+
+	return opts
+}
+
+func parseCollectionOptions(meta *Meta, tags []string) CollectionOptions {
+	// prepare options
+	var opts CollectionOptions
+
+	// parse tags
+	for _, tag := range tags {
+		// split tag
+		name, value, ok := strings.Cut(tag, "=")
+		if !ok || value == "" {
+			panic(fmt.Sprintf(`coal: invalid collection option "%s"`, tag))
+		}
+		args := strings.Split(value, ":")
+
+		// handle option
+		switch name {
+		case "timeseries":
+			// check arguments
+			if len(args) > 3 {
+				panic(`coal: expected to find a collection option of the form 'timeseries=time-field[:meta-field[:granularity]]'`)
+			}
+
+			// get time field
+			timeField := meta.Fields[args[0]]
+			if timeField == nil || timeField.Type != timeType {
+				panic(fmt.Sprintf(`coal: expected time-series field "%s" to be a time.Time field`, args[0]))
+			}
+
+			// set options
+			opts.TimeSeries = &TimeSeries{
+				TimeField: timeField.Name,
+				TimeKey:   timeField.BSONKey,
+			}
+
+			// get meta field
+			if len(args) > 1 && args[1] != "" {
+				metaField := meta.Fields[args[1]]
+				if metaField == nil || metaField.BSONKey == "" {
+					panic(fmt.Sprintf(`coal: unknown time-series meta field "%s"`, args[1]))
+				}
+				opts.TimeSeries.MetaField = metaField.Name
+				opts.TimeSeries.MetaKey = metaField.BSONKey
+			}
+
+			// get granularity
+			if len(args) > 2 {
+				switch args[2] {
+				case "seconds", "minutes", "hours":
+					opts.TimeSeries.Granularity = args[2]
+				default:
+					panic(fmt.Sprintf(`coal: invalid time-series granularity "%s"`, args[2]))
+				}
+			}
+		case "capped":
+			// check arguments
+			if len(args) > 2 {
+				panic(`coal: expected to find a collection option of the form 'capped=size[:max]'`)
+			}
+
+			// parse size
+			size, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil || size <= 0 {
+				panic(fmt.Sprintf(`coal: invalid capped size "%s"`, args[0]))
+			}
+
+			// set options
+			opts.Capped = &Capped{
+				Size: size,
+			}
+
+			// parse max
+			if len(args) > 1 {
+				max, err := strconv.ParseInt(args[1], 10, 64)
+				if err != nil || max <= 0 {
+					panic(fmt.Sprintf(`coal: invalid capped max "%s"`, args[1]))
+				}
+				opts.Capped.Max = max
+			}
+		case "collation":
+			// check arguments
+			if len(args) > 2 {
+				panic(`coal: expected to find a collection option of the form 'collation=locale[:strength]'`)
+			}
+
+			// set options
+			opts.Collation = &options.Collation{
+				Locale: args[0],
+			}
+
+			// parse strength
+			if len(args) > 1 {
+				strength, err := strconv.Atoi(args[1])
+				if err != nil || strength < 1 || strength > 5 {
+					panic(fmt.Sprintf(`coal: invalid collation strength "%s"`, args[1]))
+				}
+				opts.Collation.Strength = strength
+			}
+		case "shard":
+			// prepare key
+			key := &ShardKey{}
+
+			// add fields
+			for _, arg := range args {
+				// get name and key
+				name := strings.TrimPrefix(arg, "#")
+				bsonKey := "_id"
+				if name != "ID" {
+					field := meta.Fields[name]
+					if field == nil || field.BSONKey == "" {
+						panic(fmt.Sprintf(`coal: unknown shard key field "%s"`, arg))
+					}
+					bsonKey = field.BSONKey
+				}
+
+				// add field
+				key.Fields = append(key.Fields, name)
+				if strings.HasPrefix(arg, "#") {
+					key.Keys = append(key.Keys, bson.E{Key: bsonKey, Value: "hashed"})
+				} else {
+					key.Keys = append(key.Keys, bson.E{Key: bsonKey, Value: int32(1)})
+				}
+			}
+
+			// set key
+			opts.ShardKey = key
+		default:
+			panic(fmt.Sprintf(`coal: unknown collection option "%s"`, name))
+		}
+	}
+
+	// check combinations
+	if opts.TimeSeries != nil && opts.Capped != nil {
+		panic(`coal: time-series collections cannot be capped`)
+	} else if opts.Capped != nil && opts.ShardKey != nil {
+		panic(`coal: capped collections cannot be sharded`)
+	}
+
+	return opts
+}
+
+// EnsureCollections will ensure that the collections of the specified models
+// exist and match the declared collection options. Missing collections are
+// created and existing collections are verified. If a shard key is declared and
+// the store is connected to a sharded cluster, the collection is sharded.
+// Models that share a collection must declare the same options.
+//
+// Note: Collection options are not supported by lungo.
+func EnsureCollections(store *Store, models ...Model) error {
+	// create context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// group models by collection
+	var collections []string
+	metas := map[string]*Meta{}
+	for _, model := range models {
+		// get meta
+		meta := GetMeta(model)
+
+		// check existing
+		other, ok := metas[meta.Collection]
+		if !ok {
+			collections = append(collections, meta.Collection)
+			metas[meta.Collection] = meta
+			continue
+		}
+
+		// check options
+		if !reflect.DeepEqual(other.CollectionOptions, meta.CollectionOptions) {
+			return xo.F("conflicting collection options for %s", meta.Collection)
+		}
+	}
+
+	// check support
+	if store.Lungo() {
+		for _, meta := range metas {
+			if !meta.CollectionOptions.Empty() {
+				panic("coal: not supported by lungo")
+			}
+		}
+	}
+
+	// check if sharded
+	var sharded bool
+	if !store.Lungo() {
+		var res struct {
+			Msg string `bson:"msg"`
+		}
+		err := store.Client().Database("admin").RunCommand(ctx, bson.M{"hello": 1}).Decode(&res)
+		if err != nil {
+			return xo.W(err)
+		}
+		sharded = res.Msg == "isdbgrid"
+	}
+
+	// ensure collections
+	for _, name := range collections {
+		// get options
+		opts := metas[name].CollectionOptions
+
+		// find existing collection
+		csr, err := store.DB().ListCollections(ctx, bson.M{"name": name})
+		if err != nil {
+			return xo.W(err)
+		}
+		var specs []collectionSpec
+		err = csr.All(ctx, &specs)
+		if err != nil {
+			return xo.W(err)
+		}
+
+		// create or verify collection
+		if len(specs) == 0 {
+			var createOpts []*options.CreateCollectionOptions
+			if !opts.Empty() {
+				createOpts = append(createOpts, opts.Compile())
+			}
+			err = store.DB().CreateCollection(ctx, name, createOpts...)
+			if err != nil {
+				return xo.W(err)
+			}
+		} else if msg := specs[0].mismatch(opts); msg != "" {
+			return xo.F("collection %s does not match options: %s", name, msg)
+		}
+
+		// shard collection
+		if opts.ShardKey != nil && sharded {
+			cmd := bson.D{
+				{Key: "shardCollection", Value: store.DB().Name() + "." + name},
+				{Key: "key", Value: opts.ShardKey.Keys},
+			}
+			if opts.Collation != nil {
+				cmd = append(cmd, bson.E{Key: "collation", Value: bson.M{"locale": "simple"}})
+			}
+			err = store.Client().Database("admin").RunCommand(ctx, cmd).Err()
+			if err != nil {
+				return xo.W(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+type collectionSpec struct {
+	Name    string `bson:"name"`
+	Type    string `bson:"type"`
+	Options struct {
+		Capped     bool  `bson:"capped"`
+		Size       int64 `bson:"size"`
+		Max        int64 `bson:"max"`
+		TimeSeries *struct {
+			TimeField   string `bson:"timeField"`
+			MetaField   string `bson:"metaField"`
+			Granularity string `bson:"granularity"`
+		} `bson:"timeseries"`
+		Collation *struct {
+			Locale   string `bson:"locale"`
+			Strength int    `bson:"strength"`
+		} `bson:"collation"`
+	} `bson:"options"`
+}
+
+func (s *collectionSpec) mismatch(opts CollectionOptions) string {
+	// check time-series
+	ts := s.Options.TimeSeries
+	if (ts != nil) != (opts.TimeSeries != nil) {
+		return "time-series"
+	} else if ts != nil {
+		if ts.TimeField != opts.TimeSeries.TimeKey || ts.MetaField != opts.TimeSeries.MetaKey {
+			return "time-series fields"
+		} else if opts.TimeSeries.Granularity != "" && ts.Granularity != opts.TimeSeries.Granularity {
+			return "time-series granularity"
+		}
+	}
+
+	// check capped
+	if s.Options.Capped != (opts.Capped != nil) {
+		return "capped"
+	} else if opts.Capped != nil && (s.Options.Max != opts.Capped.Max || s.Options.Size < opts.Capped.Size) {
+		return "capped size"
+	}
+
+	// check collation
+	coll := s.Options.Collation
+	if (coll != nil) != (opts.Collation != nil) {
+		return "collation"
+	} else if coll != nil {
+		if coll.Locale != opts.Collation.Locale {
+			return "collation locale"
+		} else if opts.Collation.Strength != 0 && coll.Strength != opts.Collation.Strength {
+			return "collation strength"
+		}
+	}
+
+	return ""
+}
+
+func (m *Manager) supports(ctx context.Context, operation string, lock bool, filter bson.M) error {
+	// get options
+	opts := m.meta.CollectionOptions
+
+	// check time-series
+	if opts.TimeSeries != nil {
+		// check lock
+		if lock {
+			return ErrUnsupportedOperation.WrapF("locked %s on time-series collection %s", operation, m.meta.Collection)
+		}
+
+		// check transaction
+		if (operation == "Insert" || operation == "InsertAll" || operation == "DeleteAll") && HasTransaction(ctx) {
+			return ErrUnsupportedOperation.WrapF("transactional %s on time-series collection %s", operation, m.meta.Collection)
+		}
+
+		// check operation
+		switch operation {
+		case "Insert", "InsertAll", "Find", "FindFirst", "FindAll", "FindEach", "Project", "Count", "Distinct", "Aggregate", "DeleteAll":
+		default:
+			return ErrUnsupportedOperation.WrapF("%s on time-series collection %s", operation, m.meta.Collection)
+		}
+	}
+
+	// check capped
+	if opts.Capped != nil {
+		switch operation {
+		case "Delete", "DeleteAll", "DeleteFirst":
+			return ErrUnsupportedOperation.WrapF("%s on capped collection %s", operation, m.meta.Collection)
+		}
+	}
+
+	// check shard key
+	if opts.ShardKey != nil {
+		switch operation {
+		case "InsertIfMissing", "ReplaceFirst", "UpdateFirst", "Upsert", "DeleteFirst":
+			for _, field := range opts.ShardKey.Fields {
+				_, ok := filter[field]
+				if !ok && field == "ID" {
+					_, ok = filter["_id"]
+				}
+				if !ok {
+					return ErrUnsupportedOperation.WrapF("%s without shard key field %s on sharded collection %s", operation, field, m.meta.Collection)
+				}
+			}
+		}
+	}
+
+	return nil
+}
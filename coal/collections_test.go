@@ -0,0 +1,248 @@
+package coal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/256dpi/fire/stick"
+)
+
+type eventModel struct {
+	Base   `json:"-" bson:",inline" coal:"events,timeseries=Time:Device:minutes"`
+	Time   time.Time `json:"time"`
+	Device string    `json:"device"`
+}
+
+func (m *eventModel) Validate() error {
+	return nil
+}
+
+type logModel struct {
+	Base    `json:"-" bson:",inline" coal:"logs,capped=1048576:100"`
+	Message string `json:"message"`
+}
+
+func (m *logModel) Validate() error {
+	return nil
+}
+
+type tenantModel struct {
+	Base   `json:"-" bson:",inline" coal:"tenants,collation=en:2,shard=Tenant:#ID"`
+	Tenant string `json:"tenant"`
+	Name   string `json:"name"`
+}
+
+func (m *tenantModel) Validate() error {
+	return nil
+}
+
+func TestCollectionOptions(t *testing.T) {
+	assert.True(t, GetMeta(&postModel{}).CollectionOptions.Empty())
+
+	opts := GetMeta(&eventModel{}).CollectionOptions
+	assert.Equal(t, CollectionOptions{
+		TimeSeries: &TimeSeries{
+			TimeField:   "Time",
+			TimeKey:     "time",
+			MetaField:   "Device",
+			MetaKey:     "device",
+			Granularity: "minutes",
+		},
+	}, opts)
+	assert.Equal(t, options.CreateCollection().SetTimeSeriesOptions(
+		options.TimeSeries().SetTimeField("time").SetMetaField("device").SetGranularity("minutes"),
+	), opts.Compile())
+
+	opts = GetMeta(&logModel{}).CollectionOptions
+	assert.Equal(t, CollectionOptions{
+		Capped: &Capped{
+			Size: 1048576,
+			Max:  100,
+		},
+	}, opts)
+	assert.Equal(t, options.CreateCollection().SetCapped(true).SetSizeInBytes(1048576).SetMaxDocuments(100), opts.Compile())
+
+	opts = GetMeta(&tenantModel{}).CollectionOptions
+	assert.Equal(t, CollectionOptions{
+		Collation: &options.Collation{
+			Locale:   "en",
+			Strength: 2,
+		},
+		ShardKey: &ShardKey{
+			Fields: []string{"Tenant", "ID"},
+			Keys: bson.D{
+				{Key: "tenant", Value: int32(1)},
+				{Key: "_id", Value: "hashed"},
+			},
+		},
+	}, opts)
+	assert.Equal(t, options.CreateCollection().SetCollation(&options.Collation{
+		Locale:   "en",
+		Strength: 2,
+	}), opts.Compile())
+}
+
+func TestCollectionOptionsErrors(t *testing.T) {
+	assert.PanicsWithValue(t, `coal: invalid collection option "foo"`, func() {
+		type invalidModel struct {
+			Base `json:"-" bson:",inline" coal:"foos,foo"`
+			stick.NoValidation
+		}
+		GetMeta(&invalidModel{})
+	})
+
+	assert.PanicsWithValue(t, `coal: unknown collection option "foo"`, func() {
+		type invalidModel struct {
+			Base `json:"-" bson:",inline" coal:"foos,foo=bar"`
+			stick.NoValidation
+		}
+		GetMeta(&invalidModel{})
+	})
+
+	assert.PanicsWithValue(t, `coal: expected time-series field "Foo" to be a time.Time field`, func() {
+		type invalidModel struct {
+			Base `json:"-" bson:",inline" coal:"foos,timeseries=Foo"`
+			Foo  string `json:"foo"`
+			stick.NoValidation
+		}
+		GetMeta(&invalidModel{})
+	})
+
+	assert.PanicsWithValue(t, `coal: invalid time-series granularity "days"`, func() {
+		type invalidModel struct {
+			Base `json:"-" bson:",inline" coal:"foos,timeseries=Time::days"`
+			Time time.Time `json:"time"`
+			stick.NoValidation
+		}
+		GetMeta(&invalidModel{})
+	})
+
+	assert.PanicsWithValue(t, `coal: invalid capped size "foo"`, func() {
+		type invalidModel struct {
+			Base `json:"-" bson:",inline" coal:"foos,capped=foo"`
+			stick.NoValidation
+		}
+		GetMeta(&invalidModel{})
+	})
+
+	assert.PanicsWithValue(t, `coal: invalid collation strength "7"`, func() {
+		type invalidModel struct {
+			Base `json:"-" bson:",inline" coal:"foos,collation=en:7"`
+			stick.NoValidation
+		}
+		GetMeta(&invalidModel{})
+	})
+
+	assert.PanicsWithValue(t, `coal: unknown shard key field "#Foo"`, func() {
+		type invalidModel struct {
+			Base `json:"-" bson:",inline" coal:"foos,shard=#Foo"`
+			stick.NoValidation
+		}
+		GetMeta(&invalidModel{})
+	})
+
+	assert.PanicsWithValue(t, `coal: time-series collections cannot be capped`, func() {
+		type invalidModel struct {
+			Base `json:"-" bson:",inline" coal:"foos,timeseries=Time,capped=1024"`
+			Time time.Time `json:"time"`
+			stick.NoValidation
+		}
+		GetMeta(&invalidModel{})
+	})
+
+	assert.PanicsWithValue(t, `coal: capped collections cannot be sharded`, func() {
+		type invalidModel struct {
+			Base `json:"-" bson:",inline" coal:"foos,capped=1024,shard=ID"`
+			stick.NoValidation
+		}
+		GetMeta(&invalidModel{})
+	})
+}
+
+func TestEnsureCollections(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		if !tester.Store.Lungo() {
+			err := EnsureCollections(tester.Store, &postModel{}, &eventModel{}, &logModel{}, &tenantModel{})
+			assert.NoError(t, err)
+			return
+		}
+
+		err := EnsureCollections(tester.Store, &postModel{}, &commentModel{})
+		assert.NoError(t, err)
+
+		err = EnsureCollections(tester.Store, &postModel{}, &commentModel{})
+		assert.NoError(t, err)
+
+		assert.PanicsWithValue(t, "coal: not supported by lungo", func() {
+			_ = EnsureCollections(tester.Store, &eventModel{})
+		})
+	})
+}
+
+func TestManagerUnsupported(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		// time-series
+
+		event := &eventModel{Base: B(), Time: time.Now(), Device: "foo"}
+		err := tester.Store.M(event).Insert(nil, event)
+		assert.NoError(t, err)
+
+		var events []eventModel
+		err = tester.Store.M(event).FindAll(nil, &events, nil, nil, 0, 0, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+
+		_, err = tester.Store.M(event).Update(nil, nil, event.ID(), bson.M{"$set": bson.M{"Device": "bar"}}, false)
+		assert.True(t, ErrUnsupportedOperation.Is(err))
+
+		_, err = tester.Store.M(event).Delete(nil, nil, event.ID())
+		assert.True(t, ErrUnsupportedOperation.Is(err))
+
+		err = tester.Store.T(nil, false, func(ctx context.Context) error {
+			err = tester.Store.M(event).FindAll(ctx, &events, nil, nil, 0, 0, true)
+			assert.True(t, ErrUnsupportedOperation.Is(err))
+
+			err = tester.Store.M(event).Insert(ctx, &eventModel{Time: time.Now()})
+			assert.True(t, ErrUnsupportedOperation.Is(err))
+
+			return nil
+		})
+		assert.NoError(t, err)
+
+		n, err := tester.Store.M(event).DeleteAll(nil, bson.M{"Device": "foo"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		// capped
+
+		log := &logModel{Base: B(), Message: "foo"}
+		err = tester.Store.M(log).Insert(nil, log)
+		assert.NoError(t, err)
+
+		_, err = tester.Store.M(log).Replace(nil, log, false)
+		assert.NoError(t, err)
+
+		_, err = tester.Store.M(log).Delete(nil, nil, log.ID())
+		assert.True(t, ErrUnsupportedOperation.Is(err))
+
+		_, err = tester.Store.C(log).DeleteMany(nil, bson.M{})
+		assert.NoError(t, err)
+
+		// sharded
+
+		tenant := &tenantModel{Base: B(), Tenant: "foo", Name: "bar"}
+		_, err = tester.Store.M(tenant).Upsert(nil, tenant, bson.M{"Name": "bar"}, bson.M{"$set": bson.M{"Name": "bar"}}, nil, false)
+		assert.True(t, ErrUnsupportedOperation.Is(err))
+
+		_, err = tester.Store.M(tenant).Upsert(nil, tenant, bson.M{"Tenant": "foo", "_id": tenant.ID()}, bson.M{"$set": bson.M{"Name": "bar"}}, nil, false)
+		assert.NoError(t, err)
+
+		_, err = tester.Store.C(tenant).DeleteMany(nil, bson.M{})
+		assert.NoError(t, err)
+	})
+}
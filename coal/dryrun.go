@@ -0,0 +1,218 @@
+package coal
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DryRunSample describes the change of a single document.
+type DryRunSample struct {
+	// The document id.
+	ID ID
+
+	// The affected raw fields before and after the change. For changes made
+	// by FindEachAndUpdate and FindEachAndReplace, the after field holds the
+	// update document or the replacement respectively.
+	Before bson.M
+	After  bson.M
+}
+
+// DryRunChange describes a change that would have been made by a helper.
+type DryRunChange struct {
+	// The helper that would have made the change.
+	Operation string
+
+	// The affected collection.
+	Collection string
+
+	// The number of matched and modified documents.
+	Matched  int64
+	Modified int64
+
+	// Samples of changed documents.
+	Samples []DryRunSample
+}
+
+// DryRun collects the changes reported by helpers in dry-run mode.
+type DryRun struct {
+	// The maximum number of samples collected per change.
+	Samples int
+
+	changes []DryRunChange
+	mutex   sync.Mutex
+}
+
+type dryRunKey struct{}
+
+// WithDryRun will return a context that puts helpers like RenameFields,
+// UnsetFields and FindEachAndUpdate into dry-run mode. In this mode, changes
+// are reported to the provided dry-run instead of being written.
+func WithDryRun(ctx context.Context, dryRun *DryRun) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, dryRun)
+}
+
+// GetDryRun will return the dry-run of the provided context, if available.
+// Custom migrations should report their changes and skip writing if a dry-run
+// is returned.
+func GetDryRun(ctx context.Context) *DryRun {
+	// check context
+	if ctx == nil {
+		return nil
+	}
+
+	// get dry-run
+	dryRun, _ := ctx.Value(dryRunKey{}).(*DryRun)
+	return dryRun
+}
+
+// Record will record the provided change.
+func (d *DryRun) Record(change DryRunChange) {
+	// acquire mutex
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// add change
+	d.changes = append(d.changes, change)
+}
+
+// Changes returns all recorded changes.
+func (d *DryRun) Changes() []DryRunChange {
+	// acquire mutex
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return append([]DryRunChange(nil), d.changes...)
+}
+
+func (d *DryRun) update(ctx context.Context, store *Store, model Model, operation string, filter bson.M, diff func(bson.M) (bson.M, bson.M)) (int64, int64, error) {
+	// count documents
+	count, err := store.C(model).CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// prepare change
+	change := DryRunChange{
+		Operation:  operation,
+		Collection: GetMeta(model).Collection,
+		Matched:    count,
+		Modified:   count,
+	}
+
+	// collect samples
+	if diff != nil && d.Samples > 0 && count > 0 {
+		// find documents
+		iter, err := store.C(model).Find(ctx, filter, options.Find().SetLimit(int64(d.Samples)))
+		if err != nil {
+			return 0, 0, err
+		}
+
+		// ensure close
+		defer iter.Close()
+
+		// iterate documents
+		for iter.Next() {
+			// decode document
+			var doc bson.M
+			err = iter.Decode(&doc)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			// add sample
+			id, _ := doc["_id"].(ID)
+			before, after := diff(doc)
+			change.Samples = append(change.Samples, DryRunSample{
+				ID:     id,
+				Before: before,
+				After:  after,
+			})
+		}
+
+		// check error
+		err = iter.Error()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// record change
+	d.Record(change)
+
+	return change.Matched, change.Modified, nil
+}
+
+func (d *DryRun) process(ctx context.Context, store *Store, model Model, operation string, filter bson.M, concurrency int, fn func(Model) (bson.M, error)) (int64, int64, error) {
+	// prepare change
+	change := DryRunChange{
+		Operation:  operation,
+		Collection: GetMeta(model).Collection,
+	}
+
+	// process documents
+	var mutex sync.Mutex
+	matched, _, err := ProcessEach(ctx, store, model, filter, concurrency, func(model Model) error {
+		// yield object
+		update, err := fn(model)
+		if err != nil {
+			return err
+		}
+
+		// acquire mutex
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		// count non-empty updates
+		if len(update) > 0 {
+			change.Modified++
+			if len(change.Samples) < d.Samples {
+				change.Samples = append(change.Samples, DryRunSample{
+					ID:    model.ID(),
+					After: update,
+				})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// set matched
+	change.Matched = matched
+
+	// record change
+	d.Record(change)
+
+	return change.Matched, change.Modified, nil
+}
+
+func lookupPath(doc bson.M, path string) (interface{}, bool) {
+	// walk path
+	var value interface{} = doc
+	for _, segment := range strings.Split(path, ".") {
+		switch current := value.(type) {
+		case bson.M:
+			var ok bool
+			value, ok = current[segment]
+			if !ok {
+				return nil, false
+			}
+		case bson.D:
+			var ok bool
+			value, ok = current.Map()[segment]
+			if !ok {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
@@ -0,0 +1,503 @@
+package coal
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrConflict is returned if a document has been modified concurrently and the
+// operation could not be applied safely. This includes writes to versioned
+// models with an outdated version.
+var ErrConflict = xo.BF("conflicting concurrent modification")
+
+const elementAttempts = 5
+
+// PushElements will append the provided elements to the embedded array field
+// addressed by the specified field path. The resulting document is decoded
+// into the provided model, passed to the BeforeUpdate and AfterLoad hooks and
+// validated before it is written. It will return whether a document has been
+// found.
+//
+// The array field is updated atomically by conditioning the write on the
+// previously read array value. The operation is retried if the array has been
+// modified concurrently and ErrConflict is returned if all attempts failed.
+//
+// A transaction is required for locking.
+func (m *Manager) PushElements(ctx context.Context, model Model, id ID, field string, elements []interface{}, lock bool, flags ...Flags) (bool, error) {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Manager.PushElements")
+	span.Tag("id", id)
+	defer span.End()
+	defer m.measure("PushElements")()
+
+	// transform elements
+	values := make(bson.A, 0, len(elements))
+	for _, element := range elements {
+		value, err := elementValue(element)
+		if err != nil {
+			return false, err
+		}
+		values = append(values, value)
+	}
+
+	return m.modifyElements(ctx, "PushElements", model, id, field, lock, flags, func(list bson.A) (bson.A, error) {
+		return append(list, values...), nil
+	})
+}
+
+// PullElements will remove the elements from the embedded array field
+// addressed by the specified field path that match the provided filter. The
+// filter may either be a query document that uses the field names of the
+// element type or a value that is compared to the elements. See PushElements
+// for details on validation and atomicity.
+//
+// A transaction is required for locking.
+func (m *Manager) PullElements(ctx context.Context, model Model, id ID, field string, filter interface{}, lock bool, flags ...Flags) (bool, error) {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Manager.PullElements")
+	span.Tag("id", id)
+	defer span.End()
+	defer m.measure("PullElements")()
+
+	// prepare matcher
+	match, err := m.elementMatcher(field, filter)
+	if err != nil {
+		return false, err
+	}
+
+	return m.modifyElements(ctx, "PullElements", model, id, field, lock, flags, func(list bson.A) (bson.A, error) {
+		// filter elements
+		result := make(bson.A, 0, len(list))
+		for _, element := range list {
+			ok, err := match(element)
+			if err != nil {
+				return nil, err
+			} else if !ok {
+				result = append(result, element)
+			}
+		}
+
+		return result, nil
+	})
+}
+
+// UpdateElements will apply the provided update document to the elements of
+// the embedded array field addressed by the specified field path that match
+// the provided filter. The filter and update use the field names of the
+// element type and the elements must be documents. A nil filter matches all
+// elements. See PushElements for details on validation and atomicity.
+//
+// A transaction is required for locking.
+func (m *Manager) UpdateElements(ctx context.Context, model Model, id ID, field string, filter, update bson.M, lock bool, flags ...Flags) (bool, error) {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Manager.UpdateElements")
+	span.Tag("id", id)
+	defer span.End()
+	defer m.measure("UpdateElements")()
+
+	// prepare matcher
+	var match func(interface{}) (bool, error)
+	if filter != nil {
+		var err error
+		match, err = m.elementMatcher(field, filter)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// translate update
+	updateDoc, err := m.elementUpdate(field, update)
+	if err != nil {
+		return false, err
+	}
+
+	return m.modifyElements(ctx, "UpdateElements", model, id, field, lock, flags, func(list bson.A) (bson.A, error) {
+		for i, element := range list {
+			// check element
+			doc, ok := element.(bson.D)
+			if !ok {
+				return nil, xo.F("expected document elements")
+			}
+
+			// match element
+			if match != nil {
+				ok, err := match(doc)
+				if err != nil {
+					return nil, err
+				} else if !ok {
+					continue
+				}
+			}
+
+			// apply update
+			_, err := mongokit.Apply(&doc, nil, updateDoc, false, nil)
+			if err != nil {
+				return nil, xo.W(err)
+			}
+			list[i] = doc
+		}
+
+		return list, nil
+	})
+}
+
+// ReorderElements will reorder the elements of the embedded array field
+// addressed by the specified field path. The order must list every index of
+// the current elements once in their new order. See PushElements for details
+// on validation and atomicity.
+//
+// A transaction is required for locking.
+func (m *Manager) ReorderElements(ctx context.Context, model Model, id ID, field string, order []int, lock bool, flags ...Flags) (bool, error) {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Manager.ReorderElements")
+	span.Tag("id", id)
+	defer span.End()
+	defer m.measure("ReorderElements")()
+
+	return m.modifyElements(ctx, "ReorderElements", model, id, field, lock, flags, func(list bson.A) (bson.A, error) {
+		// check length
+		if len(order) != len(list) {
+			return nil, xo.F("order does not match elements")
+		}
+
+		// reorder elements
+		seen := make([]bool, len(list))
+		result := make(bson.A, 0, len(list))
+		for _, index := range order {
+			if index < 0 || index >= len(list) || seen[index] {
+				return nil, xo.F("invalid order index %d", index)
+			}
+			seen[index] = true
+			result = append(result, list[index])
+		}
+
+		return result, nil
+	})
+}
+
+func (m *Manager) modifyElements(ctx context.Context, operation string, model Model, id ID, field string, lock bool, flags []Flags, fn func(bson.A) (bson.A, error)) (bool, error) {
+	// check support
+	if err := m.supports(ctx, operation, lock, nil); err != nil {
+		return false, err
+	}
+
+	// require transaction
+	if lock && !HasTransaction(ctx) {
+		return false, ErrTransactionRequired.Wrap()
+	}
+
+	// ensure model
+	if model == nil {
+		model = m.meta.Make()
+	}
+
+	// check model
+	if GetMeta(model) != m.meta {
+		return false, ErrMetaMismatch.Wrap()
+	}
+
+	// translate path
+	path, typ, err := m.trans.path(field)
+	if err != nil {
+		return false, err
+	} else if typ == nil || (typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array) {
+		return false, xo.F("expected array field %q", field)
+	}
+
+	// prepare filter
+	filter := bson.M{
+		"_id": id,
+	}
+
+	for attempt := 1; ; attempt++ {
+		// find document
+		var doc bson.D
+		if lock && attempt == 1 {
+			err = m.coll.FindOneAndUpdate(ctx, filter, incrementLock, returnAfterUpdate).Decode(&doc)
+		} else {
+			err = m.coll.FindOne(ctx, filter).Decode(&doc)
+		}
+		if IsMissing(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		// get current elements
+		var current interface{}
+		list := bson.A{}
+		switch value := bsonkit.Get(&doc, path).(type) {
+		case bson.A:
+			current = value
+			list = append(list, bsonkit.Get(bsonkit.Clone(&doc), path).(bson.A)...)
+		case nil, bsonkit.MissingType:
+		default:
+			return false, xo.F("expected array value at %q", field)
+		}
+
+		// modify elements
+		list, err = fn(list)
+		if err != nil {
+			return false, err
+		}
+
+		// update document
+		_, err = bsonkit.Put(&doc, path, list, false)
+		if err != nil {
+			return false, xo.W(err)
+		}
+
+		// decode model
+		err = m.decodeElements(doc, model)
+		if err != nil {
+			return false, err
+		}
+
+		// run hook
+		hookUpdate := bson.M{}
+		err = runBeforeUpdate(ctx, model, hookUpdate, flags)
+		if err != nil {
+			return false, err
+		}
+
+		// apply hook update
+		update := bson.D{}
+		if len(hookUpdate) > 0 {
+			update, err = m.trans.Document(hookUpdate)
+			if err != nil {
+				return false, err
+			}
+			_, err = mongokit.Apply(&doc, nil, &update, false, nil)
+			if err != nil {
+				return false, xo.W(err)
+			}
+			err = m.decodeElements(doc, model)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		// run hook
+		err = runAfterLoad(ctx, model, flags)
+		if err != nil {
+			return false, err
+		}
+
+		// validate model
+		if !Merge(flags).Has(NoValidation) {
+			err = model.Validate()
+			if err != nil {
+				return false, xo.W(err)
+			}
+		}
+
+		// set elements
+		set, _ := bsonkit.Get(&update, "$set").(bson.D)
+		_, err = bsonkit.Put(&update, "$set", append(set, bson.E{Key: path, Value: list}), false)
+		if err != nil {
+			return false, xo.W(err)
+		}
+
+		// increment version
+		if m.meta.Versioned {
+			_, err = bsonkit.Put(&update, "$inc._v", int64(1), false)
+			if err != nil {
+				return false, xo.W(err)
+			}
+		}
+
+		// write elements if unchanged
+		res, err := m.coll.UpdateOne(ctx, bson.M{
+			"_id": id,
+			path:  current,
+		}, update)
+		if err != nil {
+			return false, err
+		} else if res.MatchedCount == 1 {
+			if m.meta.Versioned {
+				model.GetBase().Version++
+			}
+			return true, nil
+		}
+
+		// check attempts
+		if attempt >= elementAttempts {
+			return false, ErrConflict.WrapF("unable to modify %s of %s", field, id)
+		}
+	}
+}
+
+func (m *Manager) elementMatcher(field string, filter interface{}) (func(interface{}) (bool, error), error) {
+	// handle query documents
+	if query, ok := filter.(bson.M); ok {
+		// translate query
+		queryDoc, err := m.elementQuery(field, query)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(element interface{}) (bool, error) {
+			// check element
+			doc, ok := element.(bson.D)
+			if !ok {
+				return false, nil
+			}
+
+			// match element
+			ok, err := mongokit.Match(&doc, queryDoc)
+			if err != nil {
+				return false, xo.W(err)
+			}
+
+			return ok, nil
+		}, nil
+	}
+
+	// transform value
+	value, err := elementValue(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(element interface{}) (bool, error) {
+		return bsonkit.Compare(element, value) == 0, nil
+	}, nil
+}
+
+func (m *Manager) elementQuery(field string, query bson.M) (bsonkit.Doc, error) {
+	// translate keys
+	translated, err := m.elementKeys(field, query, func(key string, value interface{}) (interface{}, error) {
+		// translate logical operators
+		if key == "$and" || key == "$or" || key == "$nor" {
+			list, ok := value.([]bson.M)
+			if !ok {
+				return nil, xo.F("expected list of documents for %s", key)
+			}
+			result := make(bson.A, 0, len(list))
+			for _, item := range list {
+				doc, err := m.elementQuery(field, item)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, *doc)
+			}
+			return result, nil
+		}
+
+		return nil, xo.F("unsupported operator %q", key)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// transform query
+	doc, err := bsonkit.Transform(translated)
+	if err != nil {
+		return nil, xo.W(err)
+	}
+
+	return doc, nil
+}
+
+func (m *Manager) elementUpdate(field string, update bson.M) (bsonkit.Doc, error) {
+	// translate operators
+	translated := bson.M{}
+	for operator, value := range update {
+		// check operator
+		if !strings.HasPrefix(operator, "$") {
+			return nil, xo.F("expected update operator instead of %q", operator)
+		} else if unsafeOperators[operator] {
+			return nil, xo.F("unsafe operator %q", operator)
+		}
+
+		// check value
+		doc, ok := value.(bson.M)
+		if !ok {
+			return nil, xo.F("expected document for %s", operator)
+		}
+
+		// translate keys
+		keys, err := m.elementKeys(field, doc, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		translated[operator] = keys
+	}
+
+	// transform update
+	doc, err := bsonkit.Transform(translated)
+	if err != nil {
+		return nil, xo.W(err)
+	}
+
+	return doc, nil
+}
+
+func (m *Manager) elementKeys(field string, doc bson.M, operator func(string, interface{}) (interface{}, error)) (bson.M, error) {
+	// translate prefix
+	prefix, err := m.trans.Path(field + ".$")
+	if err != nil {
+		return nil, err
+	}
+
+	// translate keys
+	result := bson.M{}
+	for key, value := range doc {
+		// handle operators
+		if strings.HasPrefix(key, "$") {
+			if operator == nil {
+				return nil, xo.F("unsupported operator %q", key)
+			}
+			value, err = operator(key, value)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+			continue
+		}
+
+		// translate path
+		path, err := m.trans.Path(field + ".$." + key)
+		if err != nil {
+			return nil, err
+		}
+
+		result[strings.TrimPrefix(path, prefix+".")] = value
+	}
+
+	return result, nil
+}
+
+func elementValue(value interface{}) (interface{}, error) {
+	// transform value
+	doc, err := bsonkit.Transform(bson.M{"v": value})
+	if err != nil {
+		return nil, xo.W(err)
+	}
+
+	return bsonkit.Get(doc, "v"), nil
+}
+
+func (m *Manager) decodeElements(doc bson.D, model Model) error {
+	// marshal document
+	bytes, err := bson.Marshal(doc)
+	if err != nil {
+		return xo.W(err)
+	}
+
+	// reset and decode model
+	reflect.ValueOf(model).Elem().Set(reflect.Zero(m.meta.Type))
+	err = bson.Unmarshal(bytes, model)
+	if err != nil {
+		return xo.W(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package coal
+
+import (
+	"testing"
+
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type orderItem struct {
+	Sku      string `json:"sku"`
+	Quantity int    `json:"quantity" bson:"qty"`
+}
+
+type orderModel struct {
+	Base  `json:"-" bson:",inline" coal:"orders"`
+	Items []orderItem `json:"items"`
+	Tags  []string    `json:"tags"`
+	Note  string      `json:"-" bson:"-"`
+}
+
+var orderValidator func(*orderModel)
+
+func (m *orderModel) Validate() error {
+	// call validator
+	if orderValidator != nil {
+		orderValidator(m)
+	}
+
+	// check items
+	for _, item := range m.Items {
+		if item.Quantity <= 0 {
+			return xo.F("invalid quantity")
+		}
+	}
+
+	return nil
+}
+
+func TestTranslatorPath(t *testing.T) {
+	trans := NewTranslator(&orderModel{})
+
+	for path, result := range map[string]string{
+		"Items":            "items",
+		"Items.0.Quantity": "items.0.qty",
+		"Items.$.Sku":      "items.$.sku",
+		"Items.$[].Sku":    "items.$[].sku",
+		"Items.$[i].Sku":   "items.$[i].sku",
+		"Tags.1":           "tags.1",
+		"#foo.bar":         "foo.bar",
+	} {
+		res, err := trans.Path(path)
+		assert.NoError(t, err, path)
+		assert.Equal(t, result, res, path)
+	}
+
+	for path, msg := range map[string]string{
+		"Foo":           `unknown field "Foo"`,
+		"Note":          `virtual field "Note"`,
+		"Items.Sku":     `invalid array segment "Sku" in path "Items.Sku"`,
+		"Items.0.Foo":   `unknown field "Foo" in path "Items.0.Foo"`,
+		"Items.0.Sku.x": `invalid path "Items.0.Sku.x"`,
+	} {
+		_, err := trans.Path(path)
+		assert.Error(t, err, path)
+		assert.Equal(t, msg, err.Error(), path)
+	}
+}
+
+func TestManagerElements(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		m := tester.Store.M(&orderModel{})
+
+		order := tester.Insert(&orderModel{}).(*orderModel)
+
+		/* push */
+
+		var model orderModel
+		found, err := m.PushElements(nil, &model, order.ID(), "Items", []interface{}{
+			orderItem{Sku: "a", Quantity: 1},
+			orderItem{Sku: "b", Quantity: 2},
+			orderItem{Sku: "c", Quantity: 3},
+		}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, []orderItem{
+			{Sku: "a", Quantity: 1},
+			{Sku: "b", Quantity: 2},
+			{Sku: "c", Quantity: 3},
+		}, model.Items)
+
+		found, err = m.PushElements(nil, nil, New(), "Items", []interface{}{
+			orderItem{Sku: "a", Quantity: 1},
+		}, false)
+		assert.NoError(t, err)
+		assert.False(t, found)
+
+		found, err = m.PushElements(nil, nil, order.ID(), "Items", []interface{}{
+			orderItem{Sku: "d", Quantity: 0},
+		}, false)
+		assert.Error(t, err)
+		assert.Equal(t, "invalid quantity", err.Error())
+		assert.False(t, found)
+
+		found, err = m.PushElements(nil, nil, order.ID(), "Tags", []interface{}{"x", "y", "x"}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+
+		/* pull */
+
+		found, err = m.PullElements(nil, &model, order.ID(), "Items", bson.M{
+			"Quantity": bson.M{"$gte": 3},
+		}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, []orderItem{
+			{Sku: "a", Quantity: 1},
+			{Sku: "b", Quantity: 2},
+		}, model.Items)
+		assert.Equal(t, []string{"x", "y", "x"}, model.Tags)
+
+		found, err = m.PullElements(nil, &model, order.ID(), "Tags", "x", false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, []string{"y"}, model.Tags)
+
+		/* update */
+
+		found, err = m.UpdateElements(nil, &model, order.ID(), "Items", bson.M{
+			"Sku": "b",
+		}, bson.M{
+			"$inc": bson.M{"Quantity": 5},
+		}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, []orderItem{
+			{Sku: "a", Quantity: 1},
+			{Sku: "b", Quantity: 7},
+		}, model.Items)
+
+		found, err = m.UpdateElements(nil, &model, order.ID(), "Items", nil, bson.M{
+			"$set": bson.M{"Quantity": 0},
+		}, false)
+		assert.Error(t, err)
+		assert.False(t, found)
+
+		found, err = m.UpdateElements(nil, &model, order.ID(), "Items", nil, bson.M{
+			"$set": bson.M{"Foo": 0},
+		}, false)
+		assert.Error(t, err)
+		assert.Equal(t, `unknown field "Foo" in path "Items.$.Foo"`, err.Error())
+		assert.False(t, found)
+
+		/* reorder */
+
+		found, err = m.ReorderElements(nil, &model, order.ID(), "Items", []int{1, 0}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, []orderItem{
+			{Sku: "b", Quantity: 7},
+			{Sku: "a", Quantity: 1},
+		}, model.Items)
+
+		_, err = m.ReorderElements(nil, nil, order.ID(), "Items", []int{0, 0}, false)
+		assert.Error(t, err)
+		assert.Equal(t, "invalid order index 0", err.Error())
+
+		_, err = m.ReorderElements(nil, nil, order.ID(), "Items", []int{0}, false)
+		assert.Error(t, err)
+		assert.Equal(t, "order does not match elements", err.Error())
+
+		/* errors */
+
+		_, err = m.PushElements(nil, nil, order.ID(), "Items.0.Sku", []interface{}{"a"}, false)
+		assert.Error(t, err)
+		assert.Equal(t, `expected array field "Items.0.Sku"`, err.Error())
+
+		_, err = m.PushElements(nil, nil, order.ID(), "Items", nil, true)
+		assert.True(t, ErrTransactionRequired.Is(err))
+
+		stored := tester.Fetch(&orderModel{}, order.ID()).(*orderModel)
+		assert.Equal(t, []orderItem{
+			{Sku: "b", Quantity: 7},
+			{Sku: "a", Quantity: 1},
+		}, stored.Items)
+		assert.Equal(t, []string{"y"}, stored.Tags)
+	})
+}
+
+func TestManagerElementsConflict(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		m := tester.Store.M(&orderModel{})
+
+		order := tester.Insert(&orderModel{}).(*orderModel)
+
+		var modified int
+		orderValidator = func(model *orderModel) {
+			if modified < 1 {
+				modified++
+				_, err := tester.Store.C(model).UpdateOne(nil, bson.M{"_id": order.ID()}, bson.M{
+					"$set": bson.M{"tags": bson.A{"z"}},
+				})
+				assert.NoError(t, err)
+			}
+		}
+		defer func() {
+			orderValidator = nil
+		}()
+
+		found, err := m.PushElements(nil, nil, order.ID(), "Tags", []interface{}{"x"}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+
+		stored := tester.Fetch(&orderModel{}, order.ID()).(*orderModel)
+		assert.Equal(t, []string{"z", "x"}, stored.Tags)
+
+		orderValidator = func(model *orderModel) {
+			_, err := tester.Store.C(model).UpdateOne(nil, bson.M{"_id": order.ID()}, bson.M{
+				"$push": bson.M{"tags": "z"},
+			})
+			assert.NoError(t, err)
+		}
+
+		found, err = m.PushElements(nil, nil, order.ID(), "Tags", []interface{}{"x"}, false)
+		assert.Error(t, err)
+		assert.True(t, ErrConflict.Is(err))
+		assert.False(t, found)
+	})
+}
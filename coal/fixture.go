@@ -0,0 +1,422 @@
+package coal
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/yaml.v3"
+
+	"github.com/256dpi/fire/stick"
+)
+
+// Fixture is a set of documents keyed by the collection of their models. The
+// documents use the BSON keys of the model fields. A document may declare a
+// symbolic name using the "_ref" key. Other documents may then reference it
+// using a string of the form "@name" which is resolved to the id of the
+// document when the fixture is seeded. Other strings that start with "@" must
+// be escaped by doubling the "@".
+//
+//	posts:
+//	  - _ref: post1
+//	    title: Hello World!
+//	comments:
+//	  - message: Great post!
+//	    post_id: "@post1"
+type Fixture map[string][]bson.M
+
+// ParseFixture will parse a YAML or JSON encoded fixture.
+func ParseFixture(data []byte) (Fixture, error) {
+	// decode data
+	var raw map[string][]map[string]interface{}
+	err := yaml.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, xo.W(err)
+	}
+
+	// prepare fixture
+	fixture := Fixture{}
+	for coll, docs := range raw {
+		list := make([]bson.M, 0, len(docs))
+		for _, doc := range docs {
+			list = append(list, fixtureValue(doc).(bson.M))
+		}
+		fixture[coll] = list
+	}
+
+	return fixture, nil
+}
+
+// LoadFixture will read and parse the YAML or JSON encoded fixture file.
+func LoadFixture(path string) (Fixture, error) {
+	// read file
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, xo.W(err)
+	}
+
+	return ParseFixture(data)
+}
+
+// YAML will encode the fixture as YAML.
+func (f Fixture) YAML() ([]byte, error) {
+	// encode fixture
+	data, err := yaml.Marshal(map[string][]bson.M(f))
+	if err != nil {
+		return nil, xo.W(err)
+	}
+
+	return data, nil
+}
+
+// JSON will encode the fixture as JSON. Dates are encoded as RFC 3339 strings
+// and decimals as strings. Both are converted back according to the field
+// types of the model when the fixture is seeded.
+func (f Fixture) JSON() ([]byte, error) {
+	// encode fixture
+	data, err := json.MarshalIndent(map[string][]bson.M(f), "", "  ")
+	if err != nil {
+		return nil, xo.W(err)
+	}
+
+	return data, nil
+}
+
+// Snapshot holds the documents of the registered models of a tester.
+type Snapshot struct {
+	documents map[string][]bson.Raw
+}
+
+// Fixture will return a fixture with the documents of the snapshot. Internal
+// fields of the documents except the id are omitted.
+func (s *Snapshot) Fixture() (Fixture, error) {
+	// prepare fixture
+	fixture := Fixture{}
+	for coll, docs := range s.documents {
+		list := make([]bson.M, 0, len(docs))
+		for _, raw := range docs {
+			// decode document
+			var doc bson.M
+			err := bson.Unmarshal(raw, &doc)
+			if err != nil {
+				return nil, xo.W(err)
+			}
+
+			// remove internal fields
+			delete(doc, "_lk")
+			delete(doc, "_tk")
+			delete(doc, "_sc")
+			delete(doc, "_v")
+
+			list = append(list, escapeFixtureValue(fixtureValue(doc)).(bson.M))
+		}
+		fixture[coll] = list
+	}
+
+	return fixture, nil
+}
+
+// Seed will insert the documents of the provided fixture and return the ids of
+// the documents that declared a symbolic name. The documents are decoded into
+// and inserted as models of the registered types.
+func (t *Tester) Seed(fixture Fixture) map[string]ID {
+	// index models
+	models := map[string]Model{}
+	for _, model := range t.Models {
+		models[GetMeta(model).Collection] = model
+	}
+
+	// get collections
+	colls := make([]string, 0, len(fixture))
+	for coll := range fixture {
+		if models[coll] == nil {
+			panic(xo.F(`unknown fixture collection "%s"`, coll))
+		}
+		colls = append(colls, coll)
+	}
+	sort.Strings(colls)
+
+	// assign ids
+	refs := map[string]ID{}
+	ids := map[string][]ID{}
+	for _, coll := range colls {
+		for _, doc := range fixture[coll] {
+			// get id
+			id, _ := doc["_id"].(string)
+			if id == "" {
+				id = New()
+			}
+			ids[coll] = append(ids[coll], id)
+
+			// add reference
+			if ref, ok := doc["_ref"].(string); ok {
+				if refs[ref] != "" {
+					panic(xo.F(`duplicate fixture reference "%s"`, ref))
+				}
+				refs[ref] = id
+			}
+		}
+	}
+
+	// insert documents in model order
+	for _, model := range t.Models {
+		// get meta and documents
+		meta := GetMeta(model)
+		docs := fixture[meta.Collection]
+		if len(docs) == 0 {
+			continue
+		}
+
+		// decode models
+		list := make([]Model, 0, len(docs))
+		for i, doc := range docs {
+			// prepare document
+			prepared := bson.M{}
+			for key, value := range doc {
+				if key == "_ref" {
+					continue
+				}
+				var typ reflect.Type
+				if field := meta.DatabaseFields[key]; field != nil {
+					typ = field.Type
+				}
+				prepared[key] = resolveFixtureValue(typ, value, refs)
+			}
+			prepared["_id"] = ids[meta.Collection][i]
+
+			// encode document
+			bytes, err := bson.Marshal(prepared)
+			if err != nil {
+				panic(err)
+			}
+
+			// decode model
+			item := meta.Make()
+			err = bson.Unmarshal(bytes, item)
+			if err != nil {
+				panic(err)
+			}
+
+			list = append(list, item)
+		}
+
+		// insert models
+		err := t.Store.M(model).InsertAll(nil, list)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return refs
+}
+
+// SeedFile will load the YAML or JSON encoded fixture file and seed it.
+func (t *Tester) SeedFile(path string) map[string]ID {
+	// load fixture
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		panic(err)
+	}
+
+	return t.Seed(fixture)
+}
+
+// Snapshot will capture the documents of the registered models.
+func (t *Tester) Snapshot() *Snapshot {
+	// prepare snapshot
+	snapshot := &Snapshot{
+		documents: map[string][]bson.Raw{},
+	}
+
+	// capture documents
+	for _, model := range t.Models {
+		// find documents
+		iter, err := t.Store.C(model).Find(nil, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+		if err != nil {
+			panic(err)
+		}
+
+		// decode documents
+		var docs []bson.Raw
+		err = iter.All(&docs)
+		if err != nil {
+			panic(err)
+		}
+
+		snapshot.documents[GetMeta(model).Collection] = docs
+	}
+
+	return snapshot
+}
+
+// Restore will clean the registered models and insert the documents captured
+// by the snapshot.
+func (t *Tester) Restore(snapshot *Snapshot) {
+	// clean models
+	t.Clean()
+
+	// insert documents
+	for _, model := range t.Models {
+		// get documents
+		docs := snapshot.documents[GetMeta(model).Collection]
+		if len(docs) == 0 {
+			continue
+		}
+
+		// prepare list
+		list := make([]interface{}, 0, len(docs))
+		for _, doc := range docs {
+			list = append(list, doc)
+		}
+
+		// insert documents
+		_, err := t.Store.C(model).InsertMany(nil, list)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func fixtureValue(value interface{}) interface{} {
+	// convert value
+	switch value := value.(type) {
+	case map[string]interface{}:
+		doc := bson.M{}
+		for key, item := range value {
+			doc[key] = fixtureValue(item)
+		}
+		return doc
+	case bson.M:
+		doc := bson.M{}
+		for key, item := range value {
+			doc[key] = fixtureValue(item)
+		}
+		return doc
+	case bson.D:
+		doc := bson.M{}
+		for _, item := range value {
+			doc[item.Key] = fixtureValue(item.Value)
+		}
+		return doc
+	case []interface{}:
+		list := make(bson.A, 0, len(value))
+		for _, item := range value {
+			list = append(list, fixtureValue(item))
+		}
+		return list
+	case bson.A:
+		list := make(bson.A, 0, len(value))
+		for _, item := range value {
+			list = append(list, fixtureValue(item))
+		}
+		return list
+	case primitive.DateTime:
+		return value.Time().UTC()
+	case primitive.Decimal128:
+		return value.String()
+	case primitive.Binary:
+		return value.Data
+	default:
+		return value
+	}
+}
+
+func escapeFixtureValue(value interface{}) interface{} {
+	// escape strings that would be resolved as references
+	switch value := value.(type) {
+	case string:
+		if strings.HasPrefix(value, "@") {
+			return "@" + value
+		}
+	case bson.M:
+		for key, item := range value {
+			value[key] = escapeFixtureValue(item)
+		}
+	case bson.A:
+		for i, item := range value {
+			value[i] = escapeFixtureValue(item)
+		}
+	}
+
+	return value
+}
+
+func resolveFixtureValue(typ reflect.Type, value interface{}, refs map[string]ID) interface{} {
+	// dereference pointers
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	// resolve references
+	switch value := value.(type) {
+	case string:
+		if strings.HasPrefix(value, "@@") {
+			return value[1:]
+		} else if strings.HasPrefix(value, "@") {
+			id, ok := refs[value[1:]]
+			if !ok {
+				panic(xo.F(`unknown fixture reference "%s"`, value[1:]))
+			}
+			return id
+		}
+
+		// parse dates and decimals
+		switch typ {
+		case timeType:
+			date, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				panic(err)
+			}
+			return date
+		case decimalType, decimal128Type:
+			dec, err := primitive.ParseDecimal128(value)
+			if err != nil {
+				panic(err)
+			}
+			return dec
+		}
+	case bson.M:
+		doc := bson.M{}
+		for key, item := range value {
+			doc[key] = resolveFixtureValue(fixtureElementType(typ, key), item, refs)
+		}
+		return doc
+	case bson.A:
+		list := make(bson.A, 0, len(value))
+		for _, item := range value {
+			list = append(list, resolveFixtureValue(fixtureElementType(typ, ""), item, refs))
+		}
+		return list
+	}
+
+	return value
+}
+
+func fixtureElementType(typ reflect.Type, key string) reflect.Type {
+	// check type
+	if typ == nil {
+		return nil
+	}
+
+	// get element type
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return typ.Elem()
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath == "" && stick.BSON.GetKey(field) == key {
+				return field.Type
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package coal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/256dpi/fire/stick"
+)
+
+const testFixture = `
+posts:
+  - _ref: post1
+    title: Hello World!
+    published: true
+  - _ref: post2
+    title: "@@second"
+comments:
+  - _ref: comment1
+    message: Great post!
+    post_id: "@post1"
+  - message: Thanks!
+    post_id: "@post1"
+    parent: "@comment1"
+selections:
+  - name: Favorites
+    post_ids: ["@post1", "@post2"]
+notes:
+  - title: Note
+    created_at: 2020-01-02T03:04:05Z
+    post_id: "@post2"
+`
+
+func TestFixture(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		fixture, err := ParseFixture([]byte(testFixture))
+		assert.NoError(t, err)
+
+		refs := tester.Seed(fixture)
+		assert.Len(t, refs, 3)
+
+		post1 := tester.Fetch(&postModel{}, refs["post1"]).(*postModel)
+		assert.Equal(t, "Hello World!", post1.Title)
+		assert.True(t, post1.Published)
+
+		post2 := tester.Fetch(&postModel{}, refs["post2"]).(*postModel)
+		assert.Equal(t, "@second", post2.Title)
+
+		comments := *tester.FindAll(&commentModel{}).(*[]*commentModel)
+		assert.Len(t, comments, 2)
+		for _, comment := range comments {
+			assert.Equal(t, refs["post1"], comment.Post)
+			if comment.ID() == refs["comment1"] {
+				assert.Nil(t, comment.Parent)
+			} else {
+				assert.Equal(t, refs["comment1"], *comment.Parent)
+			}
+		}
+
+		selection := tester.FindLast(&selectionModel{}).(*selectionModel)
+		assert.Equal(t, []ID{refs["post1"], refs["post2"]}, selection.Posts)
+
+		note := tester.FindLast(&noteModel{}).(*noteModel)
+		assert.Equal(t, refs["post2"], note.Post)
+		assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), note.CreatedAt.UTC())
+	})
+}
+
+func TestFixtureErrors(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		_, err := ParseFixture([]byte("foo"))
+		assert.Error(t, err)
+
+		assert.PanicsWithError(t, `unknown fixture collection "bars"`, func() {
+			tester.Seed(Fixture{"bars": nil})
+		})
+
+		assert.PanicsWithError(t, `unknown fixture reference "bar"`, func() {
+			tester.Seed(Fixture{"comments": {{"post_id": "@bar"}}})
+		})
+
+		assert.PanicsWithError(t, `duplicate fixture reference "foo"`, func() {
+			tester.Seed(Fixture{"posts": {{"_ref": "foo"}, {"_ref": "foo"}}})
+		})
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		fixture, err := ParseFixture([]byte(testFixture))
+		assert.NoError(t, err)
+
+		refs := tester.Seed(fixture)
+
+		snapshot := tester.Snapshot()
+
+		tester.Delete(&postModel{Base: B(refs["post1"])})
+		tester.Insert(&postModel{Title: "Third"})
+		assert.Equal(t, 2, tester.Count(&postModel{}))
+
+		tester.Restore(snapshot)
+		assert.Equal(t, 2, tester.Count(&postModel{}))
+		assert.Equal(t, 2, tester.Count(&commentModel{}))
+		assert.Equal(t, "Hello World!", tester.Fetch(&postModel{}, refs["post1"]).(*postModel).Title)
+
+		for _, encode := range []func(Fixture) ([]byte, error){Fixture.YAML, Fixture.JSON} {
+			fixture, err = snapshot.Fixture()
+			assert.NoError(t, err)
+			assert.Len(t, fixture["posts"], 2)
+			assert.Nil(t, fixture["posts"][0]["_lk"])
+
+			data, err := encode(fixture)
+			assert.NoError(t, err)
+
+			path := filepath.Join(t.TempDir(), "fixture")
+			err = os.WriteFile(path, data, 0644)
+			assert.NoError(t, err)
+
+			tester.Clean()
+			tester.SeedFile(path)
+			assert.Equal(t, 2, tester.Count(&postModel{}))
+			assert.Equal(t, 2, tester.Count(&commentModel{}))
+
+			note := tester.FindLast(&noteModel{}).(*noteModel)
+			assert.Equal(t, refs["post2"], note.Post)
+			assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), note.CreatedAt.UTC())
+
+			selection := tester.FindLast(&selectionModel{}).(*selectionModel)
+			assert.Equal(t, []ID{refs["post1"], refs["post2"]}, selection.Posts)
+
+			post2 := tester.Fetch(&postModel{}, refs["post2"]).(*postModel)
+			assert.Equal(t, "@second", post2.Title)
+		}
+	})
+}
+
+type fixtureItem struct {
+	Date   time.Time  `bson:"date"`
+	Amount Decimal    `bson:"amount"`
+	Due    *time.Time `bson:"due"`
+}
+
+type fixtureModel struct {
+	Base   `json:"-" bson:",inline" coal:"fixtures"`
+	Item   fixtureItem            `bson:"item"`
+	Items  []fixtureItem          `bson:"items"`
+	Dates  map[string]time.Time   `bson:"dates"`
+	Prices []primitive.Decimal128 `bson:"prices"`
+	stick.NoValidation
+}
+
+func TestSnapshotNestedValues(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester = NewTester(tester.Store, &fixtureModel{})
+		tester.Clean()
+
+		date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		amount := decimal.RequireFromString("12.34")
+		price := primitive.NewDecimal128(0, 42)
+
+		id := tester.Insert(&fixtureModel{
+			Item: fixtureItem{
+				Date:   date,
+				Amount: amount,
+			},
+			Items: []fixtureItem{
+				{Date: date, Amount: amount, Due: &date},
+			},
+			Dates: map[string]time.Time{
+				"foo": date,
+			},
+			Prices: []primitive.Decimal128{price},
+		}).ID()
+
+		for _, encode := range []func(Fixture) ([]byte, error){Fixture.YAML, Fixture.JSON} {
+			fixture, err := tester.Snapshot().Fixture()
+			assert.NoError(t, err)
+
+			data, err := encode(fixture)
+			assert.NoError(t, err)
+
+			fixture, err = ParseFixture(data)
+			assert.NoError(t, err)
+
+			tester.Clean()
+			tester.Seed(fixture)
+
+			model := tester.Fetch(&fixtureModel{}, id).(*fixtureModel)
+			assert.Equal(t, date, model.Item.Date.UTC())
+			assert.True(t, amount.Equal(model.Item.Amount))
+			assert.Len(t, model.Items, 1)
+			assert.Equal(t, date, model.Items[0].Date.UTC())
+			assert.True(t, amount.Equal(model.Items[0].Amount))
+			assert.Equal(t, date, model.Items[0].Due.UTC())
+			assert.Equal(t, date, model.Dates["foo"].UTC())
+			assert.Equal(t, []primitive.Decimal128{price}, model.Prices)
+		}
+
+		tester.Clean()
+	})
+}
@@ -0,0 +1,88 @@
+package coal
+
+import (
+	"context"
+
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// BeforeInsert may be implemented by models to prepare them before they are
+// validated and inserted by a manager. It is invoked by Insert, InsertAll and
+// InsertIfMissing.
+type BeforeInsert interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+// BeforeUpdate may be implemented by models to prepare writes before they are
+// performed by a manager. For Replace and ReplaceFirst the hook is invoked on
+// the provided model with a nil update and may change the model before it is
+// validated. For Update, UpdateFirst, UpdateAll and Upsert the hook is invoked
+// on the provided or a zero model with the untranslated update document that
+// may be changed by the hook, e.g. to add a "$set" operator. For PushElements,
+// PullElements, UpdateElements and ReorderElements the hook is invoked on the
+// modified model with an empty update document that is written together with
+// the elements.
+type BeforeUpdate interface {
+	BeforeUpdate(ctx context.Context, update bson.M) error
+}
+
+// AfterLoad may be implemented by models to derive fields after they have been
+// loaded by a manager. It is invoked before validation by Find, FindFirst,
+// FindAll, FindEach, PushElements, PullElements, UpdateElements and
+// ReorderElements and on the models returned by Update, UpdateFirst and
+// Upsert.
+type AfterLoad interface {
+	AfterLoad(ctx context.Context) error
+}
+
+func runBeforeInsert(ctx context.Context, model Model, flags []Flags) error {
+	// check flags
+	if Merge(flags).Has(NoHooks) {
+		return nil
+	}
+
+	// run hook
+	if hook, ok := model.(BeforeInsert); ok {
+		err := hook.BeforeInsert(ctx)
+		if err != nil {
+			return xo.W(err)
+		}
+	}
+
+	return nil
+}
+
+func runBeforeUpdate(ctx context.Context, model Model, update bson.M, flags []Flags) error {
+	// check flags
+	if Merge(flags).Has(NoHooks) {
+		return nil
+	}
+
+	// run hook
+	if hook, ok := model.(BeforeUpdate); ok {
+		err := hook.BeforeUpdate(ctx, update)
+		if err != nil {
+			return xo.W(err)
+		}
+	}
+
+	return nil
+}
+
+func runAfterLoad(ctx context.Context, model Model, flags []Flags) error {
+	// check flags
+	if Merge(flags).Has(NoHooks) {
+		return nil
+	}
+
+	// run hook
+	if hook, ok := model.(AfterLoad); ok {
+		err := hook.AfterLoad(ctx)
+		if err != nil {
+			return xo.W(err)
+		}
+	}
+
+	return nil
+}
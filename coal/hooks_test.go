@@ -0,0 +1,164 @@
+package coal
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type hookModel struct {
+	Base    `json:"-" bson:",inline" coal:"hooks"`
+	Title   string   `json:"title"`
+	Slug    string   `json:"slug"`
+	Updates int      `json:"updates"`
+	Tags    []string `json:"tags"`
+	Length  int      `json:"-" bson:"-"`
+}
+
+func (m *hookModel) BeforeInsert(context.Context) error {
+	if m.Title == "" {
+		return xo.F("missing title")
+	}
+	m.Slug = strings.ToLower(m.Title)
+	return nil
+}
+
+func (m *hookModel) BeforeUpdate(_ context.Context, update bson.M) error {
+	if update == nil {
+		m.Slug = strings.ToLower(m.Title)
+		m.Updates++
+		return nil
+	}
+	inc, _ := update["$inc"].(bson.M)
+	if inc == nil {
+		inc = bson.M{}
+		update["$inc"] = inc
+	}
+	inc["Updates"] = 1
+	return nil
+}
+
+func (m *hookModel) AfterLoad(context.Context) error {
+	m.Length = len(m.Title)
+	return nil
+}
+
+func (m *hookModel) Validate() error {
+	if m.Slug == "" {
+		return xo.F("missing slug")
+	}
+	return nil
+}
+
+func TestManagerHooks(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		m := tester.Store.M(&hookModel{})
+
+		/* insert */
+
+		model := &hookModel{Title: "Hello"}
+		err := m.Insert(nil, model)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", model.Slug)
+
+		err = m.Insert(nil, &hookModel{})
+		assert.Error(t, err)
+		assert.Equal(t, "missing title", err.Error())
+
+		err = m.Insert(nil, &hookModel{}, NoHooks)
+		assert.Error(t, err)
+		assert.Equal(t, "missing slug", err.Error())
+
+		/* find */
+
+		var found hookModel
+		ok, err := m.Find(nil, &found, model.ID(), false)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 5, found.Length)
+
+		found = hookModel{}
+		ok, err = m.Find(nil, &found, model.ID(), false, NoHooks)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 0, found.Length)
+
+		var list []*hookModel
+		err = m.FindAll(nil, &list, bson.M{"_id": model.ID()}, nil, 0, 0, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, 5, list[0].Length)
+
+		iter, err := m.FindEach(nil, bson.M{"_id": model.ID()}, nil, 0, 0, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.True(t, iter.Next())
+		found = hookModel{}
+		assert.NoError(t, iter.Decode(&found))
+		assert.Equal(t, 5, found.Length)
+		iter.Close()
+
+		/* replace */
+
+		model.Title = "World"
+		ok, err = m.Replace(nil, model, false)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "world", model.Slug)
+		assert.Equal(t, 1, model.Updates)
+
+		/* update */
+
+		found = hookModel{}
+		ok, err = m.Update(nil, &found, model.ID(), bson.M{
+			"$set": bson.M{"Title": "Foo"},
+		}, false)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 2, found.Updates)
+		assert.Equal(t, 3, found.Length)
+
+		n, err := m.UpdateAll(nil, bson.M{"_id": model.ID()}, bson.M{
+			"$set": bson.M{"Title": "Bar"},
+		}, false)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		found = hookModel{}
+		ok, err = m.Update(nil, &found, model.ID(), bson.M{
+			"$set": bson.M{"Title": "Baz"},
+		}, false, NoHooks)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 3, found.Updates)
+		assert.Equal(t, 0, found.Length)
+
+		/* elements */
+
+		found = hookModel{}
+		ok, err = m.PushElements(nil, &found, model.ID(), "Tags", []interface{}{"a", "b"}, false)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"a", "b"}, found.Tags)
+		assert.Equal(t, 4, found.Updates)
+		assert.Equal(t, 3, found.Length)
+
+		found = hookModel{}
+		ok, err = m.ReorderElements(nil, &found, model.ID(), "Tags", []int{1, 0}, false, NoHooks)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"b", "a"}, found.Tags)
+		assert.Equal(t, 4, found.Updates)
+		assert.Equal(t, 0, found.Length)
+
+		found = hookModel{}
+		ok, err = m.Find(nil, &found, model.ID(), false)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"b", "a"}, found.Tags)
+		assert.Equal(t, 4, found.Updates)
+	})
+}
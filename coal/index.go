@@ -1,10 +1,15 @@
 package coal
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/256dpi/xo"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -26,6 +31,9 @@ type Index struct {
 
 	// The partial filter expression.
 	Filter bson.D
+
+	// The collation.
+	Collation *options.Collation
 }
 
 // Compile will compile the index to a mongo.IndexModel.
@@ -43,6 +51,11 @@ func (i *Index) Compile() mongo.IndexModel {
 		opts.SetPartialFilterExpression(i.Filter)
 	}
 
+	// set collation if available
+	if i.Collation != nil {
+		opts.SetCollation(i.Collation)
+	}
+
 	// add index
 	return mongo.IndexModel{
 		Keys:    i.Keys,
@@ -53,7 +66,7 @@ func (i *Index) Compile() mongo.IndexModel {
 // AddIndex will add an index to the models index list. Fields that are prefixed
 // with a dash will result in a descending key.
 func AddIndex(model Model, unique bool, expiry time.Duration, fields ...string) {
-	addIndex(model, unique, expiry, fields, nil)
+	addIndex(model, unique, expiry, fields, nil, nil)
 }
 
 // AddPartialIndex is similar to AddIndex except that it adds an index with a
@@ -65,10 +78,23 @@ func AddPartialIndex(model Model, unique bool, expiry time.Duration, fields []st
 	}
 
 	// add index
-	addIndex(model, unique, expiry, fields, filter)
+	addIndex(model, unique, expiry, fields, filter, nil)
 }
 
-func addIndex(model Model, unique bool, expiry time.Duration, fields []string, filter bson.M) {
+// AddCollatedIndex is similar to AddIndex except that it adds an index with the
+// specified collation. Only queries that use the same collation can use the
+// index, see WithCollation.
+func AddCollatedIndex(model Model, collation *options.Collation, unique bool, expiry time.Duration, fields ...string) {
+	// check collation
+	if collation == nil || collation.Locale == "" {
+		panic(`coal: missing collation locale`)
+	}
+
+	// add index
+	addIndex(model, unique, expiry, fields, nil, collation)
+}
+
+func addIndex(model Model, unique bool, expiry time.Duration, fields []string, filter bson.M, collation *options.Collation) {
 	// get meta and translator
 	meta := GetMeta(model)
 	trans := NewTranslator(model)
@@ -88,6 +114,13 @@ func addIndex(model Model, unique bool, expiry time.Duration, fields []string, f
 		}
 	}
 
+	// check keys, existing indexes are matched by their keys
+	for _, index := range meta.Indexes {
+		if sameKeys(index.Keys, keys) {
+			panic(fmt.Sprintf(`coal: duplicate index "%s"`, indexName(keys)))
+		}
+	}
+
 	// clean fields
 	cleanFields := make([]string, 0, len(fields))
 	for _, field := range fields {
@@ -96,17 +129,19 @@ func addIndex(model Model, unique bool, expiry time.Duration, fields []string, f
 
 	// add index
 	meta.Indexes = append(meta.Indexes, Index{
-		Fields: cleanFields,
-		Keys:   keys,
-		Unique: unique,
-		Expiry: expiry,
-		Filter: filterDoc,
+		Fields:    cleanFields,
+		Keys:      keys,
+		Unique:    unique,
+		Expiry:    expiry,
+		Filter:    filterDoc,
+		Collation: collation,
 	})
 }
 
 // EnsureIndexes will ensure that the registered indexes of the specified models
 // exist. It may fail early if some indexes are already existing and do not
-// match the registered indexes.
+// match the registered indexes. Use ReconcileIndexes to also drop stale and
+// recreate changed indexes.
 func EnsureIndexes(store *Store, models ...Model) error {
 	// create context
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
@@ -119,7 +154,189 @@ func EnsureIndexes(store *Store, models ...Model) error {
 
 		// ensure all indexes
 		for _, index := range meta.Indexes {
-			_, err := store.C(model).Native().Indexes().CreateOne(ctx, index.Compile())
+			_, err := store.C(model).Native().Indexes().CreateOne(ctx, compileIndex(store, &index))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// IndexAction defines the action of an index change.
+type IndexAction string
+
+// The available index actions.
+const (
+	// IndexAdd creates a registered index that does not exist.
+	IndexAdd IndexAction = "add"
+
+	// IndexRemove drops an existing index that is not registered anymore.
+	IndexRemove IndexAction = "remove"
+
+	// IndexConflict recreates an existing index whose options do not match the
+	// registered index with the same keys.
+	IndexConflict IndexAction = "conflict"
+)
+
+// IndexChange describes a change required to reconcile the existing indexes of
+// a collection with the registered indexes.
+type IndexChange struct {
+	// The change action.
+	Action IndexAction
+
+	// The affected collection.
+	Collection string
+
+	// The name of the existing index or the default name of the added index.
+	Name string
+
+	// The keys of the index.
+	Keys bson.D
+
+	// The registered index, if any.
+	Index *Index
+}
+
+// String returns a description of the change.
+func (c IndexChange) String() string {
+	return fmt.Sprintf("%s %s.%s", c.Action, c.Collection, c.Name)
+}
+
+// PlanIndexes will list the existing indexes of the collections used by the
+// specified models and compare them with the registered indexes. It returns
+// the changes required to add missing, remove stale and recreate conflicting
+// indexes. Indexes of models that share a collection are planned together.
+// Indexes are matched by their keys, registering multiple indexes with the same
+// keys for a collection is therefore not supported.
+func PlanIndexes(store *Store, models ...Model) ([]IndexChange, error) {
+	// create context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// group models by collection
+	var collections []string
+	groups := map[string][]Model{}
+	seen := map[*Meta]bool{}
+	for _, model := range models {
+		// skip repeated models
+		meta := GetMeta(model)
+		if seen[meta] {
+			continue
+		}
+		seen[meta] = true
+
+		// add model
+		name := meta.Collection
+		if _, ok := groups[name]; !ok {
+			collections = append(collections, name)
+		}
+		groups[name] = append(groups[name], model)
+	}
+
+	// plan collections
+	var changes []IndexChange
+	for _, name := range collections {
+		// collect registered indexes
+		var registered []*Index
+		for _, model := range groups[name] {
+			meta := GetMeta(model)
+			for i := range meta.Indexes {
+				// check keys
+				for _, index := range registered {
+					if sameKeys(index.Keys, meta.Indexes[i].Keys) {
+						return nil, xo.F(`duplicate index "%s" for collection "%s"`, indexName(index.Keys), name)
+					}
+				}
+
+				registered = append(registered, &meta.Indexes[i])
+			}
+		}
+
+		// list existing indexes
+		existing, err := listIndexes(ctx, store, groups[name][0])
+		if err != nil {
+			return nil, err
+		}
+
+		// match registered indexes
+		matched := map[string]bool{}
+		for _, index := range registered {
+			// find existing index
+			var found *indexSpec
+			for i := range existing {
+				if sameKeys(existing[i].Key, index.Keys) {
+					found = &existing[i]
+					break
+				}
+			}
+
+			// add missing index
+			if found == nil {
+				changes = append(changes, IndexChange{
+					Action:     IndexAdd,
+					Collection: name,
+					Name:       indexName(index.Keys),
+					Keys:       index.Keys,
+					Index:      index,
+				})
+				continue
+			}
+
+			// mark existing index
+			matched[found.Name] = true
+
+			// recreate conflicting index
+			if !found.matches(index, !store.Lungo()) {
+				changes = append(changes, IndexChange{
+					Action:     IndexConflict,
+					Collection: name,
+					Name:       found.Name,
+					Keys:       index.Keys,
+					Index:      index,
+				})
+			}
+		}
+
+		// remove stale indexes
+		for _, spec := range existing {
+			if spec.Name != "_id_" && !matched[spec.Name] {
+				changes = append(changes, IndexChange{
+					Action:     IndexRemove,
+					Collection: name,
+					Name:       spec.Name,
+					Keys:       spec.Key,
+				})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// ApplyIndexes will apply the provided index changes.
+func ApplyIndexes(store *Store, changes []IndexChange) error {
+	// create context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// apply changes
+	for _, change := range changes {
+		// get indexes
+		indexes := store.DB().Collection(change.Collection).Indexes()
+
+		// drop removed and conflicting indexes
+		if change.Action == IndexRemove || change.Action == IndexConflict {
+			_, err := indexes.DropOne(ctx, change.Name)
+			if err != nil {
+				return err
+			}
+		}
+
+		// create added and conflicting indexes
+		if change.Action == IndexAdd || change.Action == IndexConflict {
+			_, err := indexes.CreateOne(ctx, compileIndex(store, change.Index))
 			if err != nil {
 				return err
 			}
@@ -128,3 +345,161 @@ func EnsureIndexes(store *Store, models ...Model) error {
 
 	return nil
 }
+
+// ReconcileIndexes will plan the index changes for the specified models, log
+// them to the provided writer and apply them unless a dry-run is requested.
+func ReconcileIndexes(store *Store, logger io.Writer, dryRun bool, models ...Model) ([]IndexChange, error) {
+	// plan changes
+	changes, err := PlanIndexes(store, models...)
+	if err != nil {
+		return nil, err
+	}
+
+	// log changes
+	if logger != nil {
+		for _, change := range changes {
+			_, _ = fmt.Fprintln(logger, change.String())
+		}
+	}
+
+	// apply changes
+	if !dryRun {
+		err = ApplyIndexes(store, changes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+type indexSpec struct {
+	Name   string `bson:"name"`
+	Key    bson.D `bson:"key"`
+	Unique bool   `bson:"unique"`
+	Expiry *int64 `bson:"expireAfterSeconds"`
+	Filter bson.D `bson:"partialFilterExpression"`
+
+	Collation *struct {
+		Locale   string `bson:"locale"`
+		Strength int    `bson:"strength"`
+	} `bson:"collation"`
+}
+
+func (s *indexSpec) matches(index *Index, collation bool) bool {
+	// check unique
+	if s.Unique != index.Unique {
+		return false
+	}
+
+	// check expiry
+	var expiry int64
+	if s.Expiry != nil {
+		expiry = *s.Expiry
+	}
+	if expiry != int64(index.Expiry/time.Second) {
+		return false
+	}
+
+	// check filter
+	if (s.Filter == nil) != (index.Filter == nil) {
+		return false
+	} else if s.Filter != nil {
+		a, err1 := bson.Marshal(s.Filter)
+		b, err2 := bson.Marshal(index.Filter)
+		if err1 != nil || err2 != nil || !bytes.Equal(a, b) {
+			return false
+		}
+	}
+
+	// check collation
+	if collation {
+		if (s.Collation == nil) != (index.Collation == nil) {
+			return false
+		} else if s.Collation != nil {
+			if s.Collation.Locale != index.Collation.Locale {
+				return false
+			} else if index.Collation.Strength != 0 && s.Collation.Strength != index.Collation.Strength {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func compileIndex(store *Store, index *Index) mongo.IndexModel {
+	// compile index
+	model := index.Compile()
+
+	// collations are not supported by lungo
+	if store.Lungo() {
+		model.Options.Collation = nil
+	}
+
+	return model
+}
+
+func listIndexes(ctx context.Context, store *Store, model Model) ([]indexSpec, error) {
+	// list indexes
+	csr, err := store.C(model).Native().Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// decode indexes
+	var list []indexSpec
+	err = csr.All(ctx, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	// sort indexes
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list, nil
+}
+
+func sameKeys(a, b bson.D) bool {
+	// check length
+	if len(a) != len(b) {
+		return false
+	}
+
+	// check keys and directions
+	for i := range a {
+		if a[i].Key != b[i].Key || keyDirection(a[i].Value) != keyDirection(b[i].Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func keyDirection(value interface{}) interface{} {
+	// normalize numeric directions
+	switch num := value.(type) {
+	case int32:
+		return num > 0
+	case int64:
+		return num > 0
+	case int:
+		return num > 0
+	case float64:
+		return num > 0
+	}
+
+	return value
+}
+
+func indexName(keys bson.D) string {
+	// join keys and values
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+	}
+
+	return strings.Join(parts, "_")
+}
@@ -1,11 +1,14 @@
 package coal
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/stick"
 )
 
 func TestIndex(t *testing.T) {
@@ -61,3 +64,154 @@ func TestIndex(t *testing.T) {
 		metaCache[oldMeta.Type] = oldMeta
 	})
 }
+
+func TestReconcileIndexes(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		oldMeta := GetMeta(&postModel{})
+		delete(metaCache, oldMeta.Type)
+
+		newMeta := GetMeta(&postModel{})
+		AddIndex(&postModel{}, false, 0, "Title")
+		AddIndex(&postModel{}, true, 0, "Published", "-Title")
+
+		assert.PanicsWithValue(t, `coal: duplicate index "title_1"`, func() {
+			AddCollatedIndex(&postModel{}, CaseInsensitive("en"), false, 0, "Title")
+		})
+
+		err := tester.Store.C(&postModel{}).Native().Drop(nil)
+		assert.NoError(t, err)
+
+		/* add */
+
+		var buf strings.Builder
+		changes, err := ReconcileIndexes(tester.Store, &buf, true, &postModel{})
+		assert.NoError(t, err)
+		assert.Equal(t, []IndexChange{
+			{
+				Action:     IndexAdd,
+				Collection: "posts",
+				Name:       "title_1",
+				Keys:       newMeta.Indexes[0].Keys,
+				Index:      &newMeta.Indexes[0],
+			},
+			{
+				Action:     IndexAdd,
+				Collection: "posts",
+				Name:       "published_1_title_-1",
+				Keys:       newMeta.Indexes[1].Keys,
+				Index:      &newMeta.Indexes[1],
+			},
+		}, changes)
+		assert.Equal(t, "add posts.title_1\nadd posts.published_1_title_-1\n", buf.String())
+
+		changes, err = PlanIndexes(tester.Store, &postModel{})
+		assert.NoError(t, err)
+		assert.Len(t, changes, 2)
+
+		changes, err = ReconcileIndexes(tester.Store, nil, false, &postModel{})
+		assert.NoError(t, err)
+		assert.Len(t, changes, 2)
+
+		changes, err = PlanIndexes(tester.Store, &postModel{})
+		assert.NoError(t, err)
+		assert.Empty(t, changes)
+
+		/* conflict and remove */
+
+		newMeta.Indexes[0].Expiry = time.Hour
+		newMeta.Indexes = newMeta.Indexes[:1]
+
+		buf.Reset()
+		changes, err = ReconcileIndexes(tester.Store, &buf, false, &postModel{})
+		assert.NoError(t, err)
+		assert.Equal(t, "conflict posts.title_1\nremove posts.published_1_title_-1\n", buf.String())
+		assert.Len(t, changes, 2)
+
+		changes, err = PlanIndexes(tester.Store, &postModel{})
+		assert.NoError(t, err)
+		assert.Empty(t, changes)
+
+		err = tester.Store.C(&postModel{}).Native().Drop(nil)
+		assert.NoError(t, err)
+
+		metaCache[oldMeta.Type] = oldMeta
+	})
+}
+
+func TestPlanIndexesDuplicate(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		type draftModel struct {
+			Base  `json:"-" bson:",inline" coal:"posts"`
+			Title string `json:"title" bson:"title"`
+			stick.NoValidation
+		}
+
+		oldMeta := GetMeta(&postModel{})
+		delete(metaCache, oldMeta.Type)
+
+		AddIndex(&postModel{}, false, 0, "Title")
+		AddPartialIndex(&draftModel{}, false, 0, []string{"Title"}, bson.M{"Title": "draft"})
+
+		changes, err := PlanIndexes(tester.Store, &postModel{}, &postModel{})
+		assert.NoError(t, err)
+		assert.Len(t, changes, 1)
+
+		changes, err = PlanIndexes(tester.Store, &postModel{}, &draftModel{})
+		assert.Error(t, err)
+		assert.Equal(t, `duplicate index "title_1" for collection "posts"`, err.Error())
+		assert.Nil(t, changes)
+
+		delete(metaCache, GetMeta(&draftModel{}).Type)
+		metaCache[oldMeta.Type] = oldMeta
+	})
+}
+
+func TestCollatedIndex(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		oldMeta := GetMeta(&postModel{})
+		delete(metaCache, oldMeta.Type)
+
+		newMeta := GetMeta(&postModel{})
+		AddCollatedIndex(&postModel{}, CaseInsensitive("en"), true, 0, "Title")
+		assert.Equal(t, []Index{
+			{
+				Fields: []string{"Title"},
+				Keys: bson.D{
+					{Key: "title", Value: int32(1)},
+				},
+				Unique:    true,
+				Collation: CaseInsensitive("en"),
+			},
+		}, newMeta.Indexes)
+		assert.Equal(t, CaseInsensitive("en"), newMeta.Indexes[0].Compile().Options.Collation)
+
+		assert.PanicsWithValue(t, `coal: missing collation locale`, func() {
+			AddCollatedIndex(&postModel{}, nil, false, 0, "Title")
+		})
+
+		err := tester.Store.C(&postModel{}).Native().Drop(nil)
+		assert.NoError(t, err)
+
+		changes, err := ReconcileIndexes(tester.Store, nil, false, &postModel{})
+		assert.NoError(t, err)
+		assert.Len(t, changes, 1)
+
+		changes, err = PlanIndexes(tester.Store, &postModel{})
+		assert.NoError(t, err)
+		assert.Empty(t, changes)
+
+		if !tester.Store.Lungo() {
+			newMeta.Indexes[0].Collation = CaseInsensitive("de")
+
+			changes, err = PlanIndexes(tester.Store, &postModel{})
+			assert.NoError(t, err)
+			assert.Len(t, changes, 1)
+			assert.Equal(t, IndexConflict, changes[0].Action)
+		}
+
+		err = tester.Store.C(&postModel{}).Native().Drop(nil)
+		assert.NoError(t, err)
+
+		metaCache[oldMeta.Type] = oldMeta
+	})
+}
@@ -0,0 +1,314 @@
+package coal
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IssueKind defines the kind of integrity issue.
+type IssueKind string
+
+// The available integrity issue kinds.
+const (
+	// DanglingReference is reported for to-one and to-many relationships that
+	// reference a missing document.
+	DanglingReference IssueKind = "dangling-reference"
+
+	// DuplicateReference is reported for to-one relationships that reference
+	// the same document as another document although the inverse relationship
+	// is a has-one relationship.
+	DuplicateReference IssueKind = "duplicate-reference"
+
+	// InvalidDocument is reported for documents that fail validation.
+	InvalidDocument IssueKind = "invalid-document"
+)
+
+// Issue describes a single integrity issue.
+type Issue struct {
+	// The issue kind.
+	Kind IssueKind
+
+	// The model name.
+	Model string
+
+	// The document id.
+	ID ID
+
+	// The relationship field name.
+	Field string
+
+	// The referenced document id.
+	Reference ID
+
+	// The validation error.
+	Error string
+
+	// Whether the issue has been repaired.
+	Repaired bool
+}
+
+// String returns a description of the issue.
+func (i Issue) String() string {
+	switch i.Kind {
+	case InvalidDocument:
+		return fmt.Sprintf("%s %s/%s: %s", i.Kind, i.Model, i.ID, i.Error)
+	default:
+		return fmt.Sprintf("%s %s/%s#%s: %s", i.Kind, i.Model, i.ID, i.Field, i.Reference)
+	}
+}
+
+// IntegrityReport is the result of an integrity check.
+type IntegrityReport struct {
+	// The number of scanned documents.
+	Scanned int64
+
+	// The found issues.
+	Issues []Issue
+
+	// The number of repaired documents.
+	Repaired int64
+}
+
+// CheckIntegrity will scan the stored documents of the specified models and
+// report dangling references, has-one uniqueness violations and documents that
+// fail validation. Relationships to models that are not in the list are not
+// checked. If repair is enabled, dangling references of optional to-one and
+// to-many relationships are removed. The version of repaired documents of
+// versioned models is incremented.
+func CheckIntegrity(ctx context.Context, store *Store, models []Model, repair bool) (*IntegrityReport, error) {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/CheckIntegrity")
+	defer span.End()
+
+	// index metas
+	metas := map[string]*Meta{}
+	for _, model := range models {
+		meta := GetMeta(model)
+		metas[meta.PluralName] = meta
+	}
+
+	// collect ids
+	ids := map[string]map[ID]bool{}
+	for _, model := range models {
+		set, err := collectIDs(ctx, store, model)
+		if err != nil {
+			return nil, err
+		}
+		ids[GetMeta(model).PluralName] = set
+	}
+
+	// prepare report
+	report := &IntegrityReport{}
+
+	// check documents
+	for _, model := range models {
+		err := checkIntegrity(ctx, store, GetMeta(model), metas, ids, repair, report)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// IntegrityMigrator returns a function that may be used as a migration to check
+// and optionally repair the integrity of the specified models. The migration
+// returns the number of issues and repaired documents. Repairs are skipped
+// during dry-runs. The optional callback receives the report.
+func IntegrityMigrator(models []Model, repair bool, fn func(*IntegrityReport)) func(context.Context, *Store) (int64, int64, error) {
+	return func(ctx context.Context, store *Store) (int64, int64, error) {
+		// check dry-run
+		dryRun := GetDryRun(ctx)
+
+		// check integrity
+		report, err := CheckIntegrity(ctx, store, models, repair && dryRun == nil)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		// record dry-run
+		if dryRun != nil {
+			dryRun.Record(DryRunChange{
+				Operation: "CheckIntegrity",
+				Matched:   int64(len(report.Issues)),
+			})
+		}
+
+		// yield report
+		if fn != nil {
+			fn(report)
+		}
+
+		return int64(len(report.Issues)), report.Repaired, nil
+	}
+}
+
+func collectIDs(ctx context.Context, store *Store, model Model) (map[ID]bool, error) {
+	// find ids
+	iter, err := store.C(model).Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	// ensure close
+	defer iter.Close()
+
+	// collect ids
+	set := map[ID]bool{}
+	for iter.Next() {
+		var doc struct {
+			ID ID `bson:"_id"`
+		}
+		err = iter.Decode(&doc)
+		if err != nil {
+			return nil, err
+		}
+		set[doc.ID] = true
+	}
+
+	// check error
+	err = iter.Error()
+	if err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+func checkIntegrity(ctx context.Context, store *Store, meta *Meta, metas map[string]*Meta, ids map[string]map[ID]bool, repair bool, report *IntegrityReport) error {
+	// get unique relationships
+	unique := map[string]bool{}
+	for _, field := range meta.Relationships {
+		if !field.ToOne || metas[field.RelType] == nil {
+			continue
+		}
+		for _, relField := range metas[field.RelType].Relationships {
+			if relField.HasOne && relField.RelType == meta.PluralName && relField.RelInverse == field.RelName {
+				unique[field.Name] = true
+			}
+		}
+	}
+
+	// prepare referrers
+	referrers := map[string]map[ID]ID{}
+	for name := range unique {
+		referrers[name] = map[ID]ID{}
+	}
+
+	// find documents
+	iter, err := store.C(meta.Make()).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+
+	// ensure close
+	defer iter.Close()
+
+	// check documents
+	for iter.Next() {
+		// decode model
+		model := meta.Make()
+		err = iter.Decode(model)
+		if err != nil {
+			return err
+		}
+
+		// increment
+		report.Scanned++
+
+		// validate model
+		err = model.Validate()
+		if err != nil {
+			report.Issues = append(report.Issues, Issue{
+				Kind:  InvalidDocument,
+				Model: meta.Name,
+				ID:    model.ID(),
+				Error: err.Error(),
+			})
+		}
+
+		// check relationships
+		update := bson.M{}
+		for _, field := range meta.OrderedFields {
+			// check field
+			if (!field.ToOne && !field.ToMany) || ids[field.RelType] == nil {
+				continue
+			}
+
+			// check references
+			var dangling []ID
+			for _, id := range references(model, field) {
+				if !ids[field.RelType][id] {
+					dangling = append(dangling, id)
+				}
+			}
+
+			// check uniqueness
+			if unique[field.Name] {
+				for _, id := range references(model, field) {
+					if other, ok := referrers[field.Name][id]; ok {
+						report.Issues = append(report.Issues, Issue{
+							Kind:      DuplicateReference,
+							Model:     meta.Name,
+							ID:        model.ID(),
+							Field:     field.Name,
+							Reference: id,
+							Error:     fmt.Sprintf("also referenced by %s", other),
+						})
+					} else {
+						referrers[field.Name][id] = model.ID()
+					}
+				}
+			}
+
+			// prepare repair
+			repairable := repair && len(dangling) > 0 && (field.ToMany || field.Type.Kind() == reflect.Ptr)
+			if repairable {
+				// unset reference or keep valid references
+				var value interface{}
+				if field.ToMany {
+					valid := make([]ID, 0)
+					for _, id := range references(model, field) {
+						if ids[field.RelType][id] {
+							valid = append(valid, id)
+						}
+					}
+					value = valid
+				}
+				update[field.BSONKey] = value
+			}
+
+			// add issues
+			for _, id := range dangling {
+				report.Issues = append(report.Issues, Issue{
+					Kind:      DanglingReference,
+					Model:     meta.Name,
+					ID:        model.ID(),
+					Field:     field.Name,
+					Reference: id,
+					Repaired:  repairable,
+				})
+			}
+		}
+
+		// apply repair and increment version to conflict with concurrent writes
+		if len(update) > 0 {
+			updateDoc := bson.M{"$set": update}
+			if meta.Versioned {
+				updateDoc["$inc"] = bson.M{"_v": int64(1)}
+			}
+			_, err = store.C(model).UpdateOne(ctx, bson.M{"_id": model.ID()}, updateDoc)
+			if err != nil {
+				return err
+			}
+			report.Repaired++
+		}
+	}
+
+	return iter.Error()
+}
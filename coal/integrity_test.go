@@ -0,0 +1,186 @@
+package coal
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/stick"
+)
+
+type integrityModel struct {
+	Base `json:"-" bson:",inline" coal:"integrities"`
+	Name string `json:"name"`
+}
+
+func (m *integrityModel) Validate() error {
+	if m.Name == "" {
+		return xo.F("missing name")
+	}
+
+	return nil
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.DeleteAll(&integrityModel{})
+
+		models := []Model{&postModel{}, &commentModel{}, &selectionModel{}, &noteModel{}, &integrityModel{}}
+
+		post1 := tester.Insert(&postModel{Title: "post1"}).(*postModel)
+		post2 := tester.Insert(&postModel{Title: "post2"}).(*postModel)
+		missing := New()
+
+		tester.Insert(&commentModel{Post: post1.ID()})
+		comment := tester.Insert(&commentModel{Post: missing}).(*commentModel)
+		selection := tester.Insert(&selectionModel{Posts: []ID{post1.ID(), missing}}).(*selectionModel)
+		note1 := tester.Insert(&noteModel{Post: post1.ID()}).(*noteModel)
+		note2 := tester.Insert(&noteModel{Post: post1.ID()}).(*noteModel)
+		tester.Insert(&noteModel{Post: post2.ID()})
+		invalid := tester.Insert(&integrityModel{Name: "valid"}).(*integrityModel)
+		tester.Update(invalid, map[string]interface{}{"$set": map[string]interface{}{"Name": ""}})
+
+		report, err := CheckIntegrity(nil, tester.Store, models, false)
+		assert.NoError(t, err)
+		assert.Equal(t, &IntegrityReport{
+			Scanned: 9,
+			Issues: []Issue{
+				{
+					Kind:      DanglingReference,
+					Model:     "coal.commentModel",
+					ID:        comment.ID(),
+					Field:     "Post",
+					Reference: missing,
+				},
+				{
+					Kind:      DanglingReference,
+					Model:     "coal.selectionModel",
+					ID:        selection.ID(),
+					Field:     "Posts",
+					Reference: missing,
+				},
+				{
+					Kind:      DuplicateReference,
+					Model:     "coal.noteModel",
+					ID:        note2.ID(),
+					Field:     "Post",
+					Reference: post1.ID(),
+					Error:     "also referenced by " + note1.ID(),
+				},
+				{
+					Kind:  InvalidDocument,
+					Model: "coal.integrityModel",
+					ID:    invalid.ID(),
+					Error: "missing name",
+				},
+			},
+		}, report)
+		assert.Equal(t, "dangling-reference coal.commentModel/"+comment.ID()+"#Post: "+missing, report.Issues[0].String())
+		assert.Equal(t, "invalid-document coal.integrityModel/"+invalid.ID()+": missing name", report.Issues[3].String())
+
+		report, err = CheckIntegrity(nil, tester.Store, models, true)
+		assert.NoError(t, err)
+		assert.Len(t, report.Issues, 4)
+		assert.False(t, report.Issues[0].Repaired)
+		assert.True(t, report.Issues[1].Repaired)
+		assert.Equal(t, int64(1), report.Repaired)
+
+		tester.Refresh(selection)
+		assert.Equal(t, []ID{post1.ID()}, selection.Posts)
+
+		report, err = CheckIntegrity(nil, tester.Store, models, true)
+		assert.NoError(t, err)
+		assert.Len(t, report.Issues, 3)
+		assert.Equal(t, int64(0), report.Repaired)
+	})
+}
+
+type versionedIntegrityModel struct {
+	Base  `json:"-" bson:",inline" coal:"versioned-integrities,versioned"`
+	Posts []ID `json:"-" bson:"post_ids" coal:"posts:posts"`
+	stick.NoValidation
+}
+
+func TestCheckIntegrityVersioned(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		m := tester.Store.M(&versionedIntegrityModel{})
+
+		_, err := m.DeleteAll(nil, bson.M{})
+		assert.NoError(t, err)
+
+		post := tester.Insert(&postModel{Title: "post"}).(*postModel)
+
+		model := &versionedIntegrityModel{Posts: []ID{post.ID(), New()}}
+		err = m.Insert(nil, model)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), model.Version)
+
+		report, err := CheckIntegrity(nil, tester.Store, []Model{&postModel{}, &versionedIntegrityModel{}}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), report.Repaired)
+
+		var repaired versionedIntegrityModel
+		found, err := m.Find(nil, &repaired, model.ID(), false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, []ID{post.ID()}, repaired.Posts)
+		assert.Equal(t, int64(2), repaired.Version)
+
+		found, err = m.Replace(nil, model, false)
+		assert.Error(t, err)
+		assert.True(t, ErrConflict.Is(err))
+		assert.False(t, found)
+
+		_, err = m.DeleteAll(nil, bson.M{})
+		assert.NoError(t, err)
+	})
+}
+
+func TestIntegrityMigrator(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.DeleteAll(&integrityModel{})
+
+		models := []Model{&postModel{}, &selectionModel{}}
+
+		post := tester.Insert(&postModel{Title: "post"}).(*postModel)
+		selection := tester.Insert(&selectionModel{Posts: []ID{post.ID(), New()}}).(*selectionModel)
+
+		var reports []*IntegrityReport
+		m := NewMigrator()
+		m.Add(Migration{
+			Name:   "integrity",
+			DryRun: true,
+			Migrator: IntegrityMigrator(models, true, func(report *IntegrityReport) {
+				reports = append(reports, report)
+			}),
+		})
+
+		var buf bytes.Buffer
+		changes, err := m.DryRun(tester.Store, &buf, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []DryRunChange{
+			{Operation: "CheckIntegrity", Matched: 1},
+		}, changes)
+		assert.Len(t, reports, 1)
+
+		tester.Refresh(selection)
+		assert.Len(t, selection.Posts, 2)
+
+		err = m.Run(tester.Store, &buf, nil)
+		assert.NoError(t, err)
+		assert.Len(t, reports, 2)
+		assert.Equal(t, int64(1), reports[1].Repaired)
+
+		tester.Refresh(selection)
+		assert.Equal(t, []ID{post.ID()}, selection.Posts)
+
+		n, m2, err := IntegrityMigrator(models, false, nil)(context.Background(), tester.Store)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), n)
+		assert.Equal(t, int64(0), m2)
+	})
+}
@@ -0,0 +1,280 @@
+package coal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/stick"
+)
+
+// MigrationState defines the states of a migration.
+type MigrationState string
+
+// The available migration states.
+const (
+	MigrationPending   MigrationState = "pending"
+	MigrationRunning   MigrationState = "running"
+	MigrationCompleted MigrationState = "completed"
+	MigrationFailed    MigrationState = "failed"
+)
+
+func init() {
+	// add indexes
+	AddIndex(&MigrationRecord{}, true, 0, "Name")
+}
+
+// MigrationRecord is stored in the migration ledger to track the execution of
+// a migration.
+type MigrationRecord struct {
+	Base `json:"-" bson:",inline" coal:"migrations"`
+
+	// The migration name.
+	Name string `json:"name"`
+
+	// The migration checksum.
+	Checksum string `json:"checksum"`
+
+	// The current state of the migration.
+	State MigrationState `json:"state"`
+
+	// The time when the last execution started.
+	Started *time.Time `json:"started-at" bson:"started_at"`
+
+	// The time when the running execution last reported progress.
+	Heartbeat *time.Time `json:"heartbeat-at" bson:"heartbeat_at"`
+
+	// The time when the last execution finished.
+	Finished *time.Time `json:"finished-at" bson:"finished_at"`
+
+	// The counts reported by the last execution.
+	Matched  int64 `json:"matched"`
+	Modified int64 `json:"modified"`
+
+	// The error of the last failed execution.
+	Error string `json:"error"`
+
+	// The checkpoint saved by a resumable migration.
+	Checkpoint string `json:"checkpoint"`
+
+	stick.NoValidation `json:"-" bson:"-"`
+}
+
+type checkpointKey struct{}
+
+type checkpointState struct {
+	store  *Store
+	record *MigrationRecord
+}
+
+// GetCheckpoint returns the checkpoint that has been saved by a previous
+// execution of the currently running migration. It returns an empty string if
+// no checkpoint has been saved or the migrator does not use a ledger.
+func GetCheckpoint(ctx context.Context) string {
+	// get state
+	state, _ := ctx.Value(checkpointKey{}).(*checkpointState)
+	if state == nil {
+		return ""
+	}
+
+	return state.record.Checkpoint
+}
+
+// SetCheckpoint will persist the provided checkpoint for the currently running
+// migration and renew its lock. An interrupted migration may use the
+// checkpoint to resume its work when it is run again. The function is a no-op
+// if the migrator does not use a ledger.
+func SetCheckpoint(ctx context.Context, checkpoint string) error {
+	// get state
+	state, _ := ctx.Value(checkpointKey{}).(*checkpointState)
+	if state == nil {
+		return nil
+	}
+
+	// update record
+	now := time.Now()
+	found, err := state.store.M(state.record).Update(ctx, state.record, state.record.ID(), bson.M{
+		"$set": bson.M{
+			"Checkpoint": checkpoint,
+			"Heartbeat":  now,
+		},
+	}, false)
+	if err != nil {
+		return err
+	} else if !found {
+		return xo.F("missing migration record")
+	}
+
+	return nil
+}
+
+// Status returns the ledger records of all added migrations in the order they
+// have been added. Migrations that have never been run are reported as pending.
+func (m *Migrator) Status(ctx context.Context, store *Store) ([]MigrationRecord, error) {
+	// find records
+	var records []MigrationRecord
+	err := store.M(&MigrationRecord{}).FindAll(ctx, &records, bson.M{}, nil, 0, 0, false, NoTransaction)
+	if err != nil {
+		return nil, err
+	}
+
+	// index records
+	index := map[string]MigrationRecord{}
+	for _, record := range records {
+		index[record.Name] = record
+	}
+
+	// prepare list
+	list := make([]MigrationRecord, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		record, ok := index[migration.Name]
+		if !ok {
+			record = MigrationRecord{
+				Name:     migration.Name,
+				Checksum: migration.Checksum,
+				State:    MigrationPending,
+			}
+		}
+		list = append(list, record)
+	}
+
+	return list, nil
+}
+
+// Report will write a table with the status of all added migrations to the
+// provided writer.
+func (m *Migrator) Report(ctx context.Context, store *Store, w io.Writer) error {
+	// get status
+	list, err := m.Status(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	// write table
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tSTATE\tSTARTED\tFINISHED\tMATCHED\tMODIFIED\tERROR")
+	for _, record := range list {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", record.Name, record.State, formatTime(record.Started), formatTime(record.Finished), record.Matched, record.Modified, record.Error)
+	}
+
+	return tw.Flush()
+}
+
+func (m *Migrator) claim(ctx context.Context, store *Store, migration *Migration) (*MigrationRecord, bool, error) {
+	// get checksum
+	checksum := migration.Checksum
+
+	for {
+		// ensure record
+		record := &MigrationRecord{
+			Base:     B(),
+			Name:     migration.Name,
+			Checksum: checksum,
+			State:    MigrationPending,
+		}
+		_, err := store.M(record).InsertIfMissing(ctx, bson.M{
+			"Name": migration.Name,
+		}, record, false, NoTransaction)
+		if err != nil && !IsDuplicate(err) {
+			return nil, false, err
+		}
+
+		// load record
+		found, err := store.M(record).FindFirst(ctx, record, bson.M{
+			"Name": migration.Name,
+		}, nil, 0, false, NoTransaction)
+		if err != nil {
+			return nil, false, err
+		} else if !found {
+			return nil, false, xo.F("missing migration record")
+		}
+
+		// skip completed migrations with a matching checksum
+		if record.State == MigrationCompleted && record.Checksum == checksum {
+			return nil, false, nil
+		}
+
+		// check lock
+		now := time.Now()
+		if record.State == MigrationRunning && record.Heartbeat != nil && now.Sub(*record.Heartbeat) < migration.Timeout {
+			// skip asynchronous migrations running elsewhere
+			if migration.Async {
+				return nil, false, nil
+			}
+
+			// otherwise, wait for the other run to finish
+			select {
+			case <-time.After(m.interval):
+				continue
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			}
+		}
+
+		// claim record
+		filter := bson.M{
+			"_id":       record.ID(),
+			"State":     record.State,
+			"Heartbeat": record.Heartbeat,
+		}
+		found, err = store.M(record).UpdateFirst(ctx, record, filter, bson.M{
+			"$set": bson.M{
+				"Checksum":  checksum,
+				"State":     MigrationRunning,
+				"Started":   now,
+				"Heartbeat": now,
+				"Finished":  nil,
+				"Error":     "",
+			},
+		}, nil, false)
+		if err != nil {
+			return nil, false, err
+		} else if !found {
+			continue
+		}
+
+		return record, true, nil
+	}
+}
+
+func (m *Migrator) finish(store *Store, record *MigrationRecord, matched, modified int64, err error) error {
+	// prepare context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// prepare update
+	now := time.Now()
+	update := bson.M{
+		"State":     MigrationCompleted,
+		"Heartbeat": now,
+		"Finished":  now,
+		"Matched":   matched,
+		"Modified":  modified,
+		"Error":     "",
+	}
+	if err != nil {
+		update["State"] = MigrationFailed
+		update["Error"] = err.Error()
+	}
+
+	// update record
+	_, err = store.M(record).Update(ctx, record, record.ID(), bson.M{
+		"$set": update,
+	}, false)
+
+	return err
+}
+
+func formatTime(t *time.Time) string {
+	// check time
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/256dpi/xo"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/256dpi/fire/smoke"
 )
 
 // TODO: Validate updates before writing.
@@ -26,6 +28,14 @@ const (
 	// TextScoreSort will prepend the sort with a sort based on the text score
 	// of documents. The Base.Score attribute is set to the respective score.
 	TextScoreSort
+
+	// SecondaryPreferred will serve read operations from secondaries if
+	// available. See WithReadPreference for details.
+	SecondaryPreferred
+
+	// NoHooks will skip the invocation of the BeforeInsert, BeforeUpdate and
+	// AfterLoad model hooks.
+	NoHooks
 )
 
 // Has returns whether the receiver has set all provided flags.
@@ -50,9 +60,12 @@ var ErrTransactionRequired = xo.BF("operation requires a transaction")
 // that is manged by the manager.
 var ErrMetaMismatch = xo.BF("provided model does not match managed model")
 
+var modelInterface = reflect.TypeOf((*Model)(nil)).Elem()
+
+// the lock is incremented using a long to match the type of the base field
 var incrementLock = bson.M{
 	"$inc": bson.M{
-		"_lk": 1,
+		"_lk": int64(1),
 	},
 }
 
@@ -66,10 +79,20 @@ var returnAfterUpdate = options.FindOneAndUpdate().SetReturnDocument(options.Aft
 // operations and ensure that they are safe under the MongoDB guarantees.
 type Manager struct {
 	meta  *Meta
+	store *Store
 	coll  *Collection
 	trans *Translator
 }
 
+// OperationsMetric counts the operations performed by managers by collection
+// and operation.
+const OperationsMetric = "coal_operations_total"
+
+// OperationDurationMetric observes the duration of operations performed by
+// managers in seconds by collection and operation. For FindEach the duration
+// includes the iteration until the iterator is closed.
+const OperationDurationMetric = "coal_operation_duration_seconds"
+
 // C is a shorthand to access the underlying collection.
 func (m *Manager) C() *Collection {
 	return m.coll
@@ -90,6 +113,12 @@ func (m *Manager) Find(ctx context.Context, model Model, id ID, lock bool, flags
 	ctx, span := xo.Trace(ctx, "coal/Manager.Find")
 	span.Tag("id", id)
 	defer span.End()
+	defer m.measure("Find")()
+
+	// check support
+	if err := m.supports(ctx, "Find", lock, nil); err != nil {
+		return false, err
+	}
 
 	// check lock
 	if lock && !HasTransaction(ctx) {
@@ -116,7 +145,7 @@ func (m *Manager) Find(ctx context.Context, model Model, id ID, lock bool, flags
 	if lock {
 		err = m.coll.FindOneAndUpdate(ctx, filter, incrementLock, returnAfterUpdate).Decode(model)
 	} else {
-		err = m.coll.FindOne(ctx, filter).Decode(model)
+		err = m.reader(ctx, flags).FindOne(ctx, filter).Decode(model)
 	}
 	if IsMissing(err) {
 		return false, nil
@@ -124,6 +153,12 @@ func (m *Manager) Find(ctx context.Context, model Model, id ID, lock bool, flags
 		return false, err
 	}
 
+	// run hook
+	err = runAfterLoad(ctx, model, flags)
+	if err != nil {
+		return false, err
+	}
+
 	// validate model
 	if !Merge(flags).Has(NoValidation) {
 		err = model.Validate()
@@ -148,6 +183,12 @@ func (m *Manager) FindFirst(ctx context.Context, model Model, filter bson.M, sor
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.FindFirst")
 	defer span.End()
+	defer m.measure("FindFirst")()
+
+	// check support
+	if err := m.supports(ctx, "FindFirst", lock, filter); err != nil {
+		return false, err
+	}
 
 	// check lock
 	if lock && !HasTransaction(ctx) {
@@ -187,7 +228,7 @@ func (m *Manager) FindFirst(ctx context.Context, model Model, filter bson.M, sor
 	// find document
 	if lock {
 		// prepare options
-		opts := options.FindOneAndUpdate()
+		opts := options.FindOneAndUpdate().SetCollation(m.collation(ctx))
 		if sortDoc != nil {
 			opts.SetSort(sortDoc)
 		}
@@ -196,7 +237,7 @@ func (m *Manager) FindFirst(ctx context.Context, model Model, filter bson.M, sor
 		err = m.coll.FindOneAndUpdate(ctx, filterDoc, incrementLock, returnAfterUpdate, opts).Decode(model)
 	} else {
 		// prepare options
-		opts := options.FindOne()
+		opts := options.FindOne().SetCollation(m.collation(ctx))
 		if sortDoc != nil {
 			opts.SetSort(sortDoc)
 		}
@@ -205,7 +246,7 @@ func (m *Manager) FindFirst(ctx context.Context, model Model, filter bson.M, sor
 		}
 
 		// find
-		err = m.coll.FindOne(ctx, filterDoc, opts).Decode(model)
+		err = m.reader(ctx, flags).FindOne(ctx, filterDoc, opts).Decode(model)
 	}
 	if IsMissing(err) {
 		return false, nil
@@ -213,6 +254,12 @@ func (m *Manager) FindFirst(ctx context.Context, model Model, filter bson.M, sor
 		return false, err
 	}
 
+	// run hook
+	err = runAfterLoad(ctx, model, flags)
+	if err != nil {
+		return false, err
+	}
+
 	// validate model
 	if !Merge(flags).Has(NoValidation) {
 		err = model.Validate()
@@ -236,6 +283,12 @@ func (m *Manager) FindAll(ctx context.Context, list interface{}, filter bson.M,
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.FindAll")
 	defer span.End()
+	defer m.measure("FindAll")()
+
+	// check support
+	if err := m.supports(ctx, "FindAll", lock, filter); err != nil {
+		return err
+	}
 
 	// check list
 	if list == nil {
@@ -251,7 +304,7 @@ func (m *Manager) FindAll(ctx context.Context, list interface{}, filter bson.M,
 	}
 
 	// require transaction if locked or not unsafe
-	if (lock || !Merge(flags).Has(NoTransaction)) && !HasTransaction(ctx) {
+	if requiresTransaction(ctx, lock, flags) {
 		return ErrTransactionRequired.Wrap()
 	}
 
@@ -267,7 +320,7 @@ func (m *Manager) FindAll(ctx context.Context, list interface{}, filter bson.M,
 	}
 
 	// prepare options
-	opts := options.Find()
+	opts := options.Find().SetCollation(m.collation(ctx))
 
 	// set sort
 	if len(sort) > 0 {
@@ -304,14 +357,14 @@ func (m *Manager) FindAll(ctx context.Context, list interface{}, filter bson.M,
 
 	// lock documents
 	if lock {
-		_, err = m.coll.UpdateMany(ctx, filterDoc, incrementLock)
+		_, err = m.coll.UpdateMany(ctx, filterDoc, incrementLock, options.Update().SetCollation(m.collation(ctx)))
 		if err != nil {
 			return err
 		}
 	}
 
 	// find documents
-	iter, err := m.coll.Find(ctx, filterDoc, opts)
+	iter, err := m.reader(ctx, flags).Find(ctx, filterDoc, opts)
 	if err != nil {
 		return err
 	}
@@ -322,6 +375,14 @@ func (m *Manager) FindAll(ctx context.Context, list interface{}, filter bson.M,
 		return err
 	}
 
+	// run hooks
+	for _, model := range Slice(list) {
+		err = runAfterLoad(ctx, model, flags)
+		if err != nil {
+			return err
+		}
+	}
+
 	// validate models
 	if !Merge(flags).Has(NoValidation) {
 		for _, model := range Slice(list) {
@@ -346,17 +407,25 @@ func (m *Manager) FindAll(ctx context.Context, list interface{}, filter bson.M,
 func (m *Manager) FindEach(ctx context.Context, filter bson.M, sort []string, skip, limit int64, lock bool, flags ...Flags) (*ManagedIterator, error) {
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.FindEach")
+	measure := m.measure("FindEach")
 
-	// finish span on error
+	// check support
+	if err := m.supports(ctx, "FindEach", lock, filter); err != nil {
+		measure()
+		return nil, err
+	}
+
+	// finish span and measurement on error
 	var iter *Iterator
 	defer func() {
 		if iter == nil {
 			span.End()
+			measure()
 		}
 	}()
 
 	// require transaction if locked or not unsafe
-	if (lock || !Merge(flags).Has(NoTransaction)) && !HasTransaction(ctx) {
+	if requiresTransaction(ctx, lock, flags) {
 		return nil, ErrTransactionRequired.Wrap()
 	}
 
@@ -372,7 +441,7 @@ func (m *Manager) FindEach(ctx context.Context, filter bson.M, sort []string, sk
 	}
 
 	// prepare options
-	opts := options.Find()
+	opts := options.Find().SetCollation(m.collation(ctx))
 
 	// set sort
 	if len(sort) > 0 {
@@ -394,28 +463,31 @@ func (m *Manager) FindEach(ctx context.Context, filter bson.M, sort []string, sk
 
 	// lock documents
 	if lock {
-		_, err = m.coll.UpdateMany(ctx, filterDoc, incrementLock)
+		_, err = m.coll.UpdateMany(ctx, filterDoc, incrementLock, options.Update().SetCollation(m.collation(ctx)))
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// find documents
-	iter, err = m.coll.Find(ctx, filterDoc, opts)
+	iter, err = m.reader(ctx, flags).Find(ctx, filterDoc, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// attach span
+	// attach span and measure until the iterator is closed
 	iter.spans = append(iter.spans, span)
+	iter.hooks = append(iter.hooks, measure)
 
 	// determine validation
 	validate := !Merge(flags).Has(NoValidation)
 
 	return &ManagedIterator{
+		ctx:      ctx,
 		meta:     m.meta,
 		iterator: iter,
 		validate: validate,
+		flags:    flags,
 	}, nil
 }
 
@@ -427,6 +499,7 @@ func (m *Manager) Project(ctx context.Context, id ID, field string, lock bool) (
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.Project")
 	defer span.End()
+	defer m.measure("Project")()
 
 	// project
 	var res interface{}
@@ -456,6 +529,7 @@ func (m *Manager) ProjectFirst(ctx context.Context, filter bson.M, field string,
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.ProjectFirst")
 	defer span.End()
+	defer m.measure("ProjectFirst")()
 
 	// project
 	var res interface{}
@@ -483,6 +557,7 @@ func (m *Manager) ProjectAll(ctx context.Context, filter bson.M, field string, s
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.ProjectAll")
 	defer span.End()
+	defer m.measure("ProjectAll")()
 
 	// project
 	res := make(map[ID]interface{})
@@ -509,13 +584,19 @@ func (m *Manager) ProjectEach(ctx context.Context, filter bson.M, field string,
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.ProjectEach")
 	defer span.End()
+	defer m.measure("ProjectEach")()
 
 	return m.project(ctx, filter, field, sort, skip, limit, lock, fn, flags...)
 }
 
 func (m *Manager) project(ctx context.Context, filter bson.M, field string, sort []string, skip, limit int64, lock bool, fn func(id ID, val interface{}) bool, flags ...Flags) error {
+	// check support
+	if err := m.supports(ctx, "Project", lock, filter); err != nil {
+		return err
+	}
+
 	// require transaction if locked or not unsafe
-	if (lock || !Merge(flags).Has(NoTransaction)) && !HasTransaction(ctx) {
+	if requiresTransaction(ctx, lock, flags) {
 		return ErrTransactionRequired.Wrap()
 	}
 
@@ -537,7 +618,7 @@ func (m *Manager) project(ctx context.Context, filter bson.M, field string, sort
 	}
 
 	// prepare options
-	opts := options.Find()
+	opts := options.Find().SetCollation(m.collation(ctx))
 
 	// set sort
 	if len(sort) > 0 {
@@ -564,14 +645,14 @@ func (m *Manager) project(ctx context.Context, filter bson.M, field string, sort
 
 	// lock documents
 	if lock {
-		_, err = m.coll.UpdateMany(ctx, filterDoc, incrementLock)
+		_, err = m.coll.UpdateMany(ctx, filterDoc, incrementLock, options.Update().SetCollation(m.collation(ctx)))
 		if err != nil {
 			return err
 		}
 	}
 
 	// find documents
-	iter, err := m.coll.Find(ctx, filterDoc, opts)
+	iter, err := m.reader(ctx, flags).Find(ctx, filterDoc, opts)
 	if err != nil {
 		return err
 	}
@@ -615,9 +696,15 @@ func (m *Manager) Count(ctx context.Context, filter bson.M, skip, limit int64, l
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.Count")
 	defer span.End()
+	defer m.measure("Count")()
+
+	// check support
+	if err := m.supports(ctx, "Count", lock, filter); err != nil {
+		return 0, err
+	}
 
 	// require transaction if locked or not unsafe
-	if (lock || !Merge(flags).Has(NoTransaction)) && !HasTransaction(ctx) {
+	if requiresTransaction(ctx, lock, flags) {
 		return 0, ErrTransactionRequired.Wrap()
 	}
 
@@ -633,7 +720,7 @@ func (m *Manager) Count(ctx context.Context, filter bson.M, skip, limit int64, l
 	}
 
 	// prepare options
-	opts := options.Count()
+	opts := options.Count().SetCollation(m.collation(ctx))
 
 	// set skip
 	if skip > 0 {
@@ -647,7 +734,7 @@ func (m *Manager) Count(ctx context.Context, filter bson.M, skip, limit int64, l
 
 	// update if locked
 	if lock {
-		res, err := m.coll.UpdateMany(ctx, filterDoc, incrementLock)
+		res, err := m.coll.UpdateMany(ctx, filterDoc, incrementLock, options.Update().SetCollation(m.collation(ctx)))
 		if err != nil {
 			return 0, err
 		}
@@ -656,7 +743,7 @@ func (m *Manager) Count(ctx context.Context, filter bson.M, skip, limit int64, l
 	}
 
 	// count documents
-	count, err := m.coll.CountDocuments(ctx, filterDoc, opts)
+	count, err := m.reader(ctx, flags).CountDocuments(ctx, filterDoc, opts)
 	if err != nil {
 		return 0, err
 	}
@@ -676,9 +763,15 @@ func (m *Manager) Distinct(ctx context.Context, field string, filter bson.M, loc
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.Distinct")
 	defer span.End()
+	defer m.measure("Distinct")()
+
+	// check support
+	if err := m.supports(ctx, "Distinct", lock, filter); err != nil {
+		return nil, err
+	}
 
 	// require transaction if locked or not unsafe
-	if (lock || !Merge(flags).Has(NoTransaction)) && !HasTransaction(ctx) {
+	if requiresTransaction(ctx, lock, flags) {
 		return nil, ErrTransactionRequired.Wrap()
 	}
 
@@ -696,14 +789,14 @@ func (m *Manager) Distinct(ctx context.Context, field string, filter bson.M, loc
 
 	// lock documents
 	if lock {
-		_, err = m.coll.UpdateMany(ctx, filterDoc, incrementLock)
+		_, err = m.coll.UpdateMany(ctx, filterDoc, incrementLock, options.Update().SetCollation(m.collation(ctx)))
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// distinct
-	result, err := m.coll.Distinct(ctx, field, filterDoc)
+	result, err := m.reader(ctx, flags).Distinct(ctx, field, filterDoc, options.Distinct().SetCollation(m.collation(ctx)))
 	if err != nil {
 		return nil, err
 	}
@@ -711,12 +804,111 @@ func (m *Manager) Distinct(ctx context.Context, field string, filter bson.M, loc
 	return result, nil
 }
 
+// Aggregate will run the provided aggregation pipeline and decode the results
+// into the provided list. The list may be a slice of models or arbitrary
+// structs. Field names are translated as described by Translator.Pipeline.
+// Lock can be set to true to force a write lock on the documents matched by a
+// leading "$match" stage and prevent a stale read during a transaction.
+//
+// A transaction is required to ensure isolation.
+//
+// NoTransaction: The result may miss documents or include them multiple times
+// if interleaving operations move the documents in the used index.
+func (m *Manager) Aggregate(ctx context.Context, list interface{}, pipeline []bson.M, lock bool, flags ...Flags) error {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Manager.Aggregate")
+	defer span.End()
+	defer m.measure("Aggregate")()
+
+	// check support
+	if err := m.supports(ctx, "Aggregate", lock, nil); err != nil {
+		return err
+	}
+
+	// check support
+	if m.store.Lungo() {
+		panic("coal: not supported by lungo")
+	}
+
+	// check list
+	if list == nil {
+		return xo.F("missing list")
+	}
+	lt := reflect.TypeOf(list)
+	if lt.Kind() != reflect.Ptr || lt.Elem().Kind() != reflect.Slice {
+		return xo.F("expected slice pointer")
+	}
+
+	// require transaction if locked or not unsafe
+	if requiresTransaction(ctx, lock, flags) {
+		return ErrTransactionRequired.Wrap()
+	}
+
+	// translate pipeline
+	pipelineDoc, err := m.trans.Pipeline(pipeline)
+	if err != nil {
+		return err
+	}
+
+	// lock documents
+	if lock {
+		// get filter
+		filterDoc := bson.D{}
+		if len(pipeline) > 0 && pipeline[0]["$match"] != nil {
+			filterDoc = pipelineDoc[0].(bson.D)[0].Value.(bson.D)
+		}
+
+		// increment lock
+		_, err = m.coll.UpdateMany(ctx, filterDoc, incrementLock, options.Update().SetCollation(m.collation(ctx)))
+		if err != nil {
+			return err
+		}
+	}
+
+	// aggregate documents
+	iter, err := m.reader(ctx, flags).Aggregate(ctx, pipelineDoc, options.Aggregate().SetCollation(m.collation(ctx)))
+	if err != nil {
+		return err
+	}
+
+	// decode all
+	err = iter.All(list)
+	if err != nil {
+		return err
+	}
+
+	// check if models
+	et := lt.Elem().Elem()
+	if et.Kind() == reflect.Struct {
+		et = reflect.PtrTo(et)
+	}
+	models := et.Implements(modelInterface)
+
+	// validate models
+	if models && !Merge(flags).Has(NoValidation) {
+		for _, model := range Slice(list) {
+			err = model.Validate()
+			if err != nil {
+				return xo.W(err)
+			}
+		}
+	}
+
+	return nil
+}
+
 // Insert will insert the provided document. If the document has a zero id a new
 // id will be generated and assigned.
 func (m *Manager) Insert(ctx context.Context, models Model, flags ...Flags) error {
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.Insert")
 	defer span.End()
+	defer m.measure("Insert")()
+
+	// check support
+	if err := m.supports(ctx, "Insert", false, nil); err != nil {
+		return err
+	}
 
 	return m.insert(ctx, []Model{models}, flags...)
 }
@@ -728,6 +920,12 @@ func (m *Manager) InsertAll(ctx context.Context, models []Model, flags ...Flags)
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.InsertAll")
 	defer span.End()
+	defer m.measure("InsertAll")()
+
+	// check support
+	if err := m.supports(ctx, "InsertAll", false, nil); err != nil {
+		return err
+	}
 
 	return m.insert(ctx, models, flags...)
 }
@@ -749,6 +947,17 @@ func (m *Manager) insert(ctx context.Context, models []Model, flags ...Flags) er
 		if model.ID() == "" {
 			model.GetBase().DocID = New()
 		}
+
+		// ensure version
+		if m.meta.Versioned && model.GetBase().Version == 0 {
+			model.GetBase().Version = 1
+		}
+
+		// run hook
+		err := runBeforeInsert(ctx, model, flags)
+		if err != nil {
+			return err
+		}
 	}
 
 	// validate models
@@ -798,6 +1007,12 @@ func (m *Manager) InsertIfMissing(ctx context.Context, filter bson.M, model Mode
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.InsertIfMissing")
 	defer span.End()
+	defer m.measure("InsertIfMissing")()
+
+	// check support
+	if err := m.supports(ctx, "InsertIfMissing", lock, filter); err != nil {
+		return false, err
+	}
 
 	// require transaction
 	if lock && !HasTransaction(ctx) {
@@ -820,6 +1035,17 @@ func (m *Manager) InsertIfMissing(ctx context.Context, filter bson.M, model Mode
 		model.GetBase().DocID = New()
 	}
 
+	// ensure version
+	if m.meta.Versioned && model.GetBase().Version == 0 {
+		model.GetBase().Version = 1
+	}
+
+	// run hook
+	err = runBeforeInsert(ctx, model, flags)
+	if err != nil {
+		return false, err
+	}
+
 	// validate model
 	if !Merge(flags).Has(NoValidation) {
 		err = model.Validate()
@@ -829,7 +1055,7 @@ func (m *Manager) InsertIfMissing(ctx context.Context, filter bson.M, model Mode
 	}
 
 	// prepare options
-	opts := options.Update().SetUpsert(true)
+	opts := options.Update().SetUpsert(true).SetCollation(m.collation(ctx))
 
 	// prepare update
 	update := bson.M{
@@ -839,7 +1065,7 @@ func (m *Manager) InsertIfMissing(ctx context.Context, filter bson.M, model Mode
 	// increment lock
 	if lock {
 		update["$inc"] = bson.M{
-			"_lk": 1,
+			"_lk": int64(1),
 		}
 	}
 
@@ -857,11 +1083,20 @@ func (m *Manager) InsertIfMissing(ctx context.Context, filter bson.M, model Mode
 // write lock on the document and prevent a stale read during a transaction in
 // case the replace did not change the document.
 //
+// For versioned models the document is only replaced if its version matches
+// the version of the provided model. ErrConflict is returned otherwise.
+//
 // A transaction is required for locking.
 func (m *Manager) Replace(ctx context.Context, model Model, lock bool, flags ...Flags) (bool, error) {
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.Replace")
 	defer span.End()
+	defer m.measure("Replace")()
+
+	// check support
+	if err := m.supports(ctx, "Replace", lock, nil); err != nil {
+		return false, err
+	}
 
 	// check model
 	if GetMeta(model) != m.meta {
@@ -878,9 +1113,15 @@ func (m *Manager) Replace(ctx context.Context, model Model, lock bool, flags ...
 		return false, ErrTransactionRequired.Wrap()
 	}
 
+	// run hook
+	err := runBeforeUpdate(ctx, model, nil, flags)
+	if err != nil {
+		return false, err
+	}
+
 	// validate model
 	if !Merge(flags).Has(NoValidation) {
-		err := model.Validate()
+		err = model.Validate()
 		if err != nil {
 			return false, xo.W(err)
 		}
@@ -892,14 +1133,9 @@ func (m *Manager) Replace(ctx context.Context, model Model, lock bool, flags ...
 	}
 
 	// replace document
-	res, err := m.coll.ReplaceOne(ctx, bson.M{
-		"_id": model.ID(),
-	}, model)
-	if err != nil {
-		return false, err
-	}
-
-	return res.MatchedCount == 1, nil
+	return m.replace(ctx, bson.D{
+		{Key: "_id", Value: model.ID()},
+	}, model, options.Replace())
 }
 
 // ReplaceFirst will replace the first document that matches the specified filter.
@@ -907,6 +1143,9 @@ func (m *Manager) Replace(ctx context.Context, model Model, lock bool, flags ...
 // force a write lock on the document and prevent a stale read during a
 // transaction if the replace did not cause an update.
 //
+// For versioned models the document is only replaced if its version matches
+// the version of the provided model. ErrConflict is returned otherwise.
+//
 // A transaction is required for locking.
 //
 // Warning: If the operation depends on interleaving writes to not include or
@@ -915,6 +1154,12 @@ func (m *Manager) ReplaceFirst(ctx context.Context, filter bson.M, model Model,
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.ReplaceFirst")
 	defer span.End()
+	defer m.measure("ReplaceFirst")()
+
+	// check support
+	if err := m.supports(ctx, "ReplaceFirst", lock, filter); err != nil {
+		return false, err
+	}
 
 	// check model
 	if GetMeta(model) != m.meta {
@@ -926,9 +1171,15 @@ func (m *Manager) ReplaceFirst(ctx context.Context, filter bson.M, model Model,
 		return false, ErrTransactionRequired.Wrap()
 	}
 
+	// run hook
+	err := runBeforeUpdate(ctx, model, nil, flags)
+	if err != nil {
+		return false, err
+	}
+
 	// validate model
 	if !Merge(flags).Has(NoValidation) {
-		err := model.Validate()
+		err = model.Validate()
 		if err != nil {
 			return false, xo.W(err)
 		}
@@ -946,12 +1197,7 @@ func (m *Manager) ReplaceFirst(ctx context.Context, filter bson.M, model Model,
 	}
 
 	// replace document
-	res, err := m.coll.ReplaceOne(ctx, filterDoc, model)
-	if err != nil {
-		return false, err
-	}
-
-	return res.MatchedCount == 1, nil
+	return m.replace(ctx, filterDoc, model, options.Replace().SetCollation(m.collation(ctx)))
 }
 
 // Update will update the document with the specified id. It will return whether
@@ -959,11 +1205,22 @@ func (m *Manager) ReplaceFirst(ctx context.Context, filter bson.M, model Model,
 // the document and prevent a stale read during a transaction in case the
 // update did not change the document.
 //
+// For versioned models the document is only updated if its version matches
+// the non-zero version of the provided model. ErrConflict is returned
+// otherwise. The version is not checked if the model is nil or has a zero
+// version.
+//
 // A transaction is required for locking.
-func (m *Manager) Update(ctx context.Context, model Model, id ID, update bson.M, lock bool) (bool, error) {
+func (m *Manager) Update(ctx context.Context, model Model, id ID, update bson.M, lock bool, flags ...Flags) (bool, error) {
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.Update")
 	defer span.End()
+	defer m.measure("Update")()
+
+	// check support
+	if err := m.supports(ctx, "Update", lock, nil); err != nil {
+		return false, err
+	}
 
 	// require transaction
 	if lock && !HasTransaction(ctx) {
@@ -980,6 +1237,15 @@ func (m *Manager) Update(ctx context.Context, model Model, id ID, update bson.M,
 		return false, ErrMetaMismatch.Wrap()
 	}
 
+	// run hook
+	if update == nil {
+		update = bson.M{}
+	}
+	err := runBeforeUpdate(ctx, model, update, flags)
+	if err != nil {
+		return false, err
+	}
+
 	// translate update
 	updateDoc, err := m.trans.Document(update)
 	if err != nil {
@@ -988,23 +1254,58 @@ func (m *Manager) Update(ctx context.Context, model Model, id ID, update bson.M,
 
 	// increment lock
 	if lock {
-		_, err := bsonkit.Put(&updateDoc, "$inc._lk", 1, false)
+		_, err := bsonkit.Put(&updateDoc, "$inc._lk", int64(1), false)
 		if err != nil {
 			return false, xo.WF(err, "unable to add lock")
 		}
 	}
 
+	// increment version
+	if m.meta.Versioned {
+		_, err := bsonkit.Put(&updateDoc, "$inc._v", int64(1), false)
+		if err != nil {
+			return false, xo.WF(err, "unable to add version")
+		}
+	}
+
+	// prepare filter
+	filter := bson.M{
+		"_id": id,
+	}
+
+	// check version
+	version := model.GetBase().Version
+	if m.meta.Versioned && version > 0 {
+		filter["_v"] = version
+	}
+
 	// find and update document
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	err = m.coll.FindOneAndUpdate(ctx, bson.M{
-		"_id": id,
-	}, updateDoc, opts).Decode(model)
-	if IsMissing(err) {
+	err = m.coll.FindOneAndUpdate(ctx, filter, updateDoc, opts).Decode(model)
+	if IsMissing(err) && filter["_v"] != nil {
+		// check document
+		ok, err := m.exists(ctx, bson.M{
+			"_id": id,
+		})
+		if err != nil {
+			return false, err
+		} else if ok {
+			return false, ErrConflict.WrapF("version %d of %s is outdated", version, id)
+		}
+
+		return false, nil
+	} else if IsMissing(err) {
 		return false, nil
 	} else if err != nil {
 		return false, err
 	}
 
+	// run hook
+	err = runAfterLoad(ctx, model, flags)
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
@@ -1017,10 +1318,16 @@ func (m *Manager) Update(ctx context.Context, model Model, id ID, update bson.M,
 //
 // Warning: If the operation depends on interleaving writes to not include or
 // exclude documents from the filter it should be run as part of a transaction.
-func (m *Manager) UpdateFirst(ctx context.Context, model Model, filter, update bson.M, sort []string, lock bool) (bool, error) {
+func (m *Manager) UpdateFirst(ctx context.Context, model Model, filter, update bson.M, sort []string, lock bool, flags ...Flags) (bool, error) {
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.UpdateFirst")
 	defer span.End()
+	defer m.measure("UpdateFirst")()
+
+	// check support
+	if err := m.supports(ctx, "UpdateFirst", lock, filter); err != nil {
+		return false, err
+	}
 
 	// require transaction
 	if lock && !HasTransaction(ctx) {
@@ -1037,6 +1344,15 @@ func (m *Manager) UpdateFirst(ctx context.Context, model Model, filter, update b
 		return false, ErrMetaMismatch.Wrap()
 	}
 
+	// run hook
+	if update == nil {
+		update = bson.M{}
+	}
+	err := runBeforeUpdate(ctx, model, update, flags)
+	if err != nil {
+		return false, err
+	}
+
 	// translate filter
 	filterDoc, err := m.trans.Document(filter)
 	if err != nil {
@@ -1050,7 +1366,7 @@ func (m *Manager) UpdateFirst(ctx context.Context, model Model, filter, update b
 	}
 
 	// prepare options
-	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetCollation(m.collation(ctx))
 
 	// set sort
 	if len(sort) > 0 {
@@ -1062,12 +1378,20 @@ func (m *Manager) UpdateFirst(ctx context.Context, model Model, filter, update b
 
 	// increment lock
 	if lock {
-		_, err := bsonkit.Put(&updateDoc, "$inc._lk", 1, false)
+		_, err := bsonkit.Put(&updateDoc, "$inc._lk", int64(1), false)
 		if err != nil {
 			return false, xo.WF(err, "unable to add lock")
 		}
 	}
 
+	// increment version
+	if m.meta.Versioned {
+		_, err := bsonkit.Put(&updateDoc, "$inc._v", int64(1), false)
+		if err != nil {
+			return false, xo.WF(err, "unable to add version")
+		}
+	}
+
 	// find and update document
 	err = m.coll.FindOneAndUpdate(ctx, filterDoc, updateDoc, opts).Decode(model)
 	if IsMissing(err) {
@@ -1076,6 +1400,12 @@ func (m *Manager) UpdateFirst(ctx context.Context, model Model, filter, update b
 		return false, err
 	}
 
+	// run hook
+	err = runAfterLoad(ctx, model, flags)
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
@@ -1088,16 +1418,31 @@ func (m *Manager) UpdateFirst(ctx context.Context, model Model, filter, update b
 //
 // Warning: If the operation depends on interleaving writes to not include or
 // exclude documents from the filter it should be run as part of a transaction.
-func (m *Manager) UpdateAll(ctx context.Context, filter, update bson.M, lock bool) (int64, error) {
+func (m *Manager) UpdateAll(ctx context.Context, filter, update bson.M, lock bool, flags ...Flags) (int64, error) {
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.UpdateAll")
 	defer span.End()
+	defer m.measure("UpdateAll")()
+
+	// check support
+	if err := m.supports(ctx, "UpdateAll", lock, filter); err != nil {
+		return 0, err
+	}
 
 	// require transaction
 	if lock && !HasTransaction(ctx) {
 		return 0, ErrTransactionRequired.Wrap()
 	}
 
+	// run hook
+	if update == nil {
+		update = bson.M{}
+	}
+	err := runBeforeUpdate(ctx, m.meta.Make(), update, flags)
+	if err != nil {
+		return 0, err
+	}
+
 	// translate filter
 	filterDoc, err := m.trans.Document(filter)
 	if err != nil {
@@ -1112,14 +1457,22 @@ func (m *Manager) UpdateAll(ctx context.Context, filter, update bson.M, lock boo
 
 	// increment lock
 	if lock {
-		_, err := bsonkit.Put(&updateDoc, "$inc._lk", 1, false)
+		_, err := bsonkit.Put(&updateDoc, "$inc._lk", int64(1), false)
 		if err != nil {
 			return 0, xo.WF(err, "unable to add lock")
 		}
 	}
 
+	// increment version
+	if m.meta.Versioned {
+		_, err := bsonkit.Put(&updateDoc, "$inc._v", int64(1), false)
+		if err != nil {
+			return 0, xo.WF(err, "unable to add version")
+		}
+	}
+
 	// update documents
-	res, err := m.coll.UpdateMany(ctx, filterDoc, updateDoc)
+	res, err := m.coll.UpdateMany(ctx, filterDoc, updateDoc, options.Update().SetCollation(m.collation(ctx)))
 	if err != nil {
 		return 0, err
 	}
@@ -1137,10 +1490,16 @@ func (m *Manager) UpdateAll(ctx context.Context, filter, update bson.M, lock boo
 //
 // Warning: Even with transactions there is a risk for duplicate inserts when
 // the filter is not covered by a unique index.
-func (m *Manager) Upsert(ctx context.Context, model Model, filter, update bson.M, sort []string, lock bool) (bool, error) {
+func (m *Manager) Upsert(ctx context.Context, model Model, filter, update bson.M, sort []string, lock bool, flags ...Flags) (bool, error) {
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.Upsert")
 	defer span.End()
+	defer m.measure("Upsert")()
+
+	// check support
+	if err := m.supports(ctx, "Upsert", lock, filter); err != nil {
+		return false, err
+	}
 
 	// require transaction
 	if lock && !HasTransaction(ctx) {
@@ -1157,6 +1516,15 @@ func (m *Manager) Upsert(ctx context.Context, model Model, filter, update bson.M
 		return false, ErrMetaMismatch.Wrap()
 	}
 
+	// run hook
+	if update == nil {
+		update = bson.M{}
+	}
+	err := runBeforeUpdate(ctx, model, update, flags)
+	if err != nil {
+		return false, err
+	}
+
 	// translate filter
 	filterDoc, err := m.trans.Document(filter)
 	if err != nil {
@@ -1171,14 +1539,22 @@ func (m *Manager) Upsert(ctx context.Context, model Model, filter, update bson.M
 
 	// increment lock
 	if lock {
-		_, err := bsonkit.Put(&updateDoc, "$inc._lk", 1, false)
+		_, err := bsonkit.Put(&updateDoc, "$inc._lk", int64(1), false)
 		if err != nil {
 			return false, xo.WF(err, "unable to add lock")
 		}
 	}
 
+	// increment version
+	if m.meta.Versioned {
+		_, err := bsonkit.Put(&updateDoc, "$inc._v", int64(1), false)
+		if err != nil {
+			return false, xo.WF(err, "unable to add version")
+		}
+	}
+
 	// prepare options
-	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After).SetCollation(m.collation(ctx))
 
 	// set sort
 	if len(sort) > 0 {
@@ -1203,6 +1579,12 @@ func (m *Manager) Upsert(ctx context.Context, model Model, filter, update bson.M
 		return false, err
 	}
 
+	// run hook
+	err = runAfterLoad(ctx, model, flags)
+	if err != nil {
+		return false, err
+	}
+
 	return model.GetBase().Token == token, nil
 }
 
@@ -1212,6 +1594,12 @@ func (m *Manager) Delete(ctx context.Context, model Model, id ID) (bool, error)
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.Delete")
 	defer span.End()
+	defer m.measure("Delete")()
+
+	// check support
+	if err := m.supports(ctx, "Delete", false, nil); err != nil {
+		return false, err
+	}
 
 	// delete document
 	if model == nil {
@@ -1252,6 +1640,12 @@ func (m *Manager) DeleteAll(ctx context.Context, filter bson.M) (int64, error) {
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.DeleteAll")
 	defer span.End()
+	defer m.measure("DeleteAll")()
+
+	// check support
+	if err := m.supports(ctx, "DeleteAll", false, filter); err != nil {
+		return 0, err
+	}
 
 	// translate filter
 	filterDoc, err := m.trans.Document(filter)
@@ -1260,7 +1654,7 @@ func (m *Manager) DeleteAll(ctx context.Context, filter bson.M) (int64, error) {
 	}
 
 	// update documents
-	res, err := m.coll.DeleteMany(ctx, filterDoc)
+	res, err := m.coll.DeleteMany(ctx, filterDoc, options.Delete().SetCollation(m.collation(ctx)))
 	if err != nil {
 		return 0, err
 	}
@@ -1277,6 +1671,12 @@ func (m *Manager) DeleteFirst(ctx context.Context, model Model, filter bson.M, s
 	// trace
 	ctx, span := xo.Trace(ctx, "coal/Manager.DeleteFirst")
 	defer span.End()
+	defer m.measure("DeleteFirst")()
+
+	// check support
+	if err := m.supports(ctx, "DeleteFirst", false, filter); err != nil {
+		return false, err
+	}
 
 	// translate filter
 	filterDoc, err := m.trans.Document(filter)
@@ -1295,7 +1695,7 @@ func (m *Manager) DeleteFirst(ctx context.Context, model Model, filter bson.M, s
 	}
 
 	// prepare options
-	opts := options.FindOneAndDelete()
+	opts := options.FindOneAndDelete().SetCollation(m.collation(ctx))
 
 	// set sort
 	if len(sort) > 0 {
@@ -1318,9 +1718,11 @@ func (m *Manager) DeleteFirst(ctx context.Context, model Model, filter bson.M, s
 
 // ManagedIterator wraps an iterator to enforce decoding to a model.
 type ManagedIterator struct {
+	ctx      context.Context
 	meta     *Meta
 	iterator *Iterator
 	validate bool
+	flags    []Flags
 }
 
 // Next will load the next document from the cursor and if available return true.
@@ -1343,6 +1745,12 @@ func (i *ManagedIterator) Decode(model Model) error {
 		return err
 	}
 
+	// run hook
+	err = runAfterLoad(i.ctx, model, i.flags)
+	if err != nil {
+		return err
+	}
+
 	// validate if requested
 	if i.validate {
 		err = model.Validate()
@@ -1366,3 +1774,32 @@ func (i *ManagedIterator) Error() error {
 func (i *ManagedIterator) Close() {
 	i.iterator.Close()
 }
+
+func (m *Manager) reader(ctx context.Context, flags []Flags) *Collection {
+	// get preference
+	pref := GetReadPreference(ctx, flags...)
+	if pref == nil {
+		return m.coll
+	}
+
+	return m.store.reader(m.meta, pref)
+}
+
+func (m *Manager) measure(operation string) func() {
+	// get recorder
+	recorder := m.store.recorder
+	if recorder == nil {
+		return func() {}
+	}
+
+	// prepare labels
+	labels := smoke.Labels{
+		"collection": m.meta.Collection,
+		"operation":  operation,
+	}
+
+	// count operation
+	recorder.Count(OperationsMetric, labels, 1)
+
+	return smoke.Measure(recorder, OperationDurationMetric, labels)
+}
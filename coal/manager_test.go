@@ -7,6 +7,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/256dpi/fire/smoke"
 )
 
 func TestFlags(t *testing.T) {
@@ -362,6 +364,36 @@ func TestManagerFindEach(t *testing.T) {
 	})
 }
 
+func TestManagerFindEachInstrument(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Insert(&postModel{
+			Title: "Hello World!",
+		})
+
+		collector := smoke.NewCollector()
+		tester.Store.Instrument(collector)
+		defer tester.Store.Instrument(nil)
+
+		labels := smoke.Labels{
+			"collection": "posts",
+			"operation":  "FindEach",
+		}
+
+		iter, err := tester.Store.M(&postModel{}).FindEach(nil, bson.M{}, nil, 0, 0, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Equal(t, 1.0, collector.Value(OperationsMetric, labels))
+		assert.Equal(t, 0.0, collector.Value(OperationDurationMetric, labels))
+
+		for iter.Next() {
+		}
+		assert.NoError(t, iter.Error())
+		assert.Equal(t, 1.0, collector.Value(OperationDurationMetric, labels))
+
+		iter.Close()
+		assert.Equal(t, 1.0, collector.Value(OperationDurationMetric, labels))
+	})
+}
+
 func TestManagerProject(t *testing.T) {
 	withTester(t, func(t *testing.T, tester *Tester) {
 		post1 := *tester.Insert(&postModel{
@@ -1157,3 +1189,65 @@ func readPosts(t *testing.T, iter *ManagedIterator) []postModel {
 	}
 	return list
 }
+
+func TestManagerAggregate(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		m := tester.Store.M(&commentModel{})
+
+		if tester.Store.Lungo() {
+			assert.PanicsWithValue(t, "coal: not supported by lungo", func() {
+				_ = m.Aggregate(nil, &[]bson.M{}, nil, false)
+			})
+
+			return
+		}
+
+		post1 := New()
+		post2 := New()
+		tester.Insert(&commentModel{Message: "a", Post: post1})
+		tester.Insert(&commentModel{Message: "b", Post: post1})
+		tester.Insert(&commentModel{Message: "c", Post: post2})
+
+		// missing transaction
+		err := m.Aggregate(nil, &[]bson.M{}, nil, false)
+		assert.Error(t, err)
+		assert.True(t, ErrTransactionRequired.Is(err))
+
+		// models
+		var comments []commentModel
+		err = m.Aggregate(nil, &comments, []bson.M{
+			{"$match": bson.M{"Post": post1}},
+			{"$sort": []string{"-Message"}},
+		}, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Len(t, comments, 2)
+		assert.Equal(t, "b", comments[0].Message)
+
+		// structs
+		var groups []struct {
+			Post  ID    `bson:"_id"`
+			Count int64 `bson:"count"`
+		}
+		err = m.Aggregate(nil, &groups, []bson.M{
+			{"$group": bson.M{
+				"_id":   "$Post",
+				"count": bson.M{"$sum": 1},
+			}},
+			{"$sort": bson.M{"count": -1}},
+		}, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Len(t, groups, 2)
+		assert.Equal(t, post1, groups[0].Post)
+		assert.Equal(t, int64(2), groups[0].Count)
+
+		// lock
+		err = tester.Store.T(nil, false, func(ctx context.Context) error {
+			return m.Aggregate(ctx, &comments, []bson.M{
+				{"$match": bson.M{"Post": post2}},
+			}, true)
+		})
+		assert.NoError(t, err)
+		assert.Len(t, comments, 1)
+		assert.Equal(t, int64(1), comments[0].Lock)
+	})
+}
@@ -105,6 +105,24 @@ type Meta struct {
 
 	// The registered indexes.
 	Indexes []Index
+
+	// The collection options.
+	CollectionOptions CollectionOptions
+
+	// Whether the documents are versioned. Versioned models are declared using
+	// the "versioned" option in the tag of the embedded base:
+	//
+	//	Base `json:"-" bson:",inline" coal:"posts,versioned"`
+	//
+	// The manager sets the version of inserted documents to one and increments
+	// it on every write. Replace and ReplaceFirst will only replace a document
+	// if its version matches the version of the provided model. Update will do
+	// the same if the provided model has a non-zero version. ErrConflict is
+	// returned if the document exists but has been modified concurrently. The
+	// version is only checked against the provided model, a conflict is
+	// therefore only detected if the document has been modified since the
+	// model has been loaded.
+	Versioned bool
 }
 
 // GetMeta returns the meta structure for the specified model. It will always
@@ -138,6 +156,9 @@ func GetMeta(model Model) *Meta {
 		Accessor:       stick.BuildAccessor(model, "Base"),
 	}
 
+	// prepare collection tags
+	var collectionTags []string
+
 	// iterate through all fields
 	for i := 0; i < modelType.NumField(); i++ {
 		// get field
@@ -153,8 +174,16 @@ func GetMeta(model Model) *Meta {
 				panic(`coal: expected an embedded "coal.Base" as the first struct field`)
 			}
 
-			// split tag
-			baseTag := strings.Split(coalTag, ":")
+			// split tag and options
+			baseTags := strings.Split(coalTag, ",")
+			baseTag := strings.Split(baseTags[0], ":")
+			for _, tag := range baseTags[1:] {
+				if tag == "versioned" {
+					meta.Versioned = true
+				} else {
+					collectionTags = append(collectionTags, tag)
+				}
+			}
 
 			// check json tag
 			if field.Tag.Get("json") != "-" {
@@ -346,6 +375,9 @@ func GetMeta(model Model) *Meta {
 		}
 	}
 
+	// parse collection options
+	meta.CollectionOptions = parseCollectionOptions(meta, collectionTags)
+
 	// cache meta
 	metaCache[modelType] = meta
 
@@ -10,6 +10,8 @@ import (
 
 	"github.com/256dpi/xo"
 	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/stick"
 )
 
 // Migration is a single migration.
@@ -26,18 +28,43 @@ type Migration struct {
 	// migrations.
 	Async bool
 
+	// The checksum of the migration. If a ledger is used, a completed migration
+	// is run again if its checksum changes.
+	Checksum string
+
+	// Whether the migration supports dry-runs. Migrations that only use the
+	// provided helpers or check GetDryRun before writing may enable dry-runs.
+	DryRun bool
+
 	// The migration function.
 	Migrator func(ctx context.Context, store *Store) (int64, int64, error)
+
+	// The optional function that reverts the migration.
+	Reverter func(ctx context.Context, store *Store) (int64, int64, error)
 }
 
 // Migrator manages multiple migrations.
 type Migrator struct {
 	migrations []Migration
+	ledger     bool
+	interval   time.Duration
 }
 
 // NewMigrator creates and returns a new migrator.
 func NewMigrator() *Migrator {
-	return &Migrator{}
+	return &Migrator{
+		interval: 100 * time.Millisecond,
+	}
+}
+
+// UseLedger will enable the migration ledger. The execution of migrations is
+// recorded in the "migrations" collection and completed migrations are skipped
+// on subsequent runs. Concurrent runs are prevented by locking the records of
+// running migrations. Synchronous migrations running elsewhere are awaited
+// while asynchronous migrations are skipped. An interrupted or failed migration
+// is run again and may resume its work using GetCheckpoint and SetCheckpoint.
+func (m *Migrator) UseLedger() {
+	m.ledger = true
 }
 
 // Add will add the provided migration.
@@ -53,6 +80,14 @@ func (m *Migrator) Add(migration Migration) {
 
 // Run will run all added migrations.
 func (m *Migrator) Run(store *Store, logger io.Writer, reporter func(error)) error {
+	// ensure ledger indexes
+	if m.ledger {
+		err := EnsureIndexes(store, &MigrationRecord{})
+		if err != nil {
+			return err
+		}
+	}
+
 	// run synchronous migrations
 	for _, migration := range m.migrations {
 		if !migration.Async {
@@ -91,6 +126,32 @@ func (m *Migrator) run(store *Store, logger io.Writer, migration *Migration) err
 	ctx, span := xo.Trace(ctx, "MIGRATION "+migration.Name)
 	defer span.End()
 
+	// claim migration if ledger is used
+	var record *MigrationRecord
+	if m.ledger {
+		var ok bool
+		var err error
+		record, ok, err = m.claim(ctx, store, migration)
+		if err != nil {
+			return err
+		}
+
+		// check if skipped
+		if !ok {
+			if logger != nil {
+				_, _ = fmt.Fprintf(logger, "skipped migration: %s\n", migration.Name)
+			}
+
+			return nil
+		}
+
+		// add checkpoint state
+		ctx = context.WithValue(ctx, checkpointKey{}, &checkpointState{
+			store:  store,
+			record: record,
+		})
+	}
+
 	// log
 	if logger != nil {
 		_, _ = fmt.Fprintf(logger, "running migration: %s\n", migration.Name)
@@ -98,6 +159,16 @@ func (m *Migrator) run(store *Store, logger io.Writer, migration *Migration) err
 
 	// call migrator
 	matched, modified, err := migration.Migrator(ctx, store)
+
+	// record result if ledger is used
+	if record != nil {
+		rErr := m.finish(store, record, matched, modified, err)
+		if rErr != nil && err == nil {
+			err = rErr
+		}
+	}
+
+	// check error
 	if err != nil {
 		return err
 	}
@@ -110,6 +181,194 @@ func (m *Migrator) run(store *Store, logger io.Writer, migration *Migration) err
 	return nil
 }
 
+// Rollback will revert all migrations that have been added after the named
+// migration in reverse order. If the name is empty, all migrations are
+// reverted. If a ledger is used, only migrations that have been run are
+// reverted and their records are removed afterwards.
+func (m *Migrator) Rollback(store *Store, name string, logger io.Writer) error {
+	// find position
+	index := -1
+	if name != "" {
+		for i, migration := range m.migrations {
+			if migration.Name == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return xo.F("unknown migration %s", name)
+		}
+	}
+
+	// check reverters
+	for _, migration := range m.migrations[index+1:] {
+		if migration.Reverter == nil {
+			return xo.F("irreversible migration %s", migration.Name)
+		}
+	}
+
+	// revert migrations
+	for i := len(m.migrations) - 1; i > index; i-- {
+		err := m.revert(store, logger, &m.migrations[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DryRun will run all synchronous and asynchronous migrations that support
+// dry-runs in order without writing any changes. It returns the changes that
+// would have been made, including up to the specified number of samples per
+// change. Migrations that do not support dry-runs are skipped.
+func (m *Migrator) DryRun(store *Store, logger io.Writer, samples int) ([]DryRunChange, error) {
+	// prepare dry-run
+	dryRun := &DryRun{
+		Samples: samples,
+	}
+
+	// get migrations
+	var list []*Migration
+	for i := range m.migrations {
+		if !m.migrations[i].Async {
+			list = append(list, &m.migrations[i])
+		}
+	}
+	for i := range m.migrations {
+		if m.migrations[i].Async {
+			list = append(list, &m.migrations[i])
+		}
+	}
+
+	// run migrations
+	for _, migration := range list {
+		err := m.dryRun(store, logger, migration, dryRun)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dryRun.Changes(), nil
+}
+
+func (m *Migrator) revert(store *Store, logger io.Writer, migration *Migration) error {
+	// create context
+	ctx, cancel := context.WithTimeout(context.Background(), migration.Timeout)
+	defer cancel()
+
+	// trace
+	ctx, span := xo.Trace(ctx, "REVERT "+migration.Name)
+	defer span.End()
+
+	// check ledger
+	var record *MigrationRecord
+	if m.ledger {
+		// find record
+		record = &MigrationRecord{}
+		found, err := store.M(record).FindFirst(ctx, record, bson.M{
+			"Name": migration.Name,
+		}, nil, 0, false, NoTransaction)
+		if err != nil {
+			return err
+		}
+
+		// skip migrations that have not been run
+		if !found || record.State == MigrationPending {
+			if logger != nil {
+				_, _ = fmt.Fprintf(logger, "skipped migration: %s\n", migration.Name)
+			}
+
+			return nil
+		}
+
+		// check lock
+		if record.State == MigrationRunning && record.Heartbeat != nil && time.Since(*record.Heartbeat) < migration.Timeout {
+			return xo.F("running migration %s", migration.Name)
+		}
+	}
+
+	// log
+	if logger != nil {
+		_, _ = fmt.Fprintf(logger, "reverting migration: %s\n", migration.Name)
+	}
+
+	// call reverter
+	matched, modified, err := migration.Reverter(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	// remove record
+	if record != nil {
+		_, err = store.M(record).Delete(ctx, nil, record.ID())
+		if err != nil {
+			return err
+		}
+	}
+
+	// print result
+	if logger != nil {
+		_, _ = fmt.Fprintf(logger, "reverted migration: %d matched, %d modified\n", matched, modified)
+	}
+
+	return nil
+}
+
+func (m *Migrator) dryRun(store *Store, logger io.Writer, migration *Migration, dryRun *DryRun) error {
+	// create context
+	ctx, cancel := context.WithTimeout(context.Background(), migration.Timeout)
+	defer cancel()
+
+	// trace
+	ctx, span := xo.Trace(ctx, "DRY-RUN "+migration.Name)
+	defer span.End()
+
+	// check support
+	if !migration.DryRun {
+		if logger != nil {
+			_, _ = fmt.Fprintf(logger, "skipped migration: %s\n", migration.Name)
+		}
+
+		return nil
+	}
+
+	// skip completed migrations if ledger is used
+	if m.ledger {
+		var record MigrationRecord
+		found, err := store.M(&record).FindFirst(ctx, &record, bson.M{
+			"Name": migration.Name,
+		}, nil, 0, false, NoTransaction)
+		if err != nil {
+			return err
+		} else if found && record.State == MigrationCompleted && record.Checksum == migration.Checksum {
+			if logger != nil {
+				_, _ = fmt.Fprintf(logger, "skipped migration: %s\n", migration.Name)
+			}
+
+			return nil
+		}
+	}
+
+	// log
+	if logger != nil {
+		_, _ = fmt.Fprintf(logger, "dry-running migration: %s\n", migration.Name)
+	}
+
+	// call migrator
+	matched, modified, err := migration.Migrator(WithDryRun(ctx, dryRun), store)
+	if err != nil {
+		return err
+	}
+
+	// print result
+	if logger != nil {
+		_, _ = fmt.Fprintf(logger, "completed dry-run: %d matched, %d modified\n", matched, modified)
+	}
+
+	return nil
+}
+
 // ProcessEach will find all documents and yield them to the provided function
 // in parallel up to the specified amount of concurrency. Documents are not
 // validated during lookup.
@@ -215,6 +474,26 @@ func ProcessEach(ctx context.Context, store *Store, model Model, filter bson.M,
 // FindEachAndReplace will apply the provided function to each matching document
 // and replace it with the result. Documents are not validated during lookup.
 func FindEachAndReplace(ctx context.Context, store *Store, model Model, filter bson.M, concurrency int, fn func(Model) error) (int64, int64, error) {
+	// handle dry-run
+	if dryRun := GetDryRun(ctx); dryRun != nil {
+		return dryRun.process(ctx, store, model, "FindEachAndReplace", filter, concurrency, func(model Model) (bson.M, error) {
+			// yield object
+			err := fn(model)
+			if err != nil {
+				return nil, err
+			}
+
+			// convert object
+			var doc bson.M
+			err = stick.BSON.Transfer(model, &doc)
+			if err != nil {
+				return nil, err
+			}
+
+			return doc, nil
+		})
+	}
+
 	return ProcessEach(ctx, store, model, filter, concurrency, func(model Model) error {
 		// yield object
 		err := fn(model)
@@ -236,6 +515,11 @@ func FindEachAndReplace(ctx context.Context, store *Store, model Model, filter b
 // and update it with the resulting document. Documents are not validated during
 // lookup.
 func FindEachAndUpdate(ctx context.Context, store *Store, model Model, filter bson.M, concurrency int, fn func(Model) (bson.M, error)) (int64, int64, error) {
+	// handle dry-run
+	if dryRun := GetDryRun(ctx); dryRun != nil {
+		return dryRun.process(ctx, store, model, "FindEachAndUpdate", filter, concurrency, fn)
+	}
+
 	return ProcessEach(ctx, store, model, filter, concurrency, func(model Model) error {
 		// yield object
 		update, err := fn(model)
@@ -258,12 +542,22 @@ func FindEachAndUpdate(ctx context.Context, store *Store, model Model, filter bs
 // EnsureField will add the provided raw field to all documents that do not
 // have the field already.
 func EnsureField(ctx context.Context, store *Store, model Model, rawField string, value interface{}) (int64, int64, error) {
-	// set field to value
-	res, err := store.C(model).UpdateMany(ctx, bson.M{
+	// prepare filter
+	filter := bson.M{
 		rawField: bson.M{
 			"$exists": false,
 		},
-	}, bson.M{
+	}
+
+	// handle dry-run
+	if dryRun := GetDryRun(ctx); dryRun != nil {
+		return dryRun.update(ctx, store, model, "EnsureField", filter, func(doc bson.M) (bson.M, bson.M) {
+			return bson.M{}, bson.M{rawField: value}
+		})
+	}
+
+	// set field to value
+	res, err := store.C(model).UpdateMany(ctx, filter, bson.M{
 		"$set": bson.M{
 			rawField: value,
 		},
@@ -288,6 +582,22 @@ func RenameFields(ctx context.Context, store *Store, model Model, rawOldToNewFie
 		})
 	}
 
+	// handle dry-run
+	if dryRun := GetDryRun(ctx); dryRun != nil {
+		return dryRun.update(ctx, store, model, "RenameFields", bson.M{
+			"$or": filters,
+		}, func(doc bson.M) (bson.M, bson.M) {
+			before, after := bson.M{}, bson.M{}
+			for rawOldField, rawNewField := range rawOldToNewFields {
+				if value, ok := lookupPath(doc, rawOldField); ok {
+					before[rawOldField] = value
+					after[rawNewField] = value
+				}
+			}
+			return before, after
+		})
+	}
+
 	// rename fields
 	res, err := store.C(model).UpdateMany(ctx, bson.M{
 		"$or": filters,
@@ -319,6 +629,21 @@ func UnsetFields(ctx context.Context, store *Store, model Model, rawFields ...st
 		update[field] = true
 	}
 
+	// handle dry-run
+	if dryRun := GetDryRun(ctx); dryRun != nil {
+		return dryRun.update(ctx, store, model, "UnsetFields", bson.M{
+			"$or": filters,
+		}, func(doc bson.M) (bson.M, bson.M) {
+			before := bson.M{}
+			for _, rawField := range rawFields {
+				if value, ok := lookupPath(doc, rawField); ok {
+					before[rawField] = value
+				}
+			}
+			return before, bson.M{}
+		})
+	}
+
 	// unset fields
 	res, err := store.C(model).UpdateMany(ctx, bson.M{
 		"$or": filters,
@@ -340,8 +665,8 @@ func EnsureArrayField(ctx context.Context, store *Store, model Model, rawArrayFi
 		panic("coal: not supported by lungo")
 	}
 
-	// add new field in each array element using an aggregation pipeline
-	res, err := store.C(model).UpdateMany(ctx, bson.M{
+	// prepare filter
+	filter := bson.M{
 		rawArrayField: bson.M{
 			"$elemMatch": bson.M{
 				rawField: bson.M{
@@ -349,7 +674,15 @@ func EnsureArrayField(ctx context.Context, store *Store, model Model, rawArrayFi
 				},
 			},
 		},
-	}, []bson.M{
+	}
+
+	// handle dry-run
+	if dryRun := GetDryRun(ctx); dryRun != nil {
+		return dryRun.update(ctx, store, model, "EnsureArrayField", filter, nil)
+	}
+
+	// add new field in each array element using an aggregation pipeline
+	res, err := store.C(model).UpdateMany(ctx, filter, []bson.M{
 		{
 			"$set": bson.M{
 				rawArrayField: bson.M{
@@ -416,6 +749,13 @@ func RenameArrayFields(ctx context.Context, store *Store, model Model, rawArrayF
 		rawOldFields = append(rawOldFields, rawOldField)
 	}
 
+	// handle dry-run
+	if dryRun := GetDryRun(ctx); dryRun != nil {
+		return dryRun.update(ctx, store, model, "RenameArrayFields", bson.M{
+			"$or": filters,
+		}, nil)
+	}
+
 	// add new field and remove old field in each array element using an
 	// aggregation pipeline
 	res, err := store.C(model).UpdateMany(ctx, bson.M{
@@ -477,6 +817,13 @@ func UnsetArrayFields(ctx context.Context, store *Store, model Model, rawArrayFi
 		})
 	}
 
+	// handle dry-run
+	if dryRun := GetDryRun(ctx); dryRun != nil {
+		return dryRun.update(ctx, store, model, "UnsetArrayFields", bson.M{
+			"$or": filters,
+		}, nil)
+	}
+
 	// remove old field in each array element using an aggregation pipeline
 	res, err := store.C(model).UpdateMany(ctx, bson.M{
 		"$or": filters,
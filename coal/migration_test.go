@@ -84,6 +84,318 @@ func TestMigrator(t *testing.T) {
 	})
 }
 
+func TestMigratorLedger(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		var runs int
+		var checkpoints []string
+		m := NewMigrator()
+		m.UseLedger()
+		m.Add(Migration{
+			Name:     "foo",
+			Checksum: "1",
+			Migrator: func(ctx context.Context, store *Store) (int64, int64, error) {
+				runs++
+				checkpoints = append(checkpoints, GetCheckpoint(ctx))
+				err := SetCheckpoint(ctx, strconv.Itoa(runs))
+				if err != nil {
+					return 0, 0, err
+				}
+				if runs == 1 {
+					return 1, 0, errors.New("error")
+				}
+				return 2, 1, nil
+			},
+		})
+		m.Add(Migration{
+			Name: "bar",
+			Migrator: func(ctx context.Context, store *Store) (int64, int64, error) {
+				return 0, 0, nil
+			},
+		})
+
+		list, err := m.Status(nil, tester.Store)
+		assert.NoError(t, err)
+		assert.Equal(t, []MigrationRecord{
+			{Name: "foo", Checksum: "1", State: MigrationPending},
+			{Name: "bar", State: MigrationPending},
+		}, list)
+
+		/* failed */
+
+		err = m.Run(tester.Store, nil, nil)
+		assert.Error(t, err)
+		assert.Equal(t, 1, runs)
+
+		list, err = m.Status(nil, tester.Store)
+		assert.NoError(t, err)
+		assert.Equal(t, MigrationFailed, list[0].State)
+		assert.Equal(t, "error", list[0].Error)
+		assert.Equal(t, "1", list[0].Checkpoint)
+		assert.Equal(t, int64(1), list[0].Matched)
+		assert.NotNil(t, list[0].Started)
+		assert.NotNil(t, list[0].Finished)
+		assert.Equal(t, MigrationPending, list[1].State)
+
+		/* resumed */
+
+		xo.Test(func(xt *xo.Tester) {
+			err = m.Run(tester.Store, xo.Sink("MIGRATOR"), nil)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, runs)
+			assert.Equal(t, []string{"", "1"}, checkpoints)
+
+			assert.Equal(t, []string{
+				"running migration: foo",
+				"completed migration: 2 matched, 1 modified",
+				"running migration: bar",
+				"completed migration: 0 matched, 0 modified",
+			}, strings.Split(strings.TrimSpace(xt.Sinks["MIGRATOR"].String), "\n"))
+		})
+
+		list, err = m.Status(nil, tester.Store)
+		assert.NoError(t, err)
+		assert.Equal(t, MigrationCompleted, list[0].State)
+		assert.Equal(t, "", list[0].Error)
+		assert.Equal(t, int64(2), list[0].Matched)
+		assert.Equal(t, int64(1), list[0].Modified)
+		assert.Equal(t, MigrationCompleted, list[1].State)
+
+		/* skipped */
+
+		xo.Test(func(xt *xo.Tester) {
+			err = m.Run(tester.Store, xo.Sink("MIGRATOR"), nil)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, runs)
+
+			assert.Equal(t, []string{
+				"skipped migration: foo",
+				"skipped migration: bar",
+			}, strings.Split(strings.TrimSpace(xt.Sinks["MIGRATOR"].String), "\n"))
+		})
+
+		/* changed checksum */
+
+		m.migrations[0].Checksum = "2"
+		err = m.Run(tester.Store, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, runs)
+
+		/* locked */
+
+		now := time.Now()
+		_, err = tester.Store.M(&MigrationRecord{}).UpdateFirst(nil, &MigrationRecord{}, bson.M{
+			"Name": "foo",
+		}, bson.M{
+			"$set": bson.M{
+				"Checksum":  "3",
+				"State":     MigrationRunning,
+				"Heartbeat": now,
+			},
+		}, nil, false)
+		assert.NoError(t, err)
+
+		m.migrations[0].Async = true
+		err = m.Run(tester.Store, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, runs)
+
+		/* report */
+
+		var buf strings.Builder
+		err = m.Report(nil, tester.Store, &buf)
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 3)
+		assert.True(t, strings.HasPrefix(lines[0], "NAME"))
+		assert.True(t, strings.HasPrefix(lines[1], "foo   running"))
+		assert.True(t, strings.HasPrefix(lines[2], "bar   completed"))
+	})
+}
+
+func TestMigratorRollback(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		var reverted []string
+		revert := func(name string) func(context.Context, *Store) (int64, int64, error) {
+			return func(context.Context, *Store) (int64, int64, error) {
+				reverted = append(reverted, name)
+				return 1, 1, nil
+			}
+		}
+
+		m := NewMigrator()
+		m.UseLedger()
+		for _, name := range []string{"foo", "bar", "baz"} {
+			m.Add(Migration{
+				Name: name,
+				Migrator: func(context.Context, *Store) (int64, int64, error) {
+					return 0, 0, nil
+				},
+				Reverter: revert(name),
+			})
+		}
+
+		err := m.Rollback(tester.Store, "qux", nil)
+		assert.Error(t, err)
+		assert.Equal(t, "unknown migration qux", err.Error())
+
+		/* not run */
+
+		xo.Test(func(xt *xo.Tester) {
+			err = m.Rollback(tester.Store, "foo", xo.Sink("MIGRATOR"))
+			assert.NoError(t, err)
+			assert.Empty(t, reverted)
+
+			assert.Equal(t, []string{
+				"skipped migration: baz",
+				"skipped migration: bar",
+			}, strings.Split(strings.TrimSpace(xt.Sinks["MIGRATOR"].String), "\n"))
+		})
+
+		/* run */
+
+		err = m.Run(tester.Store, nil, nil)
+		assert.NoError(t, err)
+
+		xo.Test(func(xt *xo.Tester) {
+			err = m.Rollback(tester.Store, "foo", xo.Sink("MIGRATOR"))
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"baz", "bar"}, reverted)
+
+			assert.Equal(t, []string{
+				"reverting migration: baz",
+				"reverted migration: 1 matched, 1 modified",
+				"reverting migration: bar",
+				"reverted migration: 1 matched, 1 modified",
+			}, strings.Split(strings.TrimSpace(xt.Sinks["MIGRATOR"].String), "\n"))
+		})
+
+		list, err := m.Status(nil, tester.Store)
+		assert.NoError(t, err)
+		assert.Equal(t, MigrationCompleted, list[0].State)
+		assert.Equal(t, MigrationPending, list[1].State)
+		assert.Equal(t, MigrationPending, list[2].State)
+
+		/* all */
+
+		err = m.Rollback(tester.Store, "", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"baz", "bar", "foo"}, reverted)
+
+		/* irreversible */
+
+		m.migrations[2].Reverter = nil
+		err = m.Rollback(tester.Store, "", nil)
+		assert.Error(t, err)
+		assert.Equal(t, "irreversible migration baz", err.Error())
+		assert.Equal(t, []string{"baz", "bar", "foo"}, reverted)
+	})
+}
+
+func TestMigratorDryRun(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		foo := tester.Insert(&fooModel{
+			Name: "foo",
+			Body: "foo",
+		}).(*fooModel)
+
+		bar := tester.Insert(&fooModel{
+			Name: "bar",
+		}).(*fooModel)
+
+		var ran bool
+		m := NewMigrator()
+		m.Add(Migration{
+			Name:   "rename",
+			DryRun: true,
+			Migrator: func(ctx context.Context, store *Store) (int64, int64, error) {
+				return RenameFields(ctx, store, &fooModel{}, map[string]string{"body": "text"})
+			},
+		})
+		m.Add(Migration{
+			Name:   "unset",
+			DryRun: true,
+			Migrator: func(ctx context.Context, store *Store) (int64, int64, error) {
+				return UnsetFields(ctx, store, &fooModel{}, "name")
+			},
+		})
+		m.Add(Migration{
+			Name:   "update",
+			DryRun: true,
+			Async:  true,
+			Migrator: func(ctx context.Context, store *Store) (int64, int64, error) {
+				return FindEachAndUpdate(ctx, store, &fooModel{}, bson.M{
+					"Name": "bar",
+				}, 1, func(model Model) (bson.M, error) {
+					return bson.M{
+						"$set": bson.M{
+							"Body": "bar",
+						},
+					}, nil
+				})
+			},
+		})
+		m.Add(Migration{
+			Name: "custom",
+			Migrator: func(ctx context.Context, store *Store) (int64, int64, error) {
+				ran = true
+				return 0, 0, nil
+			},
+		})
+
+		xo.Test(func(xt *xo.Tester) {
+			changes, err := m.DryRun(tester.Store, xo.Sink("MIGRATOR"), 5)
+			assert.NoError(t, err)
+			assert.False(t, ran)
+			assert.Equal(t, []DryRunChange{
+				{
+					Operation:  "RenameFields",
+					Collection: "foos",
+					Matched:    1,
+					Modified:   1,
+					Samples: []DryRunSample{
+						{ID: foo.ID(), Before: bson.M{"body": "foo"}, After: bson.M{"text": "foo"}},
+					},
+				},
+				{
+					Operation:  "UnsetFields",
+					Collection: "foos",
+					Matched:    2,
+					Modified:   2,
+					Samples: []DryRunSample{
+						{ID: foo.ID(), Before: bson.M{"name": "foo"}, After: bson.M{}},
+						{ID: bar.ID(), Before: bson.M{"name": "bar"}, After: bson.M{}},
+					},
+				},
+				{
+					Operation:  "FindEachAndUpdate",
+					Collection: "foos",
+					Matched:    1,
+					Modified:   1,
+					Samples: []DryRunSample{
+						{ID: bar.ID(), After: bson.M{"$set": bson.M{"Body": "bar"}}},
+					},
+				},
+			}, changes)
+
+			assert.Equal(t, []string{
+				"dry-running migration: rename",
+				"completed dry-run: 1 matched, 1 modified",
+				"dry-running migration: unset",
+				"completed dry-run: 2 matched, 2 modified",
+				"skipped migration: custom",
+				"dry-running migration: update",
+				"completed dry-run: 1 matched, 1 modified",
+			}, strings.Split(strings.TrimSpace(xt.Sinks["MIGRATOR"].String), "\n"))
+		})
+
+		foos := *tester.FindAll(&fooModel{}).(*[]*fooModel)
+		assert.Equal(t, []*fooModel{
+			{Base: foos[0].Base, Name: "foo", Body: "foo"},
+			{Base: foos[1].Base, Name: "bar"},
+		}, foos)
+	})
+}
+
 func TestProcessEach(t *testing.T) {
 	withTester(t, func(t *testing.T, tester *Tester) {
 		for i := 0; i < 20; i++ {
@@ -18,10 +18,11 @@ type Model interface {
 
 // Base is the base for every coal model.
 type Base struct {
-	DocID ID      `json:"-" bson:"_id,omitempty"`
-	Lock  int64   `json:"-" bson:"_lk,omitempty"`
-	Token ID      `json:"-" bson:"_tk,omitempty"`
-	Score float64 `json:"-" bson:"_sc,omitempty"`
+	DocID   ID      `json:"-" bson:"_id,omitempty"`
+	Lock    int64   `json:"-" bson:"_lk,omitempty"`
+	Token   ID      `json:"-" bson:"_tk,omitempty"`
+	Score   float64 `json:"-" bson:"_sc,omitempty"`
+	Version int64   `json:"-" bson:"_v,omitempty"`
 }
 
 // B is a shorthand to construct a base with the provided id or a generated
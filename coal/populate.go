@@ -0,0 +1,310 @@
+package coal
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/stick"
+)
+
+// Population holds models loaded through a relationship and links them to the
+// models they are related to.
+type Population struct {
+	// The related models indexed by their id.
+	Models map[ID]Model
+
+	// The ids of the related models indexed by the id of the source model.
+	Links map[ID][]ID
+}
+
+// Get returns the related models of the specified source model.
+func (p *Population) Get(id ID) []Model {
+	// collect models
+	list := make([]Model, 0, len(p.Links[id]))
+	for _, relID := range p.Links[id] {
+		if model := p.Models[relID]; model != nil {
+			list = append(list, model)
+		}
+	}
+
+	return list
+}
+
+// First returns the first related model of the specified source model or nil
+// if none is available.
+func (p *Population) First(id ID) Model {
+	// get models
+	list := p.Get(id)
+	if len(list) == 0 {
+		return nil
+	}
+
+	return list[0]
+}
+
+func (p *Population) link(source ID, related Model) {
+	// add model
+	p.Models[related.ID()] = related
+
+	// add link
+	if !stick.Contains(p.Links[source], related.ID()) {
+		p.Links[source] = append(p.Links[source], related.ID())
+	}
+}
+
+// Populate will batch load the models related to the provided list of models
+// through the named relationship. The list may be any slice of models and the
+// related model must match the relationship type. To-one and to-many
+// relationships are loaded by the referenced ids and has-one and has-many
+// relationships are loaded using the inverse relationship of the related model.
+func Populate(ctx context.Context, store *Store, list interface{}, rel string, related Model, flags ...Flags) (*Population, error) {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Populate")
+	defer span.End()
+
+	// get models
+	models := Slice(list)
+
+	// prepare population
+	population := &Population{
+		Models: map[ID]Model{},
+		Links:  map[ID][]ID{},
+	}
+
+	// check models
+	if len(models) == 0 {
+		return population, nil
+	}
+
+	// get relationship
+	field, inverse, err := relationship(models[0], rel, related)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare filter
+	var filter bson.M
+	if inverse == nil {
+		// collect references
+		var ids []ID
+		for _, model := range models {
+			ids = append(ids, references(model, field)...)
+		}
+
+		filter = bson.M{"_id": bson.M{"$in": stick.Unique(ids)}}
+	} else {
+		// collect ids
+		ids := make([]ID, 0, len(models))
+		for _, model := range models {
+			ids = append(ids, model.ID())
+		}
+
+		filter = bson.M{inverse.Name: bson.M{"$in": ids}}
+	}
+
+	// load related models
+	relatedList := GetMeta(related).MakeSlice()
+	err = store.M(related).FindAll(ctx, relatedList, filter, nil, 0, 0, false, flags...)
+	if err != nil {
+		return nil, err
+	}
+
+	// link models
+	population.add(models, field, inverse, Slice(relatedList))
+
+	return population, nil
+}
+
+// Lookup will load the models that match the provided filter into the list and
+// their related models through the named relationship using a single "$lookup"
+// aggregation. See Populate for details about the relationship handling.
+//
+// Note: This function panics if the store is backed by lungo.
+func Lookup(ctx context.Context, store *Store, list interface{}, filter bson.M, sort []string, rel string, related Model, flags ...Flags) (*Population, error) {
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Lookup")
+	defer span.End()
+
+	// check list
+	lv := reflect.ValueOf(list)
+	if lv.Kind() != reflect.Ptr || lv.Elem().Kind() != reflect.Slice {
+		return nil, xo.F("expected slice pointer")
+	}
+
+	// get model
+	et := lv.Elem().Type().Elem()
+	if et.Kind() == reflect.Ptr {
+		et = et.Elem()
+	}
+	model, ok := reflect.New(et).Interface().(Model)
+	if !ok {
+		return nil, xo.F("expected slice of models")
+	}
+
+	// get relationship
+	field, inverse, err := relationship(model, rel, related)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare lookup
+	lookup := bson.M{
+		"from":         related,
+		"localField":   field.Name,
+		"foreignField": "_id",
+		"as":           "_related",
+	}
+	if inverse != nil {
+		lookup["localField"] = "_id"
+		lookup["foreignField"] = inverse.Name
+	}
+
+	// prepare pipeline
+	pipeline := []bson.M{
+		{"$match": filter},
+	}
+	if len(sort) > 0 {
+		pipeline = append(pipeline, bson.M{"$sort": sort})
+	}
+	pipeline = append(pipeline, bson.M{"$lookup": lookup})
+
+	// aggregate documents
+	var docs []bson.Raw
+	err = store.M(model).Aggregate(ctx, &docs, pipeline, false, flags...)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare population
+	population := &Population{
+		Models: map[ID]Model{},
+		Links:  map[ID][]ID{},
+	}
+
+	// decode documents
+	slice := lv.Elem()
+	for _, doc := range docs {
+		// decode model
+		item := reflect.New(et)
+		err = bson.Unmarshal(doc, item.Interface())
+		if err != nil {
+			return nil, xo.W(err)
+		}
+
+		// decode related models
+		var relatedDocs []bson.Raw
+		err = doc.Lookup("_related").Unmarshal(&relatedDocs)
+		if err != nil {
+			return nil, xo.W(err)
+		}
+		relatedModels := make([]Model, 0, len(relatedDocs))
+		for _, relatedDoc := range relatedDocs {
+			relatedModel := GetMeta(related).Make()
+			err = bson.Unmarshal(relatedDoc, relatedModel)
+			if err != nil {
+				return nil, xo.W(err)
+			}
+			relatedModels = append(relatedModels, relatedModel)
+		}
+
+		// link models
+		population.add([]Model{item.Interface().(Model)}, field, inverse, relatedModels)
+
+		// add model
+		if slice.Type().Elem().Kind() == reflect.Ptr {
+			slice = reflect.Append(slice, item)
+		} else {
+			slice = reflect.Append(slice, item.Elem())
+		}
+	}
+
+	// set list
+	lv.Elem().Set(slice)
+
+	return population, nil
+}
+
+func (p *Population) add(models []Model, field, inverse *Field, related []Model) {
+	// link referenced models
+	if inverse == nil {
+		// index related models
+		index := make(map[ID]Model, len(related))
+		for _, model := range related {
+			index[model.ID()] = model
+		}
+
+		// link models in reference order
+		for _, model := range models {
+			for _, id := range references(model, field) {
+				if relatedModel := index[id]; relatedModel != nil {
+					p.link(model.ID(), relatedModel)
+				}
+			}
+		}
+
+		return
+	}
+
+	// index source models
+	sources := make(map[ID]bool, len(models))
+	for _, model := range models {
+		sources[model.ID()] = true
+	}
+
+	// link referencing models
+	for _, relatedModel := range related {
+		for _, id := range references(relatedModel, inverse) {
+			if sources[id] {
+				p.link(id, relatedModel)
+			}
+		}
+	}
+}
+
+func relationship(model Model, rel string, related Model) (*Field, *Field, error) {
+	// get metas
+	meta := GetMeta(model)
+	relatedMeta := GetMeta(related)
+
+	// get field
+	field := meta.Relationships[rel]
+	if field == nil {
+		return nil, nil, xo.F("unknown relationship %s", rel)
+	} else if field.RelType != relatedMeta.PluralName {
+		return nil, nil, xo.F("expected related model of type %s", field.RelType)
+	}
+
+	// return field for to-one and to-many relationships
+	if field.ToOne || field.ToMany {
+		return field, nil, nil
+	}
+
+	// get inverse field
+	inverse := relatedMeta.Relationships[field.RelInverse]
+	if inverse == nil || (!inverse.ToOne && !inverse.ToMany) {
+		return nil, nil, xo.F("missing inverse relationship %s", field.RelInverse)
+	}
+
+	return field, inverse, nil
+}
+
+func references(model Model, field *Field) []ID {
+	// get references
+	switch value := stick.MustGet(model, field.Name).(type) {
+	case ID:
+		if value != "" {
+			return []ID{value}
+		}
+	case *ID:
+		if value != nil {
+			return []ID{*value}
+		}
+	case []ID:
+		return value
+	}
+
+	return nil
+}
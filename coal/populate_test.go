@@ -0,0 +1,111 @@
+package coal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPopulate(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		post1 := tester.Insert(&postModel{Title: "post1"}).(*postModel)
+		post2 := tester.Insert(&postModel{Title: "post2"}).(*postModel)
+		post3 := tester.Insert(&postModel{Title: "post3"}).(*postModel)
+
+		comment1 := tester.Insert(&commentModel{Message: "comment1", Post: post1.ID()}).(*commentModel)
+		comment2 := tester.Insert(&commentModel{Message: "comment2", Post: post1.ID()}).(*commentModel)
+		comment3 := tester.Insert(&commentModel{Message: "comment3", Post: post2.ID()}).(*commentModel)
+
+		selection := tester.Insert(&selectionModel{Name: "selection", Posts: []ID{post3.ID(), post1.ID()}}).(*selectionModel)
+
+		note := tester.Insert(&noteModel{Title: "note", Post: post2.ID()}).(*noteModel)
+
+		/* to-one */
+
+		pop, err := Populate(nil, tester.Store, []*commentModel{comment1, comment2, comment3}, "post", &postModel{}, NoTransaction)
+		assert.NoError(t, err)
+		assert.Len(t, pop.Models, 2)
+		assert.Equal(t, post1, pop.First(comment1.ID()))
+		assert.Equal(t, post1, pop.First(comment2.ID()))
+		assert.Equal(t, post2, pop.First(comment3.ID()))
+
+		/* to-many */
+
+		pop, err = Populate(nil, tester.Store, []selectionModel{*selection}, "posts", &postModel{}, NoTransaction)
+		assert.NoError(t, err)
+		assert.Equal(t, []Model{post3, post1}, pop.Get(selection.ID()))
+
+		/* has-many */
+
+		pop, err = Populate(nil, tester.Store, []*postModel{post1, post2, post3}, "comments", &commentModel{}, NoTransaction)
+		assert.NoError(t, err)
+		assert.Len(t, pop.Get(post1.ID()), 2)
+		assert.Equal(t, []Model{comment3}, pop.Get(post2.ID()))
+		assert.Empty(t, pop.Get(post3.ID()))
+
+		pop, err = Populate(nil, tester.Store, []*postModel{post1, post2, post3}, "selections", &selectionModel{}, NoTransaction)
+		assert.NoError(t, err)
+		assert.Equal(t, []Model{selection}, pop.Get(post1.ID()))
+		assert.Empty(t, pop.Get(post2.ID()))
+		assert.Equal(t, []Model{selection}, pop.Get(post3.ID()))
+
+		/* has-one */
+
+		pop, err = Populate(nil, tester.Store, []*postModel{post1, post2}, "note", &noteModel{}, NoTransaction)
+		assert.NoError(t, err)
+		assert.Nil(t, pop.First(post1.ID()))
+		assert.Equal(t, note.ID(), pop.First(post2.ID()).ID())
+
+		/* empty */
+
+		pop, err = Populate(nil, tester.Store, []*postModel{}, "note", &noteModel{}, NoTransaction)
+		assert.NoError(t, err)
+		assert.Empty(t, pop.Models)
+
+		/* errors */
+
+		_, err = Populate(nil, tester.Store, []*postModel{post1}, "foo", &noteModel{}, NoTransaction)
+		assert.Error(t, err)
+		assert.Equal(t, "unknown relationship foo", err.Error())
+
+		_, err = Populate(nil, tester.Store, []*postModel{post1}, "note", &commentModel{}, NoTransaction)
+		assert.Error(t, err)
+		assert.Equal(t, "expected related model of type notes", err.Error())
+
+		_, err = Populate(nil, tester.Store, []*postModel{post1}, "comments", &commentModel{})
+		assert.Error(t, err)
+		assert.True(t, ErrTransactionRequired.Is(err))
+	})
+}
+
+func TestLookup(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		if tester.Store.Lungo() {
+			assert.PanicsWithValue(t, "coal: not supported by lungo", func() {
+				_, _ = Lookup(nil, tester.Store, &[]*postModel{}, bson.M{}, nil, "comments", &commentModel{}, NoTransaction)
+			})
+
+			return
+		}
+
+		post1 := tester.Insert(&postModel{Title: "post1"}).(*postModel)
+		post2 := tester.Insert(&postModel{Title: "post2"}).(*postModel)
+
+		comment1 := tester.Insert(&commentModel{Message: "comment1", Post: post1.ID()}).(*commentModel)
+		comment2 := tester.Insert(&commentModel{Message: "comment2", Post: post2.ID()}).(*commentModel)
+
+		var posts []*postModel
+		pop, err := Lookup(nil, tester.Store, &posts, bson.M{}, []string{"Title"}, "comments", &commentModel{}, NoTransaction)
+		assert.NoError(t, err)
+		assert.Equal(t, []*postModel{post1, post2}, posts)
+		assert.Equal(t, []Model{comment1}, pop.Get(post1.ID()))
+		assert.Equal(t, []Model{comment2}, pop.Get(post2.ID()))
+
+		var comments []commentModel
+		pop, err = Lookup(nil, tester.Store, &comments, bson.M{"Message": "comment2"}, nil, "post", &postModel{}, NoTransaction)
+		assert.NoError(t, err)
+		assert.Equal(t, []commentModel{*comment2}, comments)
+		assert.Equal(t, post2, pop.First(comment2.ID()))
+	})
+}
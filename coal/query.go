@@ -0,0 +1,273 @@
+package coal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Operator defines a query comparison operator.
+type Operator string
+
+// The available query operators.
+const (
+	Eq     Operator = "$eq"
+	Ne     Operator = "$ne"
+	Gt     Operator = "$gt"
+	Gte    Operator = "$gte"
+	Lt     Operator = "$lt"
+	Lte    Operator = "$lte"
+	In     Operator = "$in"
+	Nin    Operator = "$nin"
+	Exists Operator = "$exists"
+)
+
+var idField = &Field{
+	Name:    "_id",
+	Type:    reflect.TypeOf(ID("")),
+	Kind:    reflect.String,
+	BSONKey: "_id",
+}
+
+// Query is a type-safe query builder for a model. Field names and values are
+// validated against the model meta when the query is constructed. The built
+// query provides the filter, sort, skip and limit arguments for the manager.
+//
+//	query := coal.Q[*Post]().
+//		Where("Published", coal.Eq, true).
+//		Or(coal.Q[*Post]().Where("Title", coal.Eq, "Foo"), coal.Q[*Post]().Where("Title", coal.Eq, "Bar")).
+//		Sort("-CreatedAt").
+//		Limit(10)
+//
+//	err := store.M(&Post{}).FindAll(ctx, &posts, query.Filter(), query.Sorting(), query.Skipping(), query.Limiting(), false)
+type Query[M Model] struct {
+	meta       *Meta
+	conditions []bson.M
+	sort       []string
+	skip       int64
+	limit      int64
+}
+
+// Q creates and returns a new query for the specified model type.
+//
+// Note: The builder methods panic if a field is unknown or virtual or a value
+// does not match the field type. Queries should therefore be constructed from
+// static field names and typed values.
+func Q[M Model]() *Query[M] {
+	return &Query[M]{
+		meta: GetMeta(reflect.New(reflect.TypeOf(*new(M)).Elem()).Interface().(Model)),
+	}
+}
+
+// Where will add a condition that matches documents where the specified field
+// compares to the provided value using the operator. The "_id" field may be used
+// to match document ids.
+//
+// Note: This method panics if the field is unknown or virtual or the value
+// type does not match the field type.
+func (q *Query[M]) Where(field string, op Operator, value interface{}) *Query[M] {
+	// get field
+	metaField := idField
+	if field != "_id" {
+		metaField = q.meta.Fields[field]
+	}
+	if metaField == nil {
+		panic(fmt.Sprintf(`coal: unknown field "%s"`, field))
+	} else if metaField.BSONKey == "" {
+		panic(fmt.Sprintf(`coal: virtual field "%s"`, field))
+	}
+
+	// check value
+	var ok bool
+	switch op {
+	case Eq, Ne, Gt, Gte, Lt, Lte:
+		ok = matchesField(metaField, value)
+	case In, Nin:
+		ok = matchesFieldList(metaField, value)
+	case Exists:
+		_, ok = value.(bool)
+	default:
+		panic(fmt.Sprintf(`coal: unsupported operator "%s"`, op))
+	}
+	if !ok {
+		panic(fmt.Sprintf(`coal: invalid value %T for field "%s" and operator "%s"`, value, field, op))
+	}
+
+	// add condition
+	q.conditions = append(q.conditions, bson.M{
+		field: bson.M{
+			string(op): value,
+		},
+	})
+
+	return q
+}
+
+// And will add a condition that matches documents that match all provided
+// queries. Only the filters of the provided queries are used.
+func (q *Query[M]) And(queries ...*Query[M]) *Query[M] {
+	// add filters
+	for _, query := range queries {
+		q.conditions = append(q.conditions, query.Filter())
+	}
+
+	return q
+}
+
+// Or will add a condition that matches documents that match any of the
+// provided queries. Only the filters of the provided queries are used. The
+// query is left unchanged if no queries are provided.
+func (q *Query[M]) Or(queries ...*Query[M]) *Query[M] {
+	// check queries
+	if len(queries) == 0 {
+		return q
+	}
+
+	// collect filters
+	filters := make([]bson.M, 0, len(queries))
+	for _, query := range queries {
+		filters = append(filters, query.Filter())
+	}
+
+	// add condition
+	q.conditions = append(q.conditions, bson.M{
+		"$or": filters,
+	})
+
+	return q
+}
+
+// Sort will add the provided fields to the sort order. Fields may be prefixed
+// with a dash to sort in descending order.
+//
+// Note: This method panics if a field is unknown or virtual.
+func (q *Query[M]) Sort(fields ...string) *Query[M] {
+	// check fields
+	for _, field := range fields {
+		name := strings.TrimPrefix(field, "-")
+		if name == "_id" {
+			continue
+		}
+		metaField := q.meta.Fields[name]
+		if metaField == nil {
+			panic(fmt.Sprintf(`coal: unknown field "%s"`, name))
+		} else if metaField.BSONKey == "" {
+			panic(fmt.Sprintf(`coal: virtual field "%s"`, name))
+		}
+	}
+
+	// add fields
+	q.sort = append(q.sort, fields...)
+
+	return q
+}
+
+// Skip will set the number of documents to skip.
+func (q *Query[M]) Skip(skip int64) *Query[M] {
+	q.skip = skip
+	return q
+}
+
+// Limit will set the maximum number of documents to return.
+func (q *Query[M]) Limit(limit int64) *Query[M] {
+	q.limit = limit
+	return q
+}
+
+// Filter returns the filter document of the query.
+func (q *Query[M]) Filter() bson.M {
+	// check conditions
+	switch len(q.conditions) {
+	case 0:
+		return bson.M{}
+	case 1:
+		return q.conditions[0]
+	}
+
+	// combine conditions
+	filters := make([]bson.M, len(q.conditions))
+	copy(filters, q.conditions)
+
+	return bson.M{
+		"$and": filters,
+	}
+}
+
+// Sorting returns the sort fields of the query.
+func (q *Query[M]) Sorting() []string {
+	return q.sort
+}
+
+// Skipping returns the number of documents to skip.
+func (q *Query[M]) Skipping() int64 {
+	return q.skip
+}
+
+// Limiting returns the maximum number of documents to return.
+func (q *Query[M]) Limiting() int64 {
+	return q.limit
+}
+
+func matchesField(field *Field, value interface{}) bool {
+	// check nil
+	if value == nil {
+		switch field.Type.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			return true
+		}
+		return false
+	}
+
+	// check type
+	typ := reflect.TypeOf(value)
+	if matchesType(typ, field.Type) {
+		return true
+	}
+
+	// check pointer and slice elements
+	switch field.Type.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return matchesType(typ, field.Type.Elem())
+	}
+
+	return false
+}
+
+func matchesType(typ, target reflect.Type) bool {
+	// check assignability
+	if typ.AssignableTo(target) {
+		return true
+	}
+
+	// allow numbers that convert to the target, e.g. untyped constants
+	return isNumeric(typ) && isNumeric(target) && typ.ConvertibleTo(target)
+}
+
+func isNumeric(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func matchesFieldList(field *Field, value interface{}) bool {
+	// check list
+	list := reflect.ValueOf(value)
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return false
+	}
+
+	// check items
+	for i := 0; i < list.Len(); i++ {
+		if !matchesField(field, list.Index(i).Interface()) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,140 @@
+package coal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type queryModel struct {
+	Base  `json:"-" bson:",inline" coal:"queries"`
+	Count int
+	Total int64
+	Rate  float64
+	Limit *float32
+}
+
+func (m *queryModel) Validate() error {
+	return nil
+}
+
+func TestQuery(t *testing.T) {
+	q := Q[*postModel]()
+	assert.Equal(t, bson.M{}, q.Filter())
+	assert.Nil(t, q.Sorting())
+	assert.Zero(t, q.Skipping())
+	assert.Zero(t, q.Limiting())
+
+	q = Q[*postModel]().Where("Title", Eq, "foo")
+	assert.Equal(t, bson.M{
+		"Title": bson.M{"$eq": "foo"},
+	}, q.Filter())
+
+	id := New()
+	cq := Q[*commentModel]().
+		Where("Post", In, []ID{id}).
+		Where("Parent", Eq, nil).
+		Or(
+			Q[*commentModel]().Where("Message", Eq, "foo"),
+			Q[*commentModel]().Where("Message", Exists, false),
+		).
+		Sort("-Message", "_id").
+		Skip(5).
+		Limit(10)
+	assert.Equal(t, bson.M{
+		"$and": []bson.M{
+			{"Post": bson.M{"$in": []ID{id}}},
+			{"Parent": bson.M{"$eq": nil}},
+			{"$or": []bson.M{
+				{"Message": bson.M{"$eq": "foo"}},
+				{"Message": bson.M{"$exists": false}},
+			}},
+		},
+	}, cq.Filter())
+	assert.Equal(t, []string{"-Message", "_id"}, cq.Sorting())
+	assert.Equal(t, int64(5), cq.Skipping())
+	assert.Equal(t, int64(10), cq.Limiting())
+
+	eq := Q[*postModel]().Where("Title", Eq, "foo").Or()
+	assert.Equal(t, bson.M{
+		"Title": bson.M{"$eq": "foo"},
+	}, eq.Filter())
+
+	sq := Q[*selectionModel]().
+		Where("Posts", Eq, New()).
+		And(Q[*selectionModel]().Where("_id", Ne, New()))
+	assert.Len(t, sq.Filter()["$and"], 2)
+
+	nq := Q[*queryModel]().
+		Where("Count", Gt, 5).
+		Where("Total", Lte, 5).
+		Where("Rate", Gte, 1.5).
+		Where("Rate", Lt, 5).
+		Where("Limit", Eq, 2).
+		Where("Total", In, []int{1, 2})
+	assert.Equal(t, bson.M{
+		"$and": []bson.M{
+			{"Count": bson.M{"$gt": 5}},
+			{"Total": bson.M{"$lte": 5}},
+			{"Rate": bson.M{"$gte": 1.5}},
+			{"Rate": bson.M{"$lt": 5}},
+			{"Limit": bson.M{"$eq": 2}},
+			{"Total": bson.M{"$in": []int{1, 2}}},
+		},
+	}, nq.Filter())
+
+	assert.PanicsWithValue(t, `coal: unknown field "Foo"`, func() {
+		Q[*postModel]().Where("Foo", Eq, "foo")
+	})
+	assert.PanicsWithValue(t, `coal: virtual field "Comments"`, func() {
+		Q[*postModel]().Where("Comments", Eq, "foo")
+	})
+	assert.PanicsWithValue(t, `coal: invalid value int for field "Title" and operator "$eq"`, func() {
+		Q[*postModel]().Where("Title", Eq, 1)
+	})
+	assert.PanicsWithValue(t, `coal: invalid value string for field "Count" and operator "$gt"`, func() {
+		Q[*queryModel]().Where("Count", Gt, "5")
+	})
+	assert.PanicsWithValue(t, `coal: invalid value bool for field "Rate" and operator "$eq"`, func() {
+		Q[*queryModel]().Where("Rate", Eq, true)
+	})
+	assert.PanicsWithValue(t, `coal: invalid value []int for field "Title" and operator "$in"`, func() {
+		Q[*postModel]().Where("Title", In, []int{1})
+	})
+	assert.PanicsWithValue(t, `coal: invalid value string for field "Title" and operator "$exists"`, func() {
+		Q[*postModel]().Where("Title", Exists, "foo")
+	})
+	assert.PanicsWithValue(t, `coal: unsupported operator "$regex"`, func() {
+		Q[*postModel]().Where("Title", "$regex", "foo")
+	})
+	assert.PanicsWithValue(t, `coal: unknown field "Foo"`, func() {
+		Q[*postModel]().Sort("-Foo")
+	})
+}
+
+func TestQueryManager(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Insert(&postModel{Title: "a", Published: true})
+		tester.Insert(&postModel{Title: "b", Published: true})
+		tester.Insert(&postModel{Title: "c"})
+
+		q := Q[*postModel]().Where("Published", Eq, true).Sort("-Title").Limit(1)
+
+		var posts []*postModel
+		err := tester.Store.M(&postModel{}).FindAll(nil, &posts, q.Filter(), q.Sorting(), q.Skipping(), q.Limiting(), false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Len(t, posts, 1)
+		assert.Equal(t, "b", posts[0].Title)
+
+		count, err := tester.Store.M(&postModel{}).Count(nil, q.Filter(), q.Skipping(), 0, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		n, err := tester.Store.M(&postModel{}).UpdateAll(nil, q.Filter(), bson.M{
+			"$set": bson.M{"Published": false},
+		}, false)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+	})
+}
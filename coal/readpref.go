@@ -0,0 +1,161 @@
+package coal
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/256dpi/lungo"
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type routingKey struct{}
+
+type routing struct {
+	pref    *readpref.ReadPref
+	session bool
+	written *int32
+}
+
+func getRouting(ctx context.Context) *routing {
+	// check context
+	if ctx == nil {
+		return nil
+	}
+
+	// get routing
+	rt, _ := ctx.Value(routingKey{}).(*routing)
+
+	return rt
+}
+
+// WithReadPreference will return a context that carries the specified read
+// preference. Manager read operations that use the context will be served
+// according to the read preference. Since transactions must read from the
+// primary, the preference is ignored within transactions.
+//
+// As reads from secondaries may return stale data, read operations that use a
+// non-primary read preference do not require a transaction. Once a write has
+// been performed using the context, subsequent reads are routed to the primary
+// to ensure the write is observed. Within a session created by S, reads remain
+// routed by the preference as the session guarantees causal consistency.
+func WithReadPreference(ctx context.Context, pref *readpref.ReadPref) context.Context {
+	// ensure context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// inherit state
+	rt := &routing{
+		pref:    pref,
+		written: new(int32),
+	}
+	if parent := getRouting(ctx); parent != nil {
+		rt.session = parent.session
+		rt.written = parent.written
+	}
+
+	return context.WithValue(ctx, routingKey{}, rt)
+}
+
+// GetReadPreference will return the read preference that is used for read
+// operations with the specified context and flags. A nil preference is
+// returned if reads are served by the primary.
+func GetReadPreference(ctx context.Context, flags ...Flags) *readpref.ReadPref {
+	// transactions always read from the primary
+	if HasTransaction(ctx) {
+		return nil
+	}
+
+	// get routing
+	rt := getRouting(ctx)
+
+	// ensure read-your-writes outside of sessions
+	if rt != nil && !rt.session && atomic.LoadInt32(rt.written) > 0 {
+		return nil
+	}
+
+	// check flags
+	if Merge(flags).Has(SecondaryPreferred) {
+		return readpref.SecondaryPreferred()
+	}
+
+	// check routing
+	if rt == nil || rt.pref == nil || rt.pref.Mode() == readpref.PrimaryMode {
+		return nil
+	}
+
+	return rt.pref
+}
+
+// HasSession will return whether the context carries a causally consistent
+// session created by S.
+func HasSession(ctx context.Context) bool {
+	rt := getRouting(ctx)
+	return rt != nil && rt.session
+}
+
+// S will create a causally consistent session around the specified callback.
+// The created context must be used with all operations that should be
+// included in the session. Reads within the session are guaranteed to observe
+// preceding writes within the session, even if they are served by secondaries.
+// Existing sessions and transactions are reused.
+func (s *Store) S(ctx context.Context, fn func(ctx context.Context) error) error {
+	// ensure context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// check if session or transaction already exists
+	if HasSession(ctx) || HasTransaction(ctx) {
+		return fn(ctx)
+	}
+
+	// trace
+	ctx, span := xo.Trace(ctx, "coal/Store.S")
+	defer span.End()
+
+	// prepare options
+	opts := options.Session().SetCausalConsistency(true)
+
+	// start session
+	return xo.W(s.client.UseSessionWithOptions(ctx, opts, func(sc lungo.ISessionContext) error {
+		// prepare routing
+		rt := &routing{
+			session: true,
+			written: new(int32),
+		}
+		if parent := getRouting(ctx); parent != nil {
+			rt.pref = parent.pref
+		}
+
+		return fn(context.WithValue(sc, routingKey{}, rt))
+	}))
+}
+
+func markWritten(ctx context.Context) {
+	// mark routing
+	if rt := getRouting(ctx); rt != nil {
+		atomic.StoreInt32(rt.written, 1)
+	}
+}
+
+func requiresTransaction(ctx context.Context, lock bool, flags []Flags) bool {
+	// check transaction
+	if HasTransaction(ctx) {
+		return false
+	}
+
+	// check lock
+	if lock {
+		return true
+	}
+
+	// check flags
+	if Merge(flags).Has(NoTransaction) {
+		return false
+	}
+
+	return GetReadPreference(ctx, flags...) == nil
+}
@@ -0,0 +1,101 @@
+package coal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestReadPreference(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		assert.Nil(t, GetReadPreference(nil))
+		assert.Equal(t, readpref.SecondaryPreferred(), GetReadPreference(nil, SecondaryPreferred))
+
+		ctx := WithReadPreference(nil, readpref.Primary())
+		assert.Nil(t, GetReadPreference(ctx))
+
+		ctx = WithReadPreference(nil, readpref.Secondary())
+		assert.Equal(t, readpref.Secondary(), GetReadPreference(ctx))
+
+		assert.NoError(t, tester.Store.T(ctx, true, func(tc context.Context) error {
+			assert.Nil(t, GetReadPreference(tc))
+			assert.Nil(t, GetReadPreference(tc, SecondaryPreferred))
+			return nil
+		}))
+
+		tester.Insert(&postModel{Title: "foo"})
+
+		var posts []postModel
+		err := tester.Store.M(&postModel{}).FindAll(nil, &posts, nil, nil, 0, 0, false)
+		assert.Error(t, err)
+		assert.True(t, ErrTransactionRequired.Is(err))
+
+		err = tester.Store.M(&postModel{}).FindAll(ctx, &posts, nil, nil, 0, 0, false)
+		assert.NoError(t, err)
+		assert.Len(t, posts, 1)
+
+		err = tester.Store.M(&postModel{}).FindAll(nil, &posts, nil, nil, 0, 0, false, SecondaryPreferred)
+		assert.NoError(t, err)
+		assert.Len(t, posts, 1)
+
+		err = tester.Store.M(&postModel{}).FindAll(ctx, &posts, nil, nil, 0, 0, true)
+		assert.Error(t, err)
+		assert.True(t, ErrTransactionRequired.Is(err))
+
+		err = tester.Store.M(&postModel{}).Insert(ctx, &postModel{Title: "bar"})
+		assert.NoError(t, err)
+		assert.Nil(t, GetReadPreference(ctx))
+		assert.Nil(t, GetReadPreference(WithReadPreference(ctx, readpref.Secondary())))
+
+		err = tester.Store.M(&postModel{}).FindAll(ctx, &posts, nil, nil, 0, 0, false)
+		assert.Error(t, err)
+		assert.True(t, ErrTransactionRequired.Is(err))
+	})
+}
+
+func TestStoreS(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		assert.False(t, HasSession(nil))
+
+		ctx := WithReadPreference(nil, readpref.SecondaryPreferred())
+
+		assert.NoError(t, tester.Store.S(ctx, func(sc context.Context) error {
+			assert.True(t, HasSession(sc))
+			assert.False(t, HasTransaction(sc))
+			assert.Equal(t, readpref.SecondaryPreferred(), GetReadPreference(sc))
+
+			assert.NoError(t, tester.Store.S(sc, func(nc context.Context) error {
+				assert.Equal(t, sc, nc)
+				return nil
+			}))
+
+			post := &postModel{Title: "foo"}
+			err := tester.Store.M(&postModel{}).Insert(sc, post)
+			assert.NoError(t, err)
+			assert.Equal(t, readpref.SecondaryPreferred(), GetReadPreference(sc))
+
+			found, err := tester.Store.M(&postModel{}).Find(sc, &postModel{}, post.ID(), false)
+			assert.NoError(t, err)
+			assert.True(t, found)
+
+			count, err := tester.Store.M(&postModel{}).Count(sc, nil, 0, 0, false)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), count)
+
+			return nil
+		}))
+	})
+}
+
+func TestStoreR(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		assert.Same(t, tester.Store.C(&postModel{}), tester.Store.R(&postModel{}, nil))
+
+		coll := tester.Store.R(&postModel{}, readpref.SecondaryPreferred())
+		assert.NotSame(t, tester.Store.C(&postModel{}), coll)
+		assert.Same(t, coll, tester.Store.R(&postModel{}, readpref.SecondaryPreferred()))
+		assert.Equal(t, "posts", coll.Native().Name())
+	})
+}
@@ -8,7 +8,11 @@ import (
 // automatically load existing models once the underlying stream has been opened.
 // After that it will yield all changes to the collection until the returned
 // stream has been closed.
-func Reconcile(store *Store, model Model, loaded func(), created, updated func(Model), deleted func(ID), errored func(error)) *Stream {
+//
+// If the stream is named and a checkpointer is configured using the options,
+// the stream resumes from the stored token and existing models are only loaded
+// if no token is available or the token has expired.
+func Reconcile(store *Store, model Model, loaded func(), created, updated func(Model), deleted func(ID), errored func(error), opts ...StreamOptions) *Stream {
 	// prepare load
 	load := func() error {
 		// get cursor
@@ -76,7 +80,7 @@ func Reconcile(store *Store, model Model, loaded func(), created, updated func(M
 		}
 
 		return nil
-	})
+	}, opts...)
 
 	return stream
 }
@@ -48,3 +48,62 @@ func TestReconcile(t *testing.T) {
 		stream.Close()
 	})
 }
+
+func TestReconcileCheckpoint(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		time.Sleep(10 * time.Millisecond)
+
+		checkpointer := &memoryCheckpointer{tokens: map[string][]byte{}}
+
+		tester.Insert(&postModel{Title: "foo"})
+
+		open := make(chan struct{})
+		created := make(chan struct{})
+
+		var loaded int
+		stream := Reconcile(tester.Store, &postModel{}, func() {
+			close(open)
+		}, func(model Model) {
+			loaded++
+			if model.(*postModel).Title == "bar" {
+				close(created)
+			}
+		}, nil, nil, func(err error) {
+			panic(err)
+		}, StreamOptions{
+			Name:         "posts",
+			Checkpointer: checkpointer,
+		})
+
+		<-open
+
+		tester.Insert(&postModel{Title: "bar"})
+
+		<-created
+
+		stream.Close()
+
+		assert.Equal(t, 2, loaded)
+		assert.NotNil(t, checkpointer.tokens["posts"])
+
+		created = make(chan struct{})
+
+		stream = Reconcile(tester.Store, &postModel{}, func() {
+			panic("unexpected load")
+		}, func(model Model) {
+			assert.Equal(t, "baz", model.(*postModel).Title)
+			close(created)
+		}, nil, nil, func(err error) {
+			panic(err)
+		}, StreamOptions{
+			Name:         "posts",
+			Checkpointer: checkpointer,
+		})
+
+		tester.Insert(&postModel{Title: "baz"})
+
+		<-created
+
+		stream.Close()
+	})
+}
@@ -13,8 +13,11 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 	"go.mongodb.org/mongo-driver/x/mongo/driver"
+
+	"github.com/256dpi/fire/smoke"
 )
 
 // MustConnect will call Connect and panic on errors.
@@ -116,7 +119,9 @@ type Store struct {
 	defDB    string
 	engine   *lungo.Engine
 	reporter func(error)
+	recorder smoke.Recorder
 	colls    sync.Map
+	readers  sync.Map
 	managers sync.Map
 }
 
@@ -131,6 +136,12 @@ func (s *Store) Lungo() bool {
 	return ok
 }
 
+// Instrument will set the recorder that is used by managers to record operation
+// metrics. It must be called before the store is used.
+func (s *Store) Instrument(recorder smoke.Recorder) {
+	s.recorder = recorder
+}
+
 // DB returns the database used by this store.
 func (s *Store) DB() lungo.IDatabase {
 	return s.client.Database(s.defDB)
@@ -161,6 +172,39 @@ func (s *Store) C(model Model) *Collection {
 	return coll
 }
 
+// R will return the collection for the specified model that serves reads
+// according to the provided read preference. If the preference is nil, the
+// collection returned by C is used.
+func (s *Store) R(model Model, pref *readpref.ReadPref) *Collection {
+	// check preference
+	if pref == nil {
+		return s.C(model)
+	}
+
+	return s.reader(GetMeta(model), pref)
+}
+
+func (s *Store) reader(meta *Meta, pref *readpref.ReadPref) *Collection {
+	// prepare key
+	key := readerKey{meta: meta, pref: pref.String()}
+
+	// check cache
+	val, ok := s.readers.Load(key)
+	if ok {
+		return val.(*Collection)
+	}
+
+	// create collection
+	coll := &Collection{
+		coll: s.DB().Collection(meta.Collection, options.Collection().SetReadPreference(pref)),
+	}
+
+	// cache collection
+	s.readers.Store(key, coll)
+
+	return coll
+}
+
 // M will return the manager for the specified model. The manager will translate
 // query and update documents as well as perform extensive checks before running
 // operations to ensure they are as safe as possible.
@@ -177,6 +221,7 @@ func (s *Store) M(model Model) *Manager {
 	// create manager
 	manager := &Manager{
 		meta:  meta,
+		store: s,
 		coll:  s.C(model),
 		trans: NewTranslator(model),
 	}
@@ -338,6 +383,11 @@ func (s *Store) Close() error {
 	return nil
 }
 
+type readerKey struct {
+	meta *Meta
+	pref string
+}
+
 type contextKey struct{}
 
 var hasTransaction = contextKey{}
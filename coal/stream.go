@@ -1,8 +1,18 @@
 package coal
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/256dpi/lungo/bsonkit"
+	"github.com/256dpi/lungo/mongokit"
 	"github.com/256dpi/xo"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readconcern"
 	"gopkg.in/tomb.v2"
@@ -21,7 +31,8 @@ type Event string
 const (
 	// Opened is emitted when the stream has been opened the first time. If the
 	// receiver returns without and error it will not be emitted again in favor
-	// of the resumed event.
+	// of the resumed event. It is emitted again if the stream had to be
+	// restarted because the resume token expired.
 	Opened Event = "opened"
 
 	// Resumed is emitted after the stream has been resumed.
@@ -44,9 +55,99 @@ const (
 	Stopped Event = "stopped"
 )
 
+var invalidationTypes = bson.A{"drop", "rename", "renamed", "dropDatabase", "invalidate"}
+
 // Receiver is a callback that receives stream events.
 type Receiver func(event Event, id ID, model Model, err error, token []byte) error
 
+// Checkpointer stores the resume tokens of named streams.
+type Checkpointer interface {
+	// Load should return the stored token of the named stream or nil if none
+	// is available.
+	Load(ctx context.Context, name string) ([]byte, error)
+
+	// Save should store the provided token of the named stream. A nil token
+	// should remove the stored token.
+	Save(ctx context.Context, name string, token []byte) error
+}
+
+// Change describes a single document change.
+type Change struct {
+	// The change event.
+	Event Event
+
+	// The document id.
+	ID ID
+
+	// The document after the change for created and updated events.
+	Model Model
+
+	// The document before the change for updated and deleted events if
+	// pre-images are enabled and available.
+	Before Model
+
+	// The resume token of the change.
+	Token []byte
+}
+
+// StreamOptions defines additional stream options.
+type StreamOptions struct {
+	// The server-side filter applied to the documents of created and updated
+	// events. Deleted events are filtered using the pre-image if enabled and
+	// are otherwise not filtered. Updated documents that stop matching the
+	// filter are not reported.
+	Filter bson.M
+
+	// The events that should be received. If empty, all document events are
+	// received.
+	Events []Event
+
+	// The fields of the documents that should be received. If empty, all
+	// fields are received.
+	Fields []string
+
+	// Whether the document before updates and deletes should be requested.
+	// Pre-images must be enabled on the collection and are only yielded to a
+	// batch receiver. Pre-images are not supported by lungo.
+	PreImages bool
+
+	// The receiver for batches of changes. If set, document events are not
+	// yielded to the regular receiver. A batch is yielded when it is full or
+	// no further changes are available within the batch timeout.
+	Batch func(changes []Change) error
+
+	// The maximum size of a batch.
+	//
+	// Default: 100.
+	BatchSize int
+
+	// The time to wait for further changes before a batch is yielded.
+	//
+	// Default: 1s.
+	BatchTimeout time.Duration
+
+	// The name of the stream used to store resume tokens. A name alone does not
+	// enable checkpoints, a checkpointer must be set as well.
+	Name string
+
+	// The checkpointer used to load and store the resume token of the named
+	// stream. If a token is available the stream is resumed and emits the
+	// resumed event instead of the opened event. If the token has expired the
+	// stream is restarted and emits the opened event.
+	//
+	// The glut package provides a durable implementation that is created using
+	// glut.NewCheckpointer. It is not used by default as glut is built on top of
+	// this package and cannot be imported here. Streams without a checkpointer
+	// always start at the current time.
+	Checkpointer Checkpointer
+
+	// The minimum time between two checkpoints. The token is always stored
+	// when the stream is stopped.
+	//
+	// Default: 0 (every change).
+	CheckpointInterval time.Duration
+}
+
 // Stream simplifies the handling of change streams to receive changes to
 // documents.
 type Stream struct {
@@ -54,25 +155,62 @@ type Stream struct {
 	model    Model
 	token    []byte
 	receiver Receiver
-
-	opened bool
-	tomb   tomb.Tomb
+	opts     StreamOptions
+	pipeline []bson.D
+
+	opened   bool
+	restored bool
+	saved    []byte
+	lastSave time.Time
+	tomb     tomb.Tomb
 }
 
 // OpenStream will open a stream and continuously forward events to the specified
 // receiver until the stream is closed. If a token is present it will be used to
-// resume the stream.
+// resume the stream. Additional options may be provided to filter and project
+// the changes or receive them in batches.
 //
 // The stream automatically resumes on errors using an internally stored resume
-// token. Applications that need more control should store the token externally
-// and reopen the stream manually to resume from a specific position.
-func OpenStream(store *Store, model Model, token []byte, receiver Receiver) *Stream {
+// token. If the stream is named and a checkpointer is configured, the token is
+// also stored externally to resume the stream after a restart. If that token
+// has expired, the stream is restarted and emits the opened event again to
+// allow a full resync.
+//
+// Note: This function panics if the options are invalid.
+func OpenStream(store *Store, model Model, token []byte, receiver Receiver, opts ...StreamOptions) *Stream {
+	// get options
+	var opt StreamOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	// set defaults
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = 100
+	}
+	if opt.BatchTimeout <= 0 {
+		opt.BatchTimeout = time.Second
+	}
+
+	// check checkpointer
+	if opt.Checkpointer != nil && opt.Name == "" {
+		panic("coal: missing stream name")
+	}
+
+	// prepare pipeline
+	pipeline, err := streamPipeline(model, opt)
+	if err != nil {
+		panic(err.Error())
+	}
+
 	// create stream
 	s := &Stream{
 		store:    store,
 		model:    model,
 		token:    token,
 		receiver: receiver,
+		opts:     opt,
+		pipeline: pipeline,
 	}
 
 	// open stream
@@ -92,37 +230,144 @@ func (s *Stream) open() error {
 	for {
 		// check if alive
 		if !s.tomb.Alive() {
-			return xo.W(s.receiver(Stopped, "", nil, nil, s.token))
+			return s.stop()
 		}
 
 		// tail stream
 		err := s.tail()
 		if ErrStop.Is(err) {
-			return xo.W(s.receiver(Stopped, "", nil, nil, s.token))
+			return s.stop()
 		} else if err != nil {
 			err = xo.W(s.receiver(Errored, "", nil, err, s.token))
 			if ErrStop.Is(err) {
-				return xo.W(s.receiver(Stopped, "", nil, nil, s.token))
+				return s.stop()
 			}
 		}
 	}
 }
 
+func (s *Stream) stop() error {
+	// prepare context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// store token
+	err := s.checkpoint(ctx, true)
+	if err != nil {
+		_ = s.receiver(Errored, "", nil, err, s.token)
+	}
+
+	return xo.W(s.receiver(Stopped, "", nil, nil, s.token))
+}
+
+func (s *Stream) restore(ctx context.Context) error {
+	// check state
+	if s.restored || s.opts.Checkpointer == nil {
+		return nil
+	}
+
+	// load token if not provided
+	if s.token == nil {
+		token, err := s.opts.Checkpointer.Load(ctx, s.opts.Name)
+		if err != nil {
+			return err
+		}
+		s.token = token
+		s.saved = token
+
+		// resume instead of opening if a token is available
+		if token != nil {
+			s.opened = true
+		}
+	}
+
+	// set flag
+	s.restored = true
+
+	return nil
+}
+
+func (s *Stream) advance(ctx context.Context, token []byte) error {
+	// set token
+	s.token = token
+
+	return s.checkpoint(ctx, false)
+}
+
+func (s *Stream) checkpoint(ctx context.Context, force bool) error {
+	// check checkpointer and token
+	if s.opts.Checkpointer == nil || !s.restored || bytes.Equal(s.token, s.saved) {
+		return nil
+	}
+
+	// check interval
+	if !force && s.opts.CheckpointInterval > 0 && time.Since(s.lastSave) < s.opts.CheckpointInterval {
+		return nil
+	}
+
+	// save token
+	err := s.opts.Checkpointer.Save(ctx, s.opts.Name, s.token)
+	if err != nil {
+		return err
+	}
+
+	// update state
+	s.saved = s.token
+	s.lastSave = time.Now()
+
+	return nil
+}
+
+func (s *Stream) reset(ctx context.Context) error {
+	// reset token and state to restart the stream
+	s.token = nil
+	s.opened = false
+
+	return s.checkpoint(ctx, true)
+}
+
 func (s *Stream) tail() error {
 	// prepare context
 	ctx := s.tomb.Context(nil)
 
+	// restore token
+	err := s.restore(ctx)
+	if err != nil {
+		return xo.W(err)
+	}
+
 	// prepare opts
 	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
 	if s.token != nil {
 		opts.SetResumeAfter(bson.Raw(s.token))
 	}
+	if s.opts.PreImages && !s.store.Lungo() {
+		opts.SetFullDocumentBeforeChange(options.WhenAvailable)
+	}
+	if s.opts.Batch != nil {
+		opts.SetMaxAwaitTime(s.opts.BatchTimeout)
+	}
 
 	// get collection
 	coll := s.store.DB().Collection(GetMeta(s.model).Collection, options.Collection().SetReadConcern(readconcern.Majority()))
 
+	// prepare pipeline
+	pipeline := s.pipeline
+	if pipeline == nil {
+		pipeline = []bson.D{}
+	}
+
 	// open change stream
-	cs, err := coll.Watch(ctx, []bson.M{}, opts)
+	cs, err := coll.Watch(ctx, pipeline, opts)
+	if s.opts.Checkpointer != nil && s.token != nil && isHistoryLost(err) {
+		// restart stream if token has expired
+		err = s.reset(ctx)
+		if err != nil {
+			return xo.W(err)
+		}
+		opts.SetResumeAfter(nil)
+		cs, err = coll.Watch(ctx, pipeline, opts)
+	}
 	if err != nil {
 		return xo.W(err)
 	}
@@ -148,13 +393,67 @@ func (s *Stream) tail() error {
 	// set flag
 	s.opened = true
 
+	// prepare batch
+	var batch []Change
+	flush := func() error {
+		// check batch
+		if len(batch) == 0 {
+			return nil
+		}
+
+		// yield batch
+		err := s.opts.Batch(batch)
+		if err != nil {
+			return err
+		}
+
+		// save token
+		token := batch[len(batch)-1].Token
+		batch = nil
+
+		return s.advance(ctx, token)
+	}
+
 	// iterate on elements forever
-	for cs.Next(ctx) {
+	for {
+		// await next change or yield pending batch
+		if len(batch) == 0 {
+			if !cs.Next(ctx) {
+				break
+			}
+		} else if !cs.TryNext(ctx) {
+			if cs.Err() != nil {
+				break
+			}
+			err = flush()
+			if err != nil {
+				return xo.W(err)
+			}
+			continue
+		}
+
+		// get result
+		var raw bson.Raw
+		err = cs.Decode(&raw)
+		if err != nil {
+			return xo.W(err)
+		}
+
 		// decode result
 		var ch change
-		err = cs.Decode(&ch)
+		ok, err := s.decode(raw, &ch)
 		if err != nil {
 			return xo.W(err)
+		} else if !ok {
+			// save token
+			if len(batch) == 0 {
+				err = s.advance(ctx, ch.ResumeToken)
+				if err != nil {
+					return xo.W(err)
+				}
+			}
+
+			continue
 		}
 
 		// prepare type
@@ -183,7 +482,12 @@ func (s *Stream) tail() error {
 			// to a following a delete or drop event
 			if locked || len(ch.FullDocument) == 0 {
 				// save token
-				s.token = ch.ResumeToken
+				if len(batch) == 0 {
+					err = s.advance(ctx, ch.ResumeToken)
+					if err != nil {
+						return xo.W(err)
+					}
+				}
 
 				continue
 			}
@@ -196,6 +500,38 @@ func (s *Stream) tail() error {
 			}
 		}
 
+		// add change to batch if available
+		if s.opts.Batch != nil {
+			// decode pre-image
+			var before Model
+			if len(ch.FullDocumentBeforeChange) > 0 {
+				before = GetMeta(s.model).Make()
+				err = bson.Unmarshal(ch.FullDocumentBeforeChange, before)
+				if err != nil {
+					return xo.W(err)
+				}
+			}
+
+			// add change
+			batch = append(batch, Change{
+				Event:  event,
+				ID:     ch.DocumentKey.ID,
+				Model:  doc,
+				Before: before,
+				Token:  ch.ResumeToken,
+			})
+
+			// yield full batch
+			if len(batch) >= s.opts.BatchSize {
+				err = flush()
+				if err != nil {
+					return xo.W(err)
+				}
+			}
+
+			continue
+		}
+
 		// call receiver
 		err = s.receiver(event, ch.DocumentKey.ID, doc, nil, ch.ResumeToken)
 		if err != nil {
@@ -203,7 +539,18 @@ func (s *Stream) tail() error {
 		}
 
 		// save token
-		s.token = ch.ResumeToken
+		err = s.advance(ctx, ch.ResumeToken)
+		if err != nil {
+			return xo.W(err)
+		}
+	}
+
+	// restart stream if token has expired
+	if s.opts.Checkpointer != nil && isHistoryLost(cs.Err()) {
+		err = s.reset(ctx)
+		if err != nil {
+			return xo.W(err)
+		}
 	}
 
 	// close stream and check error
@@ -215,14 +562,203 @@ func (s *Stream) tail() error {
 	return nil
 }
 
+func (s *Stream) decode(raw bson.Raw, ch *change) (bool, error) {
+	// apply pipeline manually as lungo ignores it
+	if s.store.Lungo() && len(s.pipeline) > 0 {
+		// transform change
+		doc, err := bsonkit.Transform(raw)
+		if err != nil {
+			return false, err
+		}
+
+		// apply stages
+		for _, stage := range s.pipeline {
+			// transform stage
+			value, err := bsonkit.Transform(stage[0].Value)
+			if err != nil {
+				return false, err
+			}
+
+			// apply stage
+			switch stage[0].Key {
+			case "$match":
+				ok, err := mongokit.Match(doc, value)
+				if err != nil {
+					return false, err
+				} else if !ok {
+					ch.ResumeToken, _ = bson.Marshal(bsonkit.Get(doc, "_id"))
+					return false, nil
+				}
+			case "$project":
+				doc, err = mongokit.Project(doc, value)
+				if err != nil {
+					return false, err
+				}
+			}
+		}
+
+		// encode change
+		raw, err = bson.Marshal(doc)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// decode change
+	err := bson.Unmarshal(raw, ch)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func streamPipeline(model Model, opts StreamOptions) ([]bson.D, error) {
+	// prepare translator
+	trans := NewTranslator(model)
+
+	// collect conditions
+	var conditions bson.A
+
+	// add events
+	if len(opts.Events) > 0 {
+		types := append(bson.A{}, invalidationTypes...)
+		for _, event := range opts.Events {
+			switch event {
+			case Created:
+				types = append(types, "insert")
+			case Updated:
+				types = append(types, "update", "replace")
+			case Deleted:
+				types = append(types, "delete")
+			default:
+				return nil, xo.F("unsupported event %q", event)
+			}
+		}
+		conditions = append(conditions, bson.D{
+			{Key: "operationType", Value: bson.D{{Key: "$in", Value: types}}},
+		})
+	}
+
+	// add filter
+	if opts.Filter != nil {
+		// translate filter
+		filter, err := trans.Document(opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+
+		// prepare delete condition
+		deleted := bson.D{{Key: "operationType", Value: "delete"}}
+		if opts.PreImages {
+			deleted = append(deleted, prefixFilter(filter, "fullDocumentBeforeChange.")...)
+		}
+
+		// add condition
+		conditions = append(conditions, bson.D{
+			{Key: "$or", Value: bson.A{
+				append(bson.D{
+					{Key: "operationType", Value: bson.D{{Key: "$in", Value: bson.A{"insert", "update", "replace"}}}},
+				}, prefixFilter(filter, "fullDocument.")...),
+				deleted,
+				bson.D{{Key: "operationType", Value: bson.D{{Key: "$in", Value: invalidationTypes}}}},
+			}},
+		})
+	}
+
+	// prepare pipeline
+	var pipeline []bson.D
+	if len(conditions) > 0 {
+		pipeline = append(pipeline, bson.D{
+			{Key: "$match", Value: bson.D{{Key: "$and", Value: conditions}}},
+		})
+	}
+
+	// add projection
+	if len(opts.Fields) > 0 {
+		// prepare projection
+		projection := bson.D{
+			{Key: "_id", Value: 1},
+			{Key: "operationType", Value: 1},
+			{Key: "documentKey", Value: 1},
+			{Key: "updateDescription", Value: 1},
+			{Key: "fullDocument._id", Value: 1},
+		}
+		if opts.PreImages {
+			projection = append(projection, bson.E{Key: "fullDocumentBeforeChange._id", Value: 1})
+		}
+
+		// add fields
+		for _, name := range opts.Fields {
+			field, err := trans.Field(name)
+			if err != nil {
+				return nil, err
+			}
+			projection = append(projection, bson.E{Key: "fullDocument." + field, Value: 1})
+			if opts.PreImages {
+				projection = append(projection, bson.E{Key: "fullDocumentBeforeChange." + field, Value: 1})
+			}
+		}
+
+		// add stage
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: projection}})
+	}
+
+	return pipeline, nil
+}
+
+func prefixFilter(filter bson.D, prefix string) bson.D {
+	// prefix fields and recurse into logical operators
+	result := make(bson.D, 0, len(filter))
+	for _, pair := range filter {
+		switch pair.Key {
+		case "$and", "$or", "$nor":
+			list, _ := pair.Value.(bson.A)
+			items := make(bson.A, 0, len(list))
+			for _, item := range list {
+				if doc, ok := item.(bson.D); ok {
+					item = prefixFilter(doc, prefix)
+				}
+				items = append(items, item)
+			}
+			result = append(result, bson.E{Key: pair.Key, Value: items})
+		default:
+			if len(pair.Key) > 0 && pair.Key[0] == '$' {
+				result = append(result, pair)
+			} else {
+				result = append(result, bson.E{Key: fmt.Sprintf("%s%s", prefix, pair.Key), Value: pair.Value})
+			}
+		}
+	}
+
+	return result
+}
+
+func isHistoryLost(err error) bool {
+	// check error
+	if err == nil {
+		return false
+	}
+
+	// check server error
+	var srvErr mongo.ServerError
+	if errors.As(err, &srvErr) {
+		return srvErr.HasErrorCode(286) || srvErr.HasErrorCode(280)
+	}
+
+	// check lungo error
+	return strings.Contains(err.Error(), "unable to resume change stream")
+}
+
 type change struct {
 	ResumeToken   bson.Raw `bson:"_id"`
 	OperationType string   `bson:"operationType"`
 	DocumentKey   struct {
 		ID ID `bson:"_id"`
 	} `bson:"documentKey"`
-	FullDocument      bson.Raw `bson:"fullDocument"`
-	UpdateDescription struct {
+	FullDocument             bson.Raw `bson:"fullDocument"`
+	FullDocumentBeforeChange bson.Raw `bson:"fullDocumentBeforeChange"`
+	UpdateDescription        struct {
 		UpdatedFields bson.M   `bson:"updatedFields"`
 		RemovedFields []string `bson:"removedFields"`
 	} `bson:"updateDescription"`
@@ -482,3 +482,326 @@ func TestStreamInvalidation(t *testing.T) {
 		stream.Close()
 	})
 }
+
+func TestStreamOptions(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		time.Sleep(100 * time.Millisecond)
+
+		open := make(chan struct{})
+		done := make(chan struct{})
+
+		var events []Event
+		var models []*postModel
+		stream := OpenStream(tester.Store, &postModel{}, nil, func(e Event, id ID, model Model, err error, token []byte) error {
+			switch e {
+			case Opened:
+				close(open)
+			case Created, Updated, Deleted:
+				events = append(events, e)
+				models = append(models, model.(*postModel))
+				if len(events) == 2 {
+					return ErrStop.Wrap()
+				}
+			case Stopped:
+				close(done)
+			}
+
+			return nil
+		}, StreamOptions{
+			Filter: bson.M{"Title": "foo"},
+			Events: []Event{Updated},
+			Fields: []string{"Title"},
+		})
+
+		<-open
+
+		post1 := tester.Insert(&postModel{Title: "foo", TextBody: "foo"}).(*postModel)
+		post2 := tester.Insert(&postModel{Title: "bar", TextBody: "bar"}).(*postModel)
+
+		post2.Published = true
+		tester.Replace(post2)
+
+		post1.Published = true
+		tester.Replace(post1)
+
+		tester.Delete(post1)
+
+		post1 = tester.Insert(&postModel{Title: "foo"}).(*postModel)
+		post1.Published = true
+		tester.Replace(post1)
+
+		<-done
+
+		stream.Close()
+
+		assert.Equal(t, []Event{Updated, Updated}, events)
+		assert.Equal(t, "foo", models[0].Title)
+		assert.False(t, models[0].Published)
+		assert.Empty(t, models[0].TextBody)
+		assert.Equal(t, post1.ID(), models[1].ID())
+	})
+}
+
+func TestStreamBatch(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		time.Sleep(100 * time.Millisecond)
+
+		open := make(chan struct{})
+		done := make(chan struct{})
+
+		var batches [][]Change
+		stream := OpenStream(tester.Store, &postModel{}, nil, func(e Event, id ID, model Model, err error, token []byte) error {
+			switch e {
+			case Opened:
+				close(open)
+			case Created, Updated, Deleted:
+				panic("unexpected event")
+			case Stopped:
+				close(done)
+			}
+
+			return nil
+		}, StreamOptions{
+			Batch: func(changes []Change) error {
+				batches = append(batches, changes)
+				var total int
+				for _, batch := range batches {
+					total += len(batch)
+				}
+				if total >= 5 {
+					return ErrStop.Wrap()
+				}
+				return nil
+			},
+			BatchSize:    2,
+			BatchTimeout: 10 * time.Millisecond,
+		})
+
+		<-open
+
+		for i := 0; i < 4; i++ {
+			tester.Insert(&postModel{Title: "foo"})
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		post := tester.Insert(&postModel{Title: "bar"}).(*postModel)
+
+		<-done
+
+		stream.Close()
+
+		var changes []Change
+		for _, batch := range batches {
+			assert.True(t, len(batch) <= 2)
+			changes = append(changes, batch...)
+		}
+		assert.Len(t, changes, 5)
+		assert.Equal(t, Created, changes[4].Event)
+		assert.Equal(t, post.ID(), changes[4].ID)
+		assert.Equal(t, "bar", changes[4].Model.(*postModel).Title)
+		assert.Nil(t, changes[4].Before)
+		assert.NotEmpty(t, changes[4].Token)
+	})
+}
+
+func TestStreamInvalidOptions(t *testing.T) {
+	assert.PanicsWithValue(t, `unknown field "Foo"`, func() {
+		OpenStream(nil, &postModel{}, nil, nil, StreamOptions{
+			Filter: bson.M{"Foo": "bar"},
+		})
+	})
+
+	assert.PanicsWithValue(t, `unsupported event "opened"`, func() {
+		OpenStream(nil, &postModel{}, nil, nil, StreamOptions{
+			Events: []Event{Opened},
+		})
+	})
+
+	assert.PanicsWithValue(t, `coal: missing stream name`, func() {
+		OpenStream(nil, &postModel{}, nil, nil, StreamOptions{
+			Checkpointer: &memoryCheckpointer{},
+		})
+	})
+}
+
+type memoryCheckpointer struct {
+	tokens map[string][]byte
+	saves  int
+}
+
+func (c *memoryCheckpointer) Load(_ context.Context, name string) ([]byte, error) {
+	return c.tokens[name], nil
+}
+
+func (c *memoryCheckpointer) Save(_ context.Context, name string, token []byte) error {
+	c.tokens[name] = token
+	c.saves++
+	return nil
+}
+
+func TestStreamCheckpoint(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		time.Sleep(100 * time.Millisecond)
+
+		checkpointer := &memoryCheckpointer{tokens: map[string][]byte{}}
+
+		open := make(chan struct{})
+		created := make(chan struct{})
+
+		var events []Event
+		var last []byte
+		stream := OpenStream(tester.Store, &postModel{}, nil, func(e Event, id ID, model Model, err error, token []byte) error {
+			events = append(events, e)
+
+			switch e {
+			case Opened:
+				close(open)
+			case Created:
+				last = token
+				close(created)
+			}
+
+			return nil
+		}, StreamOptions{
+			Name:         "posts",
+			Checkpointer: checkpointer,
+		})
+
+		<-open
+
+		tester.Insert(&postModel{Title: "foo"})
+
+		<-created
+
+		stream.Close()
+
+		assert.Equal(t, []Event{Opened, Created, Stopped}, events)
+		assert.NotEmpty(t, last)
+		assert.Equal(t, last, checkpointer.tokens["posts"])
+
+		resumed := make(chan struct{})
+		created = make(chan struct{})
+
+		events = nil
+		stream = OpenStream(tester.Store, &postModel{}, nil, func(e Event, id ID, model Model, err error, token []byte) error {
+			events = append(events, e)
+
+			switch e {
+			case Resumed:
+				assert.Equal(t, last, token)
+				close(resumed)
+			case Created:
+				assert.Equal(t, "bar", model.(*postModel).Title)
+				last = token
+				close(created)
+			}
+
+			return nil
+		}, StreamOptions{
+			Name:         "posts",
+			Checkpointer: checkpointer,
+		})
+
+		<-resumed
+
+		tester.Insert(&postModel{Title: "bar"})
+
+		<-created
+
+		stream.Close()
+
+		assert.Equal(t, []Event{Resumed, Created, Stopped}, events)
+		assert.Equal(t, last, checkpointer.tokens["posts"])
+	})
+}
+
+func TestStreamCheckpointInterval(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		time.Sleep(100 * time.Millisecond)
+
+		checkpointer := &memoryCheckpointer{tokens: map[string][]byte{}}
+
+		open := make(chan struct{})
+		created := make(chan struct{})
+
+		var last []byte
+		i := 0
+		stream := OpenStream(tester.Store, &postModel{}, nil, func(e Event, id ID, model Model, err error, token []byte) error {
+			switch e {
+			case Opened:
+				close(open)
+			case Created:
+				i++
+				if i == 3 {
+					last = token
+					close(created)
+				}
+			}
+
+			return nil
+		}, StreamOptions{
+			Name:               "posts",
+			Checkpointer:       checkpointer,
+			CheckpointInterval: time.Hour,
+		})
+
+		<-open
+
+		for i := 0; i < 3; i++ {
+			tester.Insert(&postModel{Title: "foo"})
+		}
+
+		<-created
+
+		stream.Close()
+
+		assert.Equal(t, 2, checkpointer.saves)
+		assert.Equal(t, last, checkpointer.tokens["posts"])
+	})
+}
+
+func TestStreamCheckpointExpired(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		// expired tokens can only be simulated with lungo
+		if !tester.Store.Lungo() {
+			return
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		token, err := bson.Marshal(bson.M{"ts": 1, "n": 1})
+		assert.NoError(t, err)
+
+		checkpointer := &memoryCheckpointer{tokens: map[string][]byte{
+			"posts": token,
+		}}
+
+		done := make(chan struct{})
+
+		var events []Event
+		stream := OpenStream(tester.Store, &postModel{}, nil, func(e Event, id ID, model Model, err error, token []byte) error {
+			events = append(events, e)
+
+			switch e {
+			case Opened:
+				assert.Nil(t, token)
+				return ErrStop.Wrap()
+			case Stopped:
+				close(done)
+			}
+
+			return nil
+		}, StreamOptions{
+			Name:         "posts",
+			Checkpointer: checkpointer,
+		})
+
+		<-done
+
+		stream.Close()
+
+		assert.Equal(t, []Event{Opened, Stopped}, events)
+		assert.Nil(t, checkpointer.tokens["posts"])
+	})
+}
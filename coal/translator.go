@@ -1,12 +1,15 @@
 package coal
 
 import (
+	"reflect"
 	"strings"
 
 	"github.com/256dpi/lungo/bsonkit"
 	"github.com/256dpi/xo"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/256dpi/fire/stick"
 )
 
 var unsafeOperators = map[string]bool{
@@ -23,6 +26,7 @@ var unsafeOperators = map[string]bool{
 var systemFields = map[string]bool{
 	"_id": true,
 	"_lk": true,
+	"_v":  true,
 }
 
 // Translator is capable of translating query, update and sort documents from
@@ -30,7 +34,8 @@ var systemFields = map[string]bool{
 // as list of unsafe operators. Field names may be prefixed with a "#" to bypass
 // any validation.
 type Translator struct {
-	meta *Meta
+	meta    *Meta
+	outputs map[string]bool
 }
 
 // NewTranslator will return a translator for the specified model.
@@ -50,6 +55,19 @@ func (t *Translator) Field(field string) (string, error) {
 	return field, nil
 }
 
+// Path will translate the specified dot separated field path. The first
+// segment must be a field of the model and following segments may address
+// fields of embedded structs, array indexes or array positional operators
+// e.g. "Items.0.Quantity" or "Items.$[].Quantity".
+func (t *Translator) Path(path string) (string, error) {
+	path, _, err := t.path(path)
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
 // Document will convert the provided filter or update document and translate
 // all field names to refer to known database fields. It will also validate the
 // query or update and return an error for unsafe expressions or operators.
@@ -84,6 +102,228 @@ func (t *Translator) Sort(fields []string) (bson.D, error) {
 	return doc, nil
 }
 
+// Pipeline will translate the field names of the provided aggregation pipeline.
+// Field names are translated in "$match", "$sort", "$group", "$project",
+// "$lookup" and "$unwind" stages and field path expressions like "$Title" are
+// translated in "$group" and "$project" stages. Translation stops after the
+// first "$group" or "$project" stage, as the following stages refer to the
+// output fields. A "$sort" stage may be specified as a list of fields or a
+// document. The "from" value of a "$lookup" stage may be a model to also
+// translate the foreign field. The "as" field of a "$lookup" stage and paths
+// below it are passed through untranslated in the following stages. Other
+// stages are not translated.
+func (t *Translator) Pipeline(pipeline []bson.M) (bson.A, error) {
+	// copy translator to track lookup outputs
+	t = &Translator{
+		meta:    t.meta,
+		outputs: map[string]bool{},
+	}
+
+	// translate stages
+	var raw bool
+	result := make(bson.A, 0, len(pipeline))
+	for _, stage := range pipeline {
+		// check stage
+		if len(stage) != 1 {
+			return nil, xo.F("invalid pipeline stage")
+		}
+
+		// get operator and value
+		var op string
+		var value interface{}
+		for op, value = range stage {
+		}
+
+		// keep stage if raw
+		if raw {
+			result = append(result, stage)
+			continue
+		}
+
+		// translate stage
+		var err error
+		switch op {
+		case "$match":
+			filter, ok := value.(bson.M)
+			if !ok {
+				return nil, xo.F("invalid $match stage")
+			}
+			value, err = t.Document(filter)
+		case "$sort":
+			value, err = t.pipelineSort(value)
+		case "$group":
+			value, err = t.expression(value)
+			raw = true
+		case "$project":
+			value, err = t.projection(value)
+			raw = true
+		case "$lookup":
+			value, err = t.lookup(value)
+			if err == nil {
+				if as, ok := value.(bson.M)["as"].(string); ok {
+					t.outputs[as] = true
+				}
+			}
+		case "$unwind":
+			value, err = t.expression(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// add stage
+		result = append(result, bson.D{{Key: op, Value: value}})
+	}
+
+	return result, nil
+}
+
+func (t *Translator) pipelineSort(value interface{}) (bson.D, error) {
+	// handle value
+	switch value := value.(type) {
+	case []string:
+		return t.Sort(value)
+	case bson.D:
+		doc := make(bson.D, 0, len(value))
+		for _, pair := range value {
+			field, err := t.Field(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, bson.E{Key: field, Value: pair.Value})
+		}
+		return doc, nil
+	case bson.M:
+		if len(value) > 1 {
+			return nil, xo.F("ambiguous $sort stage")
+		}
+		doc := make(bson.D, 0, len(value))
+		for key, direction := range value {
+			field, err := t.Field(key)
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, bson.E{Key: field, Value: direction})
+		}
+		return doc, nil
+	}
+
+	return nil, xo.F("invalid $sort stage")
+}
+
+func (t *Translator) projection(value interface{}) (bson.M, error) {
+	// check value
+	doc, ok := value.(bson.M)
+	if !ok {
+		return nil, xo.F("invalid $project stage")
+	}
+
+	// translate keys of known fields and expressions
+	result := make(bson.M, len(doc))
+	for key, val := range doc {
+		if field, err := t.Field(key); err == nil {
+			key = field
+		}
+		expr, err := t.expression(val)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = expr
+	}
+
+	return result, nil
+}
+
+func (t *Translator) lookup(value interface{}) (bson.M, error) {
+	// check value
+	doc, ok := value.(bson.M)
+	if !ok {
+		return nil, xo.F("invalid $lookup stage")
+	}
+
+	// copy document
+	result := make(bson.M, len(doc))
+	for key, val := range doc {
+		result[key] = val
+	}
+
+	// translate local field
+	if localField, ok := doc["localField"].(string); ok {
+		field, err := t.Field(localField)
+		if err != nil {
+			return nil, err
+		}
+		result["localField"] = field
+	}
+
+	// translate foreign field if a model is specified
+	if model, ok := doc["from"].(Model); ok {
+		result["from"] = GetMeta(model).Collection
+		if foreignField, ok := doc["foreignField"].(string); ok {
+			field, err := NewTranslator(model).Field(foreignField)
+			if err != nil {
+				return nil, err
+			}
+			result["foreignField"] = field
+		}
+	}
+
+	return result, nil
+}
+
+func (t *Translator) expression(value interface{}) (interface{}, error) {
+	// handle value
+	switch value := value.(type) {
+	case string:
+		// check field path
+		if !strings.HasPrefix(value, "$") || strings.HasPrefix(value, "$$") {
+			return value, nil
+		}
+
+		// translate first segment
+		segments := strings.SplitN(value[1:], ".", 2)
+		field, err := t.Field(segments[0])
+		if err != nil {
+			return nil, err
+		}
+		segments[0] = field
+
+		return "$" + strings.Join(segments, "."), nil
+	case bson.M:
+		result := make(bson.M, len(value))
+		for key, val := range value {
+			expr, err := t.expression(val)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = expr
+		}
+		return result, nil
+	case bson.D:
+		result := make(bson.D, 0, len(value))
+		for _, pair := range value {
+			expr, err := t.expression(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, bson.E{Key: pair.Key, Value: expr})
+		}
+		return result, nil
+	case bson.A:
+		result := make(bson.A, 0, len(value))
+		for _, item := range value {
+			expr, err := t.expression(item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, expr)
+		}
+		return result, nil
+	}
+
+	return value, nil
+}
+
 func (t *Translator) value(value interface{}, skipTranslation bool) error {
 	// translate document
 	if doc, ok := value.(bson.D); ok {
@@ -143,6 +383,11 @@ func (t *Translator) field(field *string) error {
 		return nil
 	}
 
+	// check if lookup output
+	if t.outputs[strings.SplitN(*field, ".", 2)[0]] {
+		return nil
+	}
+
 	// check if system
 	if systemFields[*field] {
 		return nil
@@ -162,6 +407,72 @@ func (t *Translator) field(field *string) error {
 	return nil
 }
 
+func (t *Translator) path(path string) (string, reflect.Type, error) {
+	// split path
+	segments := strings.Split(path, ".")
+
+	// translate first segment
+	first := segments[0]
+	err := t.field(&segments[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	// get type
+	var typ reflect.Type
+	if field := t.meta.DatabaseFields[segments[0]]; field != nil {
+		typ = field.Type
+	} else if strings.HasPrefix(first, "#") {
+		return strings.Join(segments, "."), nil, nil
+	}
+
+	// translate remaining segments
+	for i := 1; i < len(segments); i++ {
+		// check type
+		if typ == nil {
+			return "", nil, xo.F("invalid path %q", path)
+		}
+
+		// unwrap pointers
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+
+		// handle segment
+		segment := segments[i]
+		switch typ.Kind() {
+		case reflect.Slice, reflect.Array:
+			// check index or positional operator
+			_, ok := bsonkit.ParseIndex(segment)
+			if !ok && segment != "$" && !(strings.HasPrefix(segment, "$[") && strings.HasSuffix(segment, "]")) {
+				return "", nil, xo.F("invalid array segment %q in path %q", segment, path)
+			}
+			typ = typ.Elem()
+		case reflect.Map:
+			typ = typ.Elem()
+		case reflect.Struct:
+			// find field
+			structField, ok := typ.FieldByName(segment)
+			if !ok || !structField.IsExported() {
+				return "", nil, xo.F("unknown field %q in path %q", segment, path)
+			}
+
+			// get key
+			key := stick.BSON.GetKey(structField)
+			if key == "" {
+				return "", nil, xo.F("virtual field %q in path %q", segment, path)
+			}
+
+			segments[i] = key
+			typ = structField.Type
+		default:
+			return "", nil, xo.F("invalid path %q", path)
+		}
+	}
+
+	return strings.Join(segments, "."), typ, nil
+}
+
 func (t *Translator) convert(in bson.M) (bson.D, error) {
 	// attempt fast conversion
 	doc, err := bsonkit.Convert(in)
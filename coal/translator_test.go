@@ -317,3 +317,113 @@ func BenchmarkTranslatorSort(b *testing.B) {
 		}
 	}
 }
+
+func TestTranslatorPipeline(t *testing.T) {
+	trans := NewTranslator(&commentModel{})
+
+	// translated
+	doc, err := trans.Pipeline([]bson.M{
+		{"$match": bson.M{"Message": "foo"}},
+		{"$sort": []string{"-Message"}},
+		{"$lookup": bson.M{
+			"from":         &postModel{},
+			"localField":   "Post",
+			"foreignField": "_id",
+			"as":           "posts",
+		}},
+		{"$unwind": "$Post"},
+		{"$group": bson.M{
+			"_id":   "$Post",
+			"count": bson.M{"$sum": 1},
+			"last":  bson.M{"$last": "$Message"},
+			"root":  bson.M{"$first": "$$ROOT"},
+		}},
+		{"$sort": bson.M{"count": -1}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "message", Value: "foo"}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "message", Value: int32(-1)}}}},
+		bson.D{{Key: "$lookup", Value: bson.M{
+			"from":         "posts",
+			"localField":   "post_id",
+			"foreignField": "_id",
+			"as":           "posts",
+		}}},
+		bson.D{{Key: "$unwind", Value: "$post_id"}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":   "$post_id",
+			"count": bson.M{"$sum": 1},
+			"last":  bson.M{"$last": "$message"},
+			"root":  bson.M{"$first": "$$ROOT"},
+		}}},
+		bson.M{"$sort": bson.M{"count": -1}},
+	}, doc)
+
+	// lookup output
+	doc, err = trans.Pipeline([]bson.M{
+		{"$lookup": bson.M{
+			"from":         &postModel{},
+			"localField":   "Post",
+			"foreignField": "_id",
+			"as":           "post",
+		}},
+		{"$unwind": "$post"},
+		{"$match": bson.M{"post.title": "foo", "Message": "bar"}},
+		{"$sort": []string{"post.title", "-Message"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{
+		bson.D{{Key: "$lookup", Value: bson.M{
+			"from":         "posts",
+			"localField":   "post_id",
+			"foreignField": "_id",
+			"as":           "post",
+		}}},
+		bson.D{{Key: "$unwind", Value: "$post"}},
+		bson.D{{Key: "$match", Value: bson.D{{Key: "message", Value: "bar"}, {Key: "post.title", Value: "foo"}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "post.title", Value: int32(1)}, {Key: "message", Value: int32(-1)}}}},
+	}, doc)
+
+	_, err = trans.Field("post")
+	assert.Error(t, err)
+	// projection
+	doc, err = trans.Pipeline([]bson.M{
+		{"$project": bson.M{
+			"Message": 1,
+			"upper":   bson.M{"$toUpper": "$Message"},
+		}},
+		{"$match": bson.M{"upper": "FOO"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{
+		bson.D{{Key: "$project", Value: bson.M{
+			"message": 1,
+			"upper":   bson.M{"$toUpper": "$message"},
+		}}},
+		bson.M{"$match": bson.M{"upper": "FOO"}},
+	}, doc)
+
+	// sort document
+	doc, err = trans.Pipeline([]bson.M{
+		{"$sort": bson.D{{Key: "Message", Value: 1}, {Key: "Post", Value: -1}}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.A{
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "message", Value: 1}, {Key: "post_id", Value: -1}}}},
+	}, doc)
+
+	// errors
+	for _, pipeline := range [][]bson.M{
+		{{"$match": bson.M{"Foo": "bar"}}},
+		{{"$match": "foo"}},
+		{{"$sort": bson.M{"Message": 1, "Post": 1}}},
+		{{"$group": bson.M{"_id": "$Foo"}}},
+		{{"$lookup": bson.M{"localField": "Foo"}}},
+		{{"$match": bson.M{}, "$sort": bson.M{}}},
+	} {
+		doc, err = trans.Pipeline(pipeline)
+		assert.Error(t, err)
+		assert.Nil(t, doc)
+	}
+}
@@ -0,0 +1,161 @@
+package coal
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TypedManager wraps a manager to provide a type-safe API for a model. All
+// methods delegate to the underlying manager and retain its locking,
+// transaction and validation semantics.
+type TypedManager[T any, P interface {
+	*T
+	Model
+}] struct {
+	manager *Manager
+}
+
+// TM creates and returns a typed manager for the specified model type.
+//
+//	posts := coal.TM[Post](store)
+//	post, err := posts.Find(ctx, id, false)
+func TM[T any, P interface {
+	*T
+	Model
+}](store *Store) *TypedManager[T, P] {
+	return &TypedManager[T, P]{
+		manager: store.M(P(new(T))),
+	}
+}
+
+// M returns the underlying manager.
+func (m *TypedManager[T, P]) M() *Manager {
+	return m.manager
+}
+
+// Find will find the document with the specified id. It will return nil if no
+// document has been found.
+func (m *TypedManager[T, P]) Find(ctx context.Context, id ID, lock bool, flags ...Flags) (*T, error) {
+	// find model
+	model := new(T)
+	found, err := m.manager.Find(ctx, P(model), id, lock, flags...)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return model, nil
+}
+
+// FindFirst will find the first document that matches the specified filter. It
+// will return nil if no document has been found.
+func (m *TypedManager[T, P]) FindFirst(ctx context.Context, filter bson.M, sort []string, skip int64, lock bool, flags ...Flags) (*T, error) {
+	// find model
+	model := new(T)
+	found, err := m.manager.FindFirst(ctx, P(model), filter, sort, skip, lock, flags...)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return model, nil
+}
+
+// FindAll will find all documents that match the specified filter.
+func (m *TypedManager[T, P]) FindAll(ctx context.Context, filter bson.M, sort []string, skip, limit int64, lock bool, flags ...Flags) ([]*T, error) {
+	// find models
+	var list []*T
+	err := m.manager.FindAll(ctx, &list, filter, sort, skip, limit, lock, flags...)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// FindEach will find all documents that match the specified filter and yield
+// them to the provided function. Iteration is stopped when the function
+// returns an error, which is then returned.
+func (m *TypedManager[T, P]) FindEach(ctx context.Context, filter bson.M, sort []string, skip, limit int64, lock bool, fn func(*T) error, flags ...Flags) error {
+	// find models
+	iter, err := m.manager.FindEach(ctx, filter, sort, skip, limit, lock, flags...)
+	if err != nil {
+		return err
+	}
+
+	// ensure close
+	defer iter.Close()
+
+	// iterate models
+	for iter.Next() {
+		// decode model
+		model := new(T)
+		err = iter.Decode(P(model))
+		if err != nil {
+			return err
+		}
+
+		// yield model
+		err = fn(model)
+		if err != nil {
+			return err
+		}
+	}
+
+	return iter.Error()
+}
+
+// Count will count the documents that match the specified filter.
+func (m *TypedManager[T, P]) Count(ctx context.Context, filter bson.M, skip, limit int64, lock bool, flags ...Flags) (int64, error) {
+	return m.manager.Count(ctx, filter, skip, limit, lock, flags...)
+}
+
+// Insert will insert the provided model.
+func (m *TypedManager[T, P]) Insert(ctx context.Context, model *T, flags ...Flags) error {
+	return m.manager.Insert(ctx, P(model), flags...)
+}
+
+// InsertAll will insert the provided models.
+func (m *TypedManager[T, P]) InsertAll(ctx context.Context, models []*T, flags ...Flags) error {
+	// convert models
+	list := make([]Model, 0, len(models))
+	for _, model := range models {
+		list = append(list, P(model))
+	}
+
+	return m.manager.InsertAll(ctx, list, flags...)
+}
+
+// Update will update the document with the specified id and return the updated
+// document. It will return nil if no document has been found.
+//
+// The version of versioned models is not checked as the update is performed
+// using a zero model. Use Manager.Update with a loaded model to detect
+// conflicting writes.
+func (m *TypedManager[T, P]) Update(ctx context.Context, id ID, update bson.M, lock bool, flags ...Flags) (*T, error) {
+	// update model
+	model := new(T)
+	found, err := m.manager.Update(ctx, P(model), id, update, lock, flags...)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return model, nil
+}
+
+// Replace will replace the existing document with the provided model.
+func (m *TypedManager[T, P]) Replace(ctx context.Context, model *T, lock bool, flags ...Flags) (bool, error) {
+	return m.manager.Replace(ctx, P(model), lock, flags...)
+}
+
+// Delete will delete the document with the specified id and return the deleted
+// document. It will return nil if no document has been found.
+func (m *TypedManager[T, P]) Delete(ctx context.Context, id ID) (*T, error) {
+	// delete model
+	model := new(T)
+	found, err := m.manager.Delete(ctx, P(model), id)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return model, nil
+}
@@ -0,0 +1,109 @@
+package coal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTypedManager(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		m := TM[postModel](tester.Store)
+		assert.Equal(t, tester.Store.M(&postModel{}).C(), m.M().C())
+
+		post1 := &postModel{Base: B(), Title: "foo"}
+		err := m.Insert(nil, post1)
+		assert.NoError(t, err)
+
+		post2 := &postModel{Base: B(), Title: "bar"}
+		post3 := &postModel{Base: B(), Title: "baz"}
+		err = m.InsertAll(nil, []*postModel{post2, post3})
+		assert.NoError(t, err)
+
+		/* find */
+
+		post, err := m.Find(nil, post1.ID(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, post1, post)
+
+		post, err = m.Find(nil, New(), false)
+		assert.NoError(t, err)
+		assert.Nil(t, post)
+
+		post, err = m.Find(nil, post1.ID(), true)
+		assert.Error(t, err)
+		assert.True(t, ErrTransactionRequired.Is(err))
+		assert.Nil(t, post)
+
+		_ = tester.Store.T(nil, false, func(ctx context.Context) error {
+			post, err = m.Find(ctx, post1.ID(), true)
+			assert.NoError(t, err)
+			assert.Equal(t, post1.ID(), post.ID())
+			return nil
+		})
+
+		post, err = m.FindFirst(nil, bson.M{}, []string{"Title"}, 0, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Equal(t, post2, post)
+
+		/* find all */
+
+		posts, err := m.FindAll(nil, bson.M{}, []string{"Title"}, 1, 0, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Len(t, posts, 2)
+		assert.Equal(t, post3, posts[0])
+		assert.Equal(t, post1.ID(), posts[1].ID())
+
+		/* find each */
+
+		var titles []string
+		err = m.FindEach(nil, bson.M{}, []string{"-Title"}, 0, 0, false, func(post *postModel) error {
+			titles = append(titles, post.Title)
+			return nil
+		}, NoTransaction)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"foo", "baz", "bar"}, titles)
+
+		err = m.FindEach(nil, bson.M{}, nil, 0, 0, false, func(post *postModel) error {
+			return errors.New("stop")
+		}, NoTransaction)
+		assert.Error(t, err)
+		assert.Equal(t, "stop", err.Error())
+
+		count, err := m.Count(nil, bson.M{}, 0, 0, false, NoTransaction)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+
+		/* update */
+
+		post, err = m.Update(nil, post1.ID(), bson.M{
+			"$set": bson.M{"Title": "qux"},
+		}, false)
+		assert.NoError(t, err)
+		assert.Equal(t, "qux", post.Title)
+
+		post, err = m.Update(nil, New(), bson.M{
+			"$set": bson.M{"Title": "qux"},
+		}, false)
+		assert.NoError(t, err)
+		assert.Nil(t, post)
+
+		post2.Published = true
+		found, err := m.Replace(nil, post2, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+
+		/* delete */
+
+		post, err = m.Delete(nil, post2.ID())
+		assert.NoError(t, err)
+		assert.Equal(t, post2, post)
+
+		post, err = m.Delete(nil, post2.ID())
+		assert.NoError(t, err)
+		assert.Nil(t, post)
+	})
+}
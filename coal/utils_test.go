@@ -66,7 +66,7 @@ func init() {
 var mongoStore = MustConnect("mongodb://0.0.0.0/test-fire-coal", xo.Panic)
 var lungoStore = MustOpen(nil, "test-fire-coal", xo.Panic)
 
-var modelList = []Model{&postModel{}, &commentModel{}, &selectionModel{}, &noteModel{}, &fooModel{}}
+var modelList = []Model{&postModel{}, &commentModel{}, &selectionModel{}, &noteModel{}, &fooModel{}, &MigrationRecord{}}
 
 func withTester(t *testing.T, fn func(*testing.T, *Tester)) {
 	t.Run("Mongo", func(t *testing.T) {
@@ -0,0 +1,253 @@
+package coal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/256dpi/fire/stick"
+)
+
+// ValidationLevel defines how strictly the database applies a validator.
+type ValidationLevel string
+
+// The available validation levels.
+const (
+	// ValidationOff disables validation.
+	ValidationOff ValidationLevel = "off"
+
+	// ValidationModerate validates inserts and updates of valid documents.
+	ValidationModerate ValidationLevel = "moderate"
+
+	// ValidationStrict validates all inserts and updates.
+	ValidationStrict ValidationLevel = "strict"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+var bytesType = reflect.TypeOf([]byte{})
+var objectIDType = reflect.TypeOf(primitive.ObjectID{})
+var decimal128Type = reflect.TypeOf(primitive.Decimal128{})
+var marshalerType = reflect.TypeOf((*bson.Marshaler)(nil)).Elem()
+var valueMarshalerType = reflect.TypeOf((*bson.ValueMarshaler)(nil)).Elem()
+
+// Validator returns a MongoDB "$jsonSchema" validator that describes the
+// documents of the specified model. Fields that are not pointers or slices and
+// not omitted when empty are required. Fields with custom BSON encodings are
+// not constrained.
+func Validator(model Model) bson.M {
+	// get meta
+	meta := GetMeta(model)
+
+	// prepare properties
+	properties := bson.M{
+		"_id": bson.M{"bsonType": "string"},
+		"_lk": bson.M{"bsonType": "long"},
+		"_tk": bson.M{"bsonType": "string"},
+		"_sc": bson.M{"bsonType": "double"},
+		"_v":  bson.M{"bsonType": "long"},
+	}
+	required := bson.A{"_id"}
+
+	// add fields
+	for _, field := range meta.OrderedFields {
+		// skip virtual fields
+		if field.BSONKey == "" {
+			continue
+		}
+
+		// add property
+		properties[field.BSONKey] = schemaType(field.Type)
+
+		// add required
+		if isRequired(meta.Type.Field(field.Index)) {
+			required = append(required, field.BSONKey)
+		}
+	}
+
+	return bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType":   "object",
+			"required":   required,
+			"properties": properties,
+		},
+	}
+}
+
+// ApplyValidators will apply the validators of the specified models to their
+// collections using the provided validation level. Missing collections are
+// created. Invalid documents are rejected by the database.
+func ApplyValidators(store *Store, level ValidationLevel, models ...Model) error {
+	// check support
+	if store.Lungo() {
+		panic("coal: not supported by lungo")
+	}
+
+	// create context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// apply validators
+	for _, model := range models {
+		// get meta and validator
+		meta := GetMeta(model)
+		validator := Validator(model)
+
+		// modify collection
+		err := store.DB().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: meta.Collection},
+			{Key: "validator", Value: validator},
+			{Key: "validationLevel", Value: string(level)},
+			{Key: "validationAction", Value: "error"},
+		}).Err()
+		if isNamespaceNotFound(err) {
+			err = store.DB().CreateCollection(ctx, meta.Collection, options.CreateCollection().
+				SetValidator(validator).
+				SetValidationLevel(string(level)).
+				SetValidationAction("error"))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func schemaType(typ reflect.Type) bson.M {
+	// handle pointers
+	if typ.Kind() == reflect.Ptr {
+		schema := schemaType(typ.Elem())
+		return nullable(schema)
+	}
+
+	// handle special types
+	switch typ {
+	case timeType:
+		return bson.M{"bsonType": "date"}
+	case bytesType:
+		return nullable(bson.M{"bsonType": "binData"})
+	case objectIDType:
+		return bson.M{"bsonType": "objectId"}
+	case decimal128Type, decimalType:
+		return bson.M{"bsonType": "decimal"}
+	}
+
+	// handle custom encodings
+	if typ.Implements(valueMarshalerType) || reflect.PtrTo(typ).Implements(valueMarshalerType) {
+		return bson.M{}
+	} else if typ.Implements(marshalerType) || reflect.PtrTo(typ).Implements(marshalerType) {
+		return bson.M{"bsonType": "object"}
+	}
+
+	// handle kinds
+	switch typ.Kind() {
+	case reflect.String:
+		return bson.M{"bsonType": "string"}
+	case reflect.Bool:
+		return bson.M{"bsonType": "bool"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return bson.M{"bsonType": bson.A{"int", "long"}}
+	case reflect.Float32, reflect.Float64:
+		return bson.M{"bsonType": "double"}
+	case reflect.Slice:
+		return nullable(bson.M{
+			"bsonType": "array",
+			"items":    schemaType(typ.Elem()),
+		})
+	case reflect.Array:
+		return bson.M{
+			"bsonType": "array",
+			"items":    schemaType(typ.Elem()),
+		}
+	case reflect.Map:
+		return nullable(bson.M{"bsonType": "object"})
+	case reflect.Struct:
+		return structType(typ)
+	}
+
+	return bson.M{}
+}
+
+func structType(typ reflect.Type) bson.M {
+	// prepare properties
+	properties := bson.M{}
+	required := bson.A{}
+
+	// add fields
+	for i := 0; i < typ.NumField(); i++ {
+		// get field
+		field := typ.Field(i)
+
+		// skip unexported and ignored fields
+		key := stick.BSON.GetKey(field)
+		if field.PkgPath != "" || key == "" {
+			continue
+		}
+
+		// add property
+		properties[key] = schemaType(field.Type)
+
+		// add required
+		if isRequired(field) {
+			required = append(required, key)
+		}
+	}
+
+	// prepare schema
+	schema := bson.M{
+		"bsonType":   "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+func nullable(schema bson.M) bson.M {
+	// add null to the allowed types
+	switch typ := schema["bsonType"].(type) {
+	case string:
+		schema["bsonType"] = bson.A{typ, "null"}
+	case bson.A:
+		schema["bsonType"] = append(typ, "null")
+	}
+
+	return schema
+}
+
+func isRequired(field reflect.StructField) bool {
+	// check omit empty
+	for _, option := range strings.Split(field.Tag.Get("bson"), ",")[1:] {
+		if option == "omitempty" {
+			return false
+		}
+	}
+
+	// check kind
+	switch field.Type.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return false
+	}
+
+	return true
+}
+
+func isNamespaceNotFound(err error) bool {
+	// check error
+	var cmdErr mongo.CommandError
+	if err == nil || !errors.As(err, &cmdErr) {
+		return false
+	}
+
+	return cmdErr.Code == 26
+}
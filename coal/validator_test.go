@@ -0,0 +1,164 @@
+package coal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type validatorModel struct {
+	Base     `json:"-" bson:",inline" coal:"validators"`
+	Count    int             `json:"count"`
+	Rate     *float64        `json:"rate"`
+	Tags     []string        `json:"tags"`
+	Data     map[string]int  `json:"data"`
+	Decimal  Decimal         `json:"decimal"`
+	Nested   validatorItem   `json:"nested"`
+	Items    []validatorItem `json:"items"`
+	Optional string          `json:"optional" bson:",omitempty"`
+	Ignored  string          `json:"ignored" bson:"-"`
+}
+
+type validatorItem struct {
+	Name  string  `bson:"name"`
+	Value *string `bson:"value"`
+}
+
+func (m *validatorModel) Validate() error {
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	assert.Equal(t, bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": bson.A{"_id", "message", "post_id"},
+			"properties": bson.M{
+				"_id":     bson.M{"bsonType": "string"},
+				"_lk":     bson.M{"bsonType": "long"},
+				"_tk":     bson.M{"bsonType": "string"},
+				"_sc":     bson.M{"bsonType": "double"},
+				"_v":      bson.M{"bsonType": "long"},
+				"message": bson.M{"bsonType": "string"},
+				"post_id": bson.M{"bsonType": "string"},
+				"parent":  bson.M{"bsonType": bson.A{"string", "null"}},
+			},
+		},
+	}, Validator(&commentModel{}))
+
+	item := bson.M{
+		"bsonType": "object",
+		"required": bson.A{"name"},
+		"properties": bson.M{
+			"name":  bson.M{"bsonType": "string"},
+			"value": bson.M{"bsonType": bson.A{"string", "null"}},
+		},
+	}
+
+	assert.Equal(t, bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": bson.A{"_id", "count", "decimal", "nested"},
+			"properties": bson.M{
+				"_id":      bson.M{"bsonType": "string"},
+				"_lk":      bson.M{"bsonType": "long"},
+				"_tk":      bson.M{"bsonType": "string"},
+				"_sc":      bson.M{"bsonType": "double"},
+				"_v":       bson.M{"bsonType": "long"},
+				"count":    bson.M{"bsonType": bson.A{"int", "long"}},
+				"rate":     bson.M{"bsonType": bson.A{"double", "null"}},
+				"tags":     bson.M{"bsonType": bson.A{"array", "null"}, "items": bson.M{"bsonType": "string"}},
+				"data":     bson.M{"bsonType": bson.A{"object", "null"}},
+				"decimal":  bson.M{"bsonType": "decimal"},
+				"nested":   item,
+				"items":    bson.M{"bsonType": bson.A{"array", "null"}, "items": item},
+				"optional": bson.M{"bsonType": "string"},
+			},
+		},
+	}, Validator(&validatorModel{}))
+}
+
+func TestApplyValidators(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		if tester.Store.Lungo() {
+			assert.PanicsWithValue(t, "coal: not supported by lungo", func() {
+				_ = ApplyValidators(tester.Store, ValidationStrict, &validatorModel{})
+			})
+
+			return
+		}
+
+		err := tester.Store.C(&validatorModel{}).Native().Drop(nil)
+		assert.NoError(t, err)
+
+		err = ApplyValidators(tester.Store, ValidationStrict, &validatorModel{})
+		assert.NoError(t, err)
+
+		err = ApplyValidators(tester.Store, ValidationModerate, &validatorModel{})
+		assert.NoError(t, err)
+
+		_, err = tester.Store.C(&validatorModel{}).InsertOne(nil, bson.M{
+			"_id":   New(),
+			"count": "foo",
+		})
+		assert.Error(t, err)
+
+		tester.Insert(&validatorModel{})
+
+		err = tester.Store.C(&validatorModel{}).Native().Drop(nil)
+		assert.NoError(t, err)
+	})
+}
+
+func TestValidatorLock(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		err := tester.Store.C(&validatorModel{}).Native().Drop(nil)
+		assert.NoError(t, err)
+
+		if !tester.Store.Lungo() {
+			err = ApplyValidators(tester.Store, ValidationStrict, &validatorModel{})
+			assert.NoError(t, err)
+		}
+
+		m := tester.Store.M(&validatorModel{})
+
+		model := tester.Insert(&validatorModel{}).(*validatorModel)
+
+		err = tester.Store.T(nil, false, func(ctx context.Context) error {
+			var found validatorModel
+			ok, err := m.Find(ctx, &found, model.ID(), true)
+			assert.True(t, ok)
+			if err != nil {
+				return err
+			}
+
+			ok, err = m.Update(ctx, nil, model.ID(), bson.M{
+				"$set": bson.M{"Count": 7},
+			}, true)
+			assert.True(t, ok)
+			if err != nil {
+				return err
+			}
+
+			_, err = m.Upsert(ctx, nil, bson.M{"Count": 42}, bson.M{
+				"$set": bson.M{"Count": 42},
+			}, nil, true)
+			return err
+		})
+		assert.NoError(t, err)
+
+		var docs []bson.M
+		iter, err := tester.Store.C(&validatorModel{}).Find(nil, bson.M{})
+		assert.NoError(t, err)
+		assert.NoError(t, iter.All(&docs))
+		assert.Len(t, docs, 2)
+		for _, doc := range docs {
+			assert.IsType(t, int64(0), doc["_lk"])
+		}
+
+		err = tester.Store.C(&validatorModel{}).Native().Drop(nil)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,68 @@
+package coal
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func versionFilter(version int64) interface{} {
+	// documents without a version are stored without the field
+	if version == 0 {
+		return nil
+	}
+
+	return version
+}
+
+func (m *Manager) exists(ctx context.Context, filter interface{}) (bool, error) {
+	// count documents
+	n, err := m.coll.CountDocuments(ctx, filter, options.Count().SetLimit(1).SetCollation(m.collation(ctx)))
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (m *Manager) replace(ctx context.Context, filter bson.D, model Model, opts *options.ReplaceOptions) (bool, error) {
+	// replace document if not versioned
+	if !m.meta.Versioned {
+		res, err := m.coll.ReplaceOne(ctx, filter, model, opts)
+		if err != nil {
+			return false, err
+		}
+
+		return res.MatchedCount == 1, nil
+	}
+
+	// increment version
+	base := model.GetBase()
+	version := base.Version
+	base.Version++
+
+	// replace document if version matches
+	res, err := m.coll.ReplaceOne(ctx, append(filter[:len(filter):len(filter)], bson.E{
+		Key: "_v", Value: versionFilter(version),
+	}), model, opts)
+	if err == nil && res.MatchedCount == 1 {
+		return true, nil
+	}
+
+	// restore version
+	base.Version = version
+	if err != nil {
+		return false, err
+	}
+
+	// check document
+	ok, err := m.exists(ctx, filter)
+	if err != nil {
+		return false, err
+	} else if ok {
+		return false, ErrConflict.WrapF("version %d of %s is outdated", version, model.ID())
+	}
+
+	return false, nil
+}
@@ -0,0 +1,164 @@
+package coal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/stick"
+)
+
+type versionModel struct {
+	Base  `json:"-" bson:",inline" coal:"versions,versioned"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+	stick.NoValidation
+}
+
+func TestManagerVersion(t *testing.T) {
+	assert.True(t, GetMeta(&versionModel{}).Versioned)
+	assert.False(t, GetMeta(&postModel{}).Versioned)
+	assert.True(t, GetMeta(&versionModel{}).CollectionOptions.Empty())
+
+	withTester(t, func(t *testing.T, tester *Tester) {
+		m := tester.Store.M(&versionModel{})
+
+		/* insert */
+
+		model := &versionModel{Title: "foo"}
+		err := m.Insert(nil, model)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), model.Version)
+
+		/* replace */
+
+		stale := *model
+
+		model.Title = "bar"
+		found, err := m.Replace(nil, model, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, int64(2), model.Version)
+
+		stale.Title = "baz"
+		found, err = m.Replace(nil, &stale, false)
+		assert.Error(t, err)
+		assert.True(t, ErrConflict.Is(err))
+		assert.False(t, found)
+		assert.Equal(t, int64(1), stale.Version)
+
+		found, err = m.ReplaceFirst(nil, bson.M{"Title": "bar"}, &stale, false)
+		assert.Error(t, err)
+		assert.True(t, ErrConflict.Is(err))
+		assert.False(t, found)
+
+		found, err = m.ReplaceFirst(nil, bson.M{"Title": "bar"}, model, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, int64(3), model.Version)
+
+		found, err = m.Replace(nil, &versionModel{Base: B()}, false)
+		assert.NoError(t, err)
+		assert.False(t, found)
+
+		/* update */
+
+		found, err = m.Update(nil, &stale, model.ID(), bson.M{
+			"$set": bson.M{"Title": "baz"},
+		}, false)
+		assert.Error(t, err)
+		assert.True(t, ErrConflict.Is(err))
+		assert.False(t, found)
+
+		found, err = m.Update(nil, model, model.ID(), bson.M{
+			"$set": bson.M{"Title": "baz"},
+		}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, int64(4), model.Version)
+
+		found, err = m.Update(nil, nil, model.ID(), bson.M{
+			"$set": bson.M{"Title": "qux"},
+		}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+
+		n, err := m.UpdateAll(nil, bson.M{"_id": model.ID()}, bson.M{
+			"$set": bson.M{"Title": "quz"},
+		}, false)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		found, err = m.PushElements(nil, model, model.ID(), "Tags", []interface{}{"a"}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, int64(7), model.Version)
+
+		found, err = m.Find(nil, model, model.ID(), false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, int64(7), model.Version)
+		assert.Equal(t, "quz", model.Title)
+
+		/* unversioned */
+
+		legacy := B()
+		_, err = tester.Store.C(&versionModel{}).InsertOne(nil, bson.M{
+			"_id":   legacy.DocID,
+			"title": "foo",
+		})
+		assert.NoError(t, err)
+
+		found, err = m.Replace(nil, &versionModel{Base: legacy, Title: "bar"}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+
+		found, err = m.Find(nil, model, legacy.DocID, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, int64(1), model.Version)
+		assert.Equal(t, "bar", model.Title)
+
+		/* types */
+
+		legacy = B()
+		_, err = tester.Store.C(&versionModel{}).InsertOne(nil, bson.M{
+			"_id":   legacy.DocID,
+			"title": "foo",
+		})
+		assert.NoError(t, err)
+
+		found, err = m.Update(nil, nil, legacy.DocID, bson.M{
+			"$set": bson.M{"Title": "bar"},
+		}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+
+		legacy = B()
+		_, err = tester.Store.C(&versionModel{}).InsertOne(nil, bson.M{
+			"_id":   legacy.DocID,
+			"title": "foo",
+		})
+		assert.NoError(t, err)
+
+		found, err = m.PushElements(nil, nil, legacy.DocID, "Tags", []interface{}{"a"}, false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+
+		inserted, err := m.Upsert(nil, nil, bson.M{"Title": "new"}, bson.M{
+			"$set": bson.M{"Title": "new"},
+		}, nil, false)
+		assert.NoError(t, err)
+		assert.True(t, inserted)
+
+		var docs []bson.M
+		iter, err := tester.Store.C(&versionModel{}).Find(nil, bson.M{})
+		assert.NoError(t, err)
+		assert.NoError(t, iter.All(&docs))
+		assert.Len(t, docs, 5)
+		for _, doc := range docs {
+			assert.IsType(t, int64(0), doc["_v"])
+		}
+	})
+}
@@ -3,8 +3,12 @@ package coal
 import (
 	"bytes"
 	"fmt"
+	"html"
 	"io/ioutil"
+	"math"
 	"os/exec"
+	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -79,6 +83,323 @@ func VisualizeDOT(title string, models ...Model) string {
 	out.WriteString("  edge[headclip=true, tailclip=false];\n")
 	out.WriteString("  label=\"" + title + "\";\n")
 
+	// prepare graph
+	graph := newVisualGraph(models)
+
+	// add model nodes
+	for _, node := range graph.nodes {
+		// write begin of node
+		out.WriteString(fmt.Sprintf(`  "%s" [ style=filled, fillcolor=white, label=`, node.meta.Name))
+
+		// write head table
+		out.WriteString(fmt.Sprintf(`<<table border="0" align="center" cellspacing="0.5" cellpadding="0" width="134"><tr><td align="center" valign="bottom" width="130"><font face="Arial" point-size="11">%s</font></td></tr></table>|`, node.meta.Name))
+
+		// write begin of tail table
+		out.WriteString(`<table border="0" align="left" cellspacing="2" cellpadding="0" width="134">`)
+
+		// write attributes
+		for _, field := range node.meta.OrderedFields {
+			typ := dotEscape(visualType(field))
+			out.WriteString(fmt.Sprintf(`<tr><td align="left" width="130" port="%s">%s<font face="Arial" color="grey60"> %s %s</font></td></tr>`, field.Name, field.Name, typ, node.indexes[field.Name]))
+		}
+
+		// write end of tail table
+		out.WriteString(`</table>>`)
+
+		// write end of node
+		out.WriteString(`, shape=Mrecord, fontsize=10, fontname="Arial", margin="0.07,0.05", penwidth="1.0" ];` + "\n")
+	}
+
+	// add relationships
+	for _, r := range graph.relations {
+		// get style
+		style := "solid"
+		if !r.hasInverse {
+			style = "dotted"
+		}
+
+		// get color
+		color := "black"
+		if r.srcMany {
+			color = "black:white:black"
+		}
+
+		// write edge
+		out.WriteString(fmt.Sprintf(`  "%s"--"%s"[ fontname="Arial", fontsize=7, dir=both, arrowsize="0.9", penwidth="0.9", labelangle=32, labeldistance="1.8", style=%s, color="%s", arrowhead=%s, arrowtail=%s ];`, graph.lookup[r.from], graph.lookup[r.to], style, color, "normal", "none") + "\n")
+	}
+
+	// end graph
+	out.WriteString("}\n")
+
+	return out.String()
+}
+
+// VisualizeMermaid emits a string in Mermaid ER diagram format that visualizes
+// the models, their attributes, indexes and relationships. Relationships
+// without an inverse relationship are drawn as non-identifying relationships.
+func VisualizeMermaid(title string, models ...Model) string {
+	// prepare buffer
+	var out bytes.Buffer
+
+	// write title
+	if title != "" {
+		out.WriteString("---\n")
+		out.WriteString("title: " + title + "\n")
+		out.WriteString("---\n")
+	}
+
+	// start diagram
+	out.WriteString("erDiagram\n")
+
+	// prepare graph
+	graph := newVisualGraph(models)
+
+	// add entities
+	for _, node := range graph.nodes {
+		// write begin of entity
+		out.WriteString(fmt.Sprintf("  %s {\n", mermaidName(node.meta.Name)))
+
+		// write attributes
+		for _, field := range node.meta.OrderedFields {
+			// skip virtual fields
+			if field.BSONKey == "" {
+				continue
+			}
+
+			// prepare keys
+			var keys []string
+			if field.ToOne || field.ToMany {
+				keys = append(keys, "FK")
+			}
+			if node.unique[field.Name] {
+				keys = append(keys, "UK")
+			}
+
+			// write attribute
+			out.WriteString(fmt.Sprintf("    %s %s", mermaidType(field), field.Name))
+			if len(keys) > 0 {
+				out.WriteString(" " + strings.Join(keys, ","))
+			}
+			if comment := visualComment(node, field); comment != "" {
+				out.WriteString(fmt.Sprintf(" \"%s\"", comment))
+			}
+			out.WriteString("\n")
+		}
+
+		// write end of entity
+		out.WriteString("  }\n")
+	}
+
+	// add relationships
+	for _, r := range graph.relations {
+		// skip relationships to models that are not visualized
+		if graph.lookup[r.from] == "" || graph.lookup[r.to] == "" {
+			continue
+		}
+
+		// get source cardinality
+		src := "}o"
+		if r.hasInverse && !r.dstMany {
+			src = "|o"
+		}
+
+		// get destination cardinality
+		dst := "||"
+		if r.srcMany {
+			dst = "o{"
+		} else if r.optional {
+			dst = "o|"
+		}
+
+		// get line
+		line := "--"
+		if !r.hasInverse {
+			line = ".."
+		}
+
+		// write relationship
+		out.WriteString(fmt.Sprintf("  %s %s%s%s %s : %s\n", mermaidName(graph.lookup[r.from]), src, line, dst, mermaidName(graph.lookup[r.to]), r.name))
+	}
+
+	return out.String()
+}
+
+// VisualizeSVG returns a standalone SVG document that visualizes the models,
+// their attributes, indexes and relationships. The models are arranged in a
+// grid and relationships are labeled with their name and cardinalities.
+func VisualizeSVG(title string, models ...Model) string {
+	// prepare graph
+	graph := newVisualGraph(models)
+
+	// define sizes
+	const (
+		padding    = 20.0
+		gap        = 80.0
+		charWidth  = 6.5
+		headHeight = 24.0
+		rowHeight  = 16.0
+		titleSize  = 30.0
+	)
+
+	// prepare boxes
+	type box struct {
+		node          *visualNode
+		lines         []string
+		x, y, w, h    float64
+		column, layer int
+	}
+	boxes := map[string]*box{}
+	var list []*box
+	columns := int(math.Ceil(math.Sqrt(float64(len(graph.nodes)))))
+	for i, node := range graph.nodes {
+		// prepare lines
+		var lines []string
+		width := float64(len(node.meta.Name))
+		for _, field := range node.meta.OrderedFields {
+			line := strings.TrimSpace(fmt.Sprintf("%s %s %s", field.Name, visualType(field), node.indexes[field.Name]))
+			lines = append(lines, line)
+			width = math.Max(width, float64(len([]rune(line))))
+		}
+
+		// add box
+		b := &box{
+			node:   node,
+			lines:  lines,
+			w:      width*charWidth + 20,
+			h:      headHeight + float64(len(lines))*rowHeight + 8,
+			column: i % columns,
+			layer:  i / columns,
+		}
+		boxes[node.meta.PluralName] = b
+		list = append(list, b)
+	}
+
+	// compute column widths and row heights
+	widths := map[int]float64{}
+	heights := map[int]float64{}
+	for _, b := range list {
+		widths[b.column] = math.Max(widths[b.column], b.w)
+		heights[b.layer] = math.Max(heights[b.layer], b.h)
+	}
+
+	// position boxes
+	totalWidth, totalHeight := 0.0, 0.0
+	for _, b := range list {
+		b.x = padding
+		for i := 0; i < b.column; i++ {
+			b.x += widths[i] + gap
+		}
+		b.y = padding + titleSize
+		for i := 0; i < b.layer; i++ {
+			b.y += heights[i] + gap
+		}
+		totalWidth = math.Max(totalWidth, b.x+b.w+padding+gap/2)
+		totalHeight = math.Max(totalHeight, b.y+b.h+padding)
+	}
+	totalWidth = math.Max(totalWidth, float64(len(title))*charWidth*1.2+2*padding)
+
+	// prepare buffer
+	var out bytes.Buffer
+
+	// start document
+	out.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="Arial" font-size="11">`+"\n", totalWidth, totalHeight, totalWidth, totalHeight))
+	out.WriteString(`  <rect width="100%" height="100%" fill="white"/>` + "\n")
+	out.WriteString(fmt.Sprintf(`  <text x="%.0f" y="%.0f" font-size="13" text-anchor="middle">%s</text>`+"\n", totalWidth/2, padding+8, html.EscapeString(title)))
+
+	// add relationships first to draw them below the boxes
+	for _, r := range graph.relations {
+		// get boxes
+		from, to := boxes[r.from], boxes[r.to]
+		if from == nil || to == nil {
+			continue
+		}
+
+		// get style
+		style := ""
+		if !r.hasInverse {
+			style = ` stroke-dasharray="4,3"`
+		}
+		width := 1.0
+		if r.srcMany {
+			width = 2.0
+		}
+
+		// get cardinalities
+		dstLabel := "1"
+		if r.srcMany {
+			dstLabel = "0..*"
+		} else if r.optional {
+			dstLabel = "0..1"
+		}
+		srcLabel := "0..*"
+		if r.hasInverse && !r.dstMany {
+			srcLabel = "0..1"
+		}
+
+		// draw loop for self relationships
+		if from == to {
+			x1, y1 := from.x+from.w, from.y+headHeight/2
+			x2, y2 := from.x+from.w, from.y+headHeight/2+rowHeight*1.5
+			out.WriteString(fmt.Sprintf(`  <path d="M %.1f %.1f C %.1f %.1f %.1f %.1f %.1f %.1f" fill="none" stroke="black" stroke-width="%.0f"%s/>`+"\n", x1, y1, x1+gap/2, y1, x2+gap/2, y2, x2, y2, width, style))
+			out.WriteString(fmt.Sprintf(`  <text x="%.1f" y="%.1f" font-size="9" fill="grey">%s (%s : %s)</text>`+"\n", x1+gap/2, (y1+y2)/2+3, html.EscapeString(r.name), srcLabel, dstLabel))
+			continue
+		}
+
+		// get centers
+		x1, y1 := from.x+from.w/2, from.y+from.h/2
+		x2, y2 := to.x+to.w/2, to.y+to.h/2
+
+		// draw line
+		out.WriteString(fmt.Sprintf(`  <line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="black" stroke-width="%.0f"%s/>`+"\n", x1, y1, x2, y2, width, style))
+
+		// draw labels
+		sx, sy := svgClip(x1, y1, x2, y2, from.w/2, from.h/2, 12)
+		dx, dy := svgClip(x2, y2, x1, y1, to.w/2, to.h/2, 12)
+		out.WriteString(fmt.Sprintf(`  <text x="%.1f" y="%.1f" font-size="9" text-anchor="middle" fill="grey">%s</text>`+"\n", sx, sy, srcLabel))
+		out.WriteString(fmt.Sprintf(`  <text x="%.1f" y="%.1f" font-size="9" text-anchor="middle" fill="grey">%s</text>`+"\n", dx, dy, dstLabel))
+		out.WriteString(fmt.Sprintf(`  <text x="%.1f" y="%.1f" font-size="9" text-anchor="middle">%s</text>`+"\n", (sx+dx)/2, (sy+dy)/2-4, html.EscapeString(r.name)))
+	}
+
+	// add boxes
+	for _, b := range list {
+		out.WriteString(fmt.Sprintf(`  <g id="%s">`+"\n", html.EscapeString(b.node.meta.Name)))
+		out.WriteString(fmt.Sprintf(`    <rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="6" fill="white" stroke="black"/>`+"\n", b.x, b.y, b.w, b.h))
+		out.WriteString(fmt.Sprintf(`    <text x="%.1f" y="%.1f" text-anchor="middle" font-weight="bold">%s</text>`+"\n", b.x+b.w/2, b.y+16, html.EscapeString(b.node.meta.Name)))
+		out.WriteString(fmt.Sprintf(`    <line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="black"/>`+"\n", b.x, b.y+headHeight, b.x+b.w, b.y+headHeight))
+		for i, line := range b.lines {
+			out.WriteString(fmt.Sprintf(`    <text x="%.1f" y="%.1f">%s</text>`+"\n", b.x+10, b.y+headHeight+float64(i+1)*rowHeight, html.EscapeString(line)))
+		}
+		out.WriteString("  </g>\n")
+	}
+
+	// end document
+	out.WriteString("</svg>\n")
+
+	return out.String()
+}
+
+type visualNode struct {
+	meta    *Meta
+	indexes map[string]string
+	unique  map[string]bool
+}
+
+type visualRelation struct {
+	from, to   string
+	name       string
+	optional   bool
+	srcMany    bool
+	dstMany    bool
+	hasInverse bool
+}
+
+type visualGraph struct {
+	nodes     []*visualNode
+	relations []*visualRelation
+	lookup    map[string]string
+}
+
+func newVisualGraph(models []Model) *visualGraph {
 	// prepare catalog
 	catalog := make(map[string]Model)
 	for _, model := range models {
@@ -94,13 +415,15 @@ func VisualizeDOT(title string, models ...Model) string {
 	}
 	sort.Strings(names)
 
-	// add model nodes
-	for _, name := range names {
-		// get model
-		model := catalog[name]
+	// prepare graph
+	graph := &visualGraph{
+		lookup: lookup,
+	}
 
+	// add nodes
+	for _, name := range names {
 		// get meta
-		meta := GetMeta(model)
+		meta := GetMeta(catalog[name])
 
 		// prepare index info
 		indexedInfo := map[string]string{}
@@ -109,7 +432,11 @@ func VisualizeDOT(title string, models ...Model) string {
 		}
 
 		// analyse indexes
+		unique := map[string]bool{}
 		for _, index := range meta.Indexes {
+			if index.Unique && len(index.Fields) == 1 && index.Filter == nil {
+				unique[index.Fields[0]] = true
+			}
 			for i, field := range index.Fields {
 				if index.Filter != nil {
 					indexedInfo[field] += "◌"
@@ -123,53 +450,29 @@ func VisualizeDOT(title string, models ...Model) string {
 			}
 		}
 
-		// write begin of node
-		out.WriteString(fmt.Sprintf(`  "%s" [ style=filled, fillcolor=white, label=`, lookup[name]))
-
-		// write head table
-		out.WriteString(fmt.Sprintf(`<<table border="0" align="center" cellspacing="0.5" cellpadding="0" width="134"><tr><td align="center" valign="bottom" width="130"><font face="Arial" point-size="11">%s</font></td></tr></table>|`, lookup[name]))
-
-		// write begin of tail table
-		out.WriteString(`<table border="0" align="left" cellspacing="2" cellpadding="0" width="134">`)
-
-		// write attributes
-		for _, field := range GetMeta(model).OrderedFields {
-			typ := strings.ReplaceAll(field.Type.String(), "primitive.ObjectID", "coal.ID")
-			typ = dotEscape(typ)
-			out.WriteString(fmt.Sprintf(`<tr><td align="left" width="130" port="%s">%s<font face="Arial" color="grey60"> %s %s</font></td></tr>`, field.Name, field.Name, typ, indexedInfo[field.Name]))
-		}
-
-		// write end of tail table
-		out.WriteString(`</table>>`)
-
-		// write end of node
-		out.WriteString(`, shape=Mrecord, fontsize=10, fontname="Arial", margin="0.07,0.05", penwidth="1.0" ];` + "\n")
-	}
-
-	// define temporary struct
-	type rel struct {
-		from, to   string
-		srcMany    bool
-		dstMany    bool
-		hasInverse bool
+		// add node
+		graph.nodes = append(graph.nodes, &visualNode{
+			meta:    meta,
+			indexes: indexedInfo,
+			unique:  unique,
+		})
 	}
 
 	// prepare list
-	list := make(map[string]*rel)
+	list := make(map[string]*visualRelation)
 	var relNames []string
 
 	// prepare relationships
 	for _, name := range names {
-		// get model
-		model := catalog[name]
-
 		// add all direct relationships
-		for _, field := range GetMeta(model).OrderedFields {
+		for _, field := range GetMeta(catalog[name]).OrderedFields {
 			if field.RelName != "" && (field.ToOne || field.ToMany) {
-				list[name+"-"+field.RelName] = &rel{
-					from:    name,
-					to:      field.RelType,
-					srcMany: field.ToMany,
+				list[name+"-"+field.RelName] = &visualRelation{
+					from:     name,
+					to:       field.RelType,
+					name:     field.RelName,
+					optional: field.Optional,
+					srcMany:  field.ToMany,
 				}
 
 				relNames = append(relNames, name+"-"+field.RelName)
@@ -179,13 +482,13 @@ func VisualizeDOT(title string, models ...Model) string {
 
 	// update relationships
 	for _, name := range names {
-		// get model
-		model := catalog[name]
-
 		// add all indirect relationships
-		for _, field := range GetMeta(model).OrderedFields {
+		for _, field := range GetMeta(catalog[name]).OrderedFields {
 			if field.RelName != "" && (field.HasOne || field.HasMany) {
 				r := list[field.RelType+"-"+field.RelInverse]
+				if r == nil {
+					continue
+				}
 				r.dstMany = field.HasMany
 				r.hasInverse = true
 			}
@@ -197,29 +500,77 @@ func VisualizeDOT(title string, models ...Model) string {
 
 	// add relationships
 	for _, name := range relNames {
-		// get relationship
-		r := list[name]
+		graph.relations = append(graph.relations, list[name])
+	}
 
-		// get style
-		style := "solid"
-		if !r.hasInverse {
-			style = "dotted"
-		}
+	return graph
+}
 
-		// get color
-		color := "black"
-		if r.srcMany {
-			color = "black:white:black"
+func visualType(field *Field) string {
+	return strings.ReplaceAll(field.Type.String(), "primitive.ObjectID", "coal.ID")
+}
+
+func visualComment(node *visualNode, field *Field) string {
+	// collect notes
+	var notes []string
+	if field.Type.Kind() == reflect.Ptr {
+		notes = append(notes, "optional")
+	}
+	if info := node.indexes[field.Name]; info != "" {
+		notes = append(notes, "indexed "+info)
+	}
+
+	return strings.Join(notes, ", ")
+}
+
+var mermaidInvalidName = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+var mermaidInvalidType = regexp.MustCompile(`[^A-Za-z0-9_\-\[\]()]`)
+
+var mermaidListPrefix = regexp.MustCompile(`^\[\d*\]\*?`)
+
+func mermaidName(name string) string {
+	return mermaidInvalidName.ReplaceAllString(name, "_")
+}
+
+func mermaidType(field *Field) string {
+	// get type
+	typ := strings.TrimPrefix(visualType(field), "*")
+
+	// move list prefixes to the end as types must start with a letter
+	var suffix string
+	for {
+		prefix := mermaidListPrefix.FindString(typ)
+		if prefix == "" {
+			break
 		}
+		typ = typ[len(prefix):]
+		suffix += "[]"
+	}
 
-		// write edge
-		out.WriteString(fmt.Sprintf(`  "%s"--"%s"[ fontname="Arial", fontsize=7, dir=both, arrowsize="0.9", penwidth="0.9", labelangle=32, labeldistance="1.8", style=%s, color="%s", arrowhead=%s, arrowtail=%s ];`, lookup[r.from], lookup[r.to], style, color, "normal", "none") + "\n")
+	return mermaidInvalidType.ReplaceAllString(typ, "_") + suffix
+}
+
+func svgClip(x1, y1, x2, y2, hw, hh, offset float64) (float64, float64) {
+	// get direction
+	dx, dy := x2-x1, y2-y1
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return x1, y1
 	}
 
-	// end graph
-	out.WriteString("}\n")
+	// compute distance to border
+	t := math.Inf(1)
+	if dx != 0 {
+		t = math.Min(t, hw/math.Abs(dx))
+	}
+	if dy != 0 {
+		t = math.Min(t, hh/math.Abs(dy))
+	}
 
-	return out.String()
+	// move beyond border
+	t += offset / length
+
+	return x1 + dx*t, y1 + dy*t
 }
 
 func dotEscape(str string) string {
@@ -1,6 +1,9 @@
 package coal
 
 import (
+	"encoding/xml"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,3 +43,72 @@ func TestCatalogVisualizeDOT(t *testing.T) {
 }
 `, out)
 }
+
+func TestCatalogVisualizeMermaid(t *testing.T) {
+	out := VisualizeMermaid("Test", &postModel{}, &commentModel{}, &selectionModel{}, &noteModel{})
+	assert.Equal(t, `---
+title: Test
+---
+erDiagram
+  coal_commentModel {
+    string Message
+    string Post FK
+    string Parent "optional"
+  }
+  coal_noteModel {
+    string Title
+    time_Time CreatedAt
+    time_Time UpdatedAt
+    string Post FK
+  }
+  coal_postModel {
+    string Title "indexed ○"
+    bool Published "indexed ●"
+    string TextBody "indexed ◌"
+  }
+  coal_selectionModel {
+    string Name
+    string[] Posts FK
+  }
+  coal_commentModel }o--|| coal_postModel : post
+  coal_noteModel |o--|| coal_postModel : post
+  coal_selectionModel }o--o{ coal_postModel : posts
+`, out)
+}
+
+func TestCatalogVisualizeMermaidPartial(t *testing.T) {
+	out := VisualizeMermaid("Test", &commentModel{})
+	assert.Equal(t, `---
+title: Test
+---
+erDiagram
+  coal_commentModel {
+    string Message
+    string Post FK
+    string Parent "optional"
+  }
+`, out)
+}
+
+func TestCatalogVisualizeSVG(t *testing.T) {
+	out := VisualizeSVG("Test & Co", &postModel{}, &commentModel{}, &selectionModel{}, &noteModel{})
+	assert.True(t, strings.HasPrefix(out, `<svg xmlns="http://www.w3.org/2000/svg"`))
+	assert.Contains(t, out, `>Test &amp; Co</text>`)
+	assert.Contains(t, out, `<g id="coal.postModel">`)
+	assert.Contains(t, out, `>Published bool ●</text>`)
+	assert.Contains(t, out, `>Posts []string</text>`)
+	assert.Contains(t, out, `>posts</text>`)
+	assert.Contains(t, out, `>0..*</text>`)
+
+	decoder := xml.NewDecoder(strings.NewReader(out))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		if err != nil {
+			break
+		}
+	}
+}
@@ -3,6 +3,7 @@ package fire
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/bits"
@@ -17,6 +18,8 @@ import (
 	"github.com/256dpi/xo"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 
 	"github.com/256dpi/fire/coal"
 	"github.com/256dpi/fire/stick"
@@ -218,6 +221,22 @@ type Controller struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 
+	// ListReadPreference may be set to serve List operations according to the
+	// specified read preference, e.g. from secondaries for reporting models
+	// that are queried heavily. The operation is then run in a causally
+	// consistent session instead of a transaction. Reads that follow a write
+	// in the same request are still served by the primary. A primary read
+	// preference is treated as unset.
+	ListReadPreference *readpref.ReadPref
+
+	// Collation may be set to use the specified collation for the queries that
+	// load and list the models of the controller. Sorting and filtering of
+	// string fields will then be locale-aware and may use indexes with the same
+	// collation. Queries issued by callbacks are not affected. The
+	// coal.CaseInsensitive helper can be used to create a collation that
+	// compares strings case-insensitively.
+	Collation *options.Collation
+
 	// CollectionActions and ResourceActions are custom actions that are run
 	// on the collection (e.g. "posts/delete-cache") or resource (e.g.
 	// "users/1/recover-password"). The request context is forwarded to
@@ -230,6 +249,19 @@ type Controller struct {
 	// fields are changed during a Create or Update operation.
 	TolerateViolations []string
 
+	// VerifyReferences can be set to true to verify the references assigned
+	// using the SetRelationship and AppendToRelationship operations. The
+	// controller will check that the referenced resources exist and are
+	// readable by running a virtual list request against the related
+	// controller. Additionally, to-one references that are the inverse of a
+	// has-one relationship are checked to remain unique.
+	VerifyReferences bool
+
+	// ReferenceLimits may be set to limit the number of references in to-many
+	// relationships when they are changed using the SetRelationship and
+	// AppendToRelationship operations. The map is keyed by the field name.
+	ReferenceLimits map[string]int
+
 	// IdempotentCreate can be set to true to enable the idempotent create
 	// mechanism. When creating resources, clients have to generate and submit a
 	// unique "create token". The controller will then first check if a document
@@ -247,6 +279,11 @@ type Controller struct {
 	// will then check if the stored document still has the same token. The
 	// controller will determine the token field from the provided model using
 	// the "fire-consistent-update" flag.
+	//
+	// For versioned models the controller exposes the document version in the
+	// "version" resource meta field. Clients may send the version with an
+	// update to have it rejected with a conflict if the resource has been
+	// modified since it has been loaded, without enabling this mechanism.
 	ConsistentUpdate bool
 
 	// SoftDelete can be set to true to enable the soft delete mechanism. If
@@ -363,6 +400,13 @@ func (c *Controller) prepare() {
 		}
 	}
 
+	// check reference limits
+	for name := range c.ReferenceLimits {
+		if field := c.meta.Fields[name]; field == nil || !field.ToMany {
+			panic(fmt.Sprintf(`fire: reference limit for invalid to-many relationship "%s"`, name))
+		}
+	}
+
 	// lookup properties
 	c.properties = map[string]func(coal.Model) (interface{}, error){}
 	for name := range c.Properties {
@@ -379,9 +423,25 @@ func (c *Controller) handle(prefix string, ctx *Context, selector bson.M, write
 	parser := c.parser
 	parser.Prefix = prefix
 
+	// negotiate renderer
+	var renderer Renderer
+	if write && ctx.Group != nil {
+		renderer = ctx.Group.negotiate(ctx.HTTPRequest)
+	}
+
 	// parse incoming JSON-API request if not yet present
 	if ctx.JSONAPIRequest == nil {
-		req, err := parser.ParseRequest(ctx.HTTPRequest)
+		// get request
+		r := ctx.HTTPRequest
+
+		// accept JSON:API when a renderer has been negotiated
+		if renderer != nil {
+			r = r.Clone(r.Context())
+			r.Header.Set("Accept", jsonapi.MediaType)
+		}
+
+		// parse request
+		req, err := parser.ParseRequest(r)
 		xo.AbortIf(err)
 		ctx.JSONAPIRequest = req
 	}
@@ -448,8 +508,17 @@ func (c *Controller) handle(prefix string, ctx *Context, selector bson.M, write
 	ctx.ReadableProperties = c.initialProperties(ctx.JSONAPIRequest)
 	ctx.RelationshipFilters = map[string][]bson.M{}
 
-	// run operation with transaction if not an action
-	if !ctx.Operation.Action() {
+	// run operation with session and read preference if configured (primary
+	// reads are run with a transaction as usual)
+	if ctx.Operation == List && c.ListReadPreference != nil && c.ListReadPreference.Mode() != readpref.PrimaryMode {
+		xo.AbortIf(c.Store.S(ctx.Context, func(sc context.Context) error {
+			return ctx.With(coal.WithReadPreference(sc, c.ListReadPreference), func() error {
+				c.runOperation(ctx)
+				return nil
+			})
+		}))
+	} else if !ctx.Operation.Action() {
+		// run operation with transaction if not an action
 		xo.AbortIf(c.Store.T(ctx.Context, ctx.Operation.Read(), func(tc context.Context) error {
 			return ctx.With(tc, func() error {
 				c.runOperation(ctx)
@@ -462,10 +531,25 @@ func (c *Controller) handle(prefix string, ctx *Context, selector bson.M, write
 
 	// write response if available
 	if write && ctx.Response != nil {
+		// use negotiated renderer for list and find operations
+		if renderer != nil && (ctx.Operation == List || ctx.Operation == Find) {
+			xo.AbortIf(renderer.Render(ctx.ResponseWriter, ctx.ResponseCode, ctx.Response))
+			return
+		}
+
 		xo.AbortIf(jsonapi.WriteResponse(ctx.ResponseWriter, ctx.ResponseCode, ctx.Response))
 	}
 }
 
+func (c *Controller) collate(ctx context.Context) context.Context {
+	// check collation
+	if c.Collation == nil {
+		return ctx
+	}
+
+	return coal.WithCollation(ctx, c.Collation)
+}
+
 func (c *Controller) runOperation(ctx *Context) {
 	// call specific handlers
 	switch ctx.JSONAPIRequest.Intent {
@@ -715,6 +799,18 @@ func (c *Controller) updateResource(ctx *Context) {
 	// load model
 	c.loadModel(ctx)
 
+	// check version
+	if c.meta.Versioned && ctx.Request.Data.One.Meta["version"] != nil {
+		number, _ := ctx.Request.Data.One.Meta["version"].(json.Number)
+		version, err := number.Int64()
+		if err != nil {
+			xo.Abort(jsonapi.BadRequest("invalid version"))
+		}
+		if version != ctx.Model.GetBase().Version {
+			xo.Abort(jsonapi.ErrorFromStatus(http.StatusConflict, "existing document has a different version"))
+		}
+	}
+
 	// get stored idempotent create token
 	var storedIdempotentCreateToken string
 	if c.IdempotentCreate {
@@ -777,6 +873,8 @@ func (c *Controller) updateResource(ctx *Context) {
 		}, ctx.Model, false)
 		if coal.IsDuplicate(err) {
 			xo.Abort(jsonapi.BadRequest("document is not unique"))
+		} else if coal.ErrConflict.Is(err) {
+			xo.Abort(jsonapi.ErrorFromStatus(http.StatusConflict, "existing document has been modified concurrently"))
 		}
 		xo.AbortIf(err)
 
@@ -789,6 +887,8 @@ func (c *Controller) updateResource(ctx *Context) {
 		found, err := ctx.Store.M(c.Model).Replace(ctx, ctx.Model, false)
 		if coal.IsDuplicate(err) {
 			xo.Abort(jsonapi.BadRequest("document is not unique"))
+		} else if coal.ErrConflict.Is(err) {
+			xo.Abort(jsonapi.ErrorFromStatus(http.StatusConflict, "existing document has been modified concurrently"))
 		}
 		xo.AbortIf(err)
 
@@ -1127,6 +1227,22 @@ func (c *Controller) setRelationship(ctx *Context) {
 	// assign relationship
 	c.assignRelationship(ctx, ctx.Request, rel)
 
+	// collect references
+	var refs []coal.ID
+	switch value := stick.MustGet(ctx.Model, rel.Name).(type) {
+	case coal.ID:
+		refs = []coal.ID{value}
+	case *coal.ID:
+		if value != nil {
+			refs = []coal.ID{*value}
+		}
+	case []coal.ID:
+		refs = value
+	}
+
+	// verify references
+	c.verifyReferences(ctx, rel, refs)
+
 	// run modifiers
 	c.runCallbacks(ctx, Modifier, c.Modifiers, http.StatusBadRequest)
 
@@ -1201,6 +1317,9 @@ func (c *Controller) appendToRelationship(ctx *Context) {
 		xo.Abort(jsonapi.BadRequest("relationship is not writable"))
 	}
 
+	// prepare list
+	var refs []coal.ID
+
 	// process all references
 	for _, ref := range ctx.Request.Data.Many {
 		// check type
@@ -1225,8 +1344,12 @@ func (c *Controller) appendToRelationship(ctx *Context) {
 		// add id
 		ids = append(ids, refID)
 		stick.MustSet(ctx.Model, rel.Name, ids)
+		refs = append(refs, refID)
 	}
 
+	// verify references
+	c.verifyReferences(ctx, rel, refs)
+
 	// run modifiers
 	c.runCallbacks(ctx, Modifier, c.Modifiers, http.StatusBadRequest)
 
@@ -1377,6 +1500,117 @@ func (c *Controller) removeFromRelationship(ctx *Context) {
 	c.runCallbacks(ctx, Notifier, c.Notifiers, http.StatusInternalServerError)
 }
 
+func (c *Controller) verifyReferences(ctx *Context, rel *coal.Field, refs []coal.ID) {
+	// trace
+	ctx.Tracer.Push("fire/Controller.verifyReferences")
+	defer ctx.Tracer.Pop()
+
+	// check limit
+	if limit, ok := c.ReferenceLimits[rel.Name]; ok {
+		if len(stick.MustGet(ctx.Model, rel.Name).([]coal.ID)) > limit {
+			xo.Abort(jsonapi.BadRequest(fmt.Sprintf("too many references for relationship %s", rel.RelName)))
+		}
+	}
+
+	// check flag and references
+	if !c.VerifyReferences || len(refs) == 0 {
+		return
+	}
+
+	// check group
+	if ctx.Group == nil {
+		xo.Abort(xo.F("missing group to verify references for %s", rel.RelType))
+	}
+
+	// get related controller
+	rc := ctx.Group.controllers[rel.RelType]
+	if rc == nil {
+		xo.Abort(xo.F("missing related controller for %s", rel.RelType))
+	}
+
+	// get unique references
+	refs = stick.Unique(refs)
+
+	// determine batch size to fit all references of a batch on a single page
+	batch := len(refs)
+	if rc.ListLimit > 0 && int(rc.ListLimit) < batch {
+		batch = int(rc.ListLimit)
+	}
+
+	// verify references in batches
+	for start := 0; start < len(refs); start += batch {
+		// get batch
+		end := start + batch
+		if end > len(refs) {
+			end = len(refs)
+		}
+
+		// prepare sub context
+		subCtx := &Context{
+			Context:        ctx,
+			Data:           stick.Map{},
+			Parent:         ctx.Model,
+			HTTPRequest:    ctx.HTTPRequest,
+			ResponseWriter: nil,
+			Controller:     rc,
+			Group:          ctx.Group,
+			Tracer:         ctx.Tracer,
+			JSONAPIRequest: &jsonapi.Request{
+				Intent:       jsonapi.ListResources,
+				ResourceType: rel.RelType,
+			},
+		}
+
+		// prepare selector
+		selector := bson.M{
+			"_id": bson.M{
+				"$in": refs[start:end],
+			},
+		}
+
+		// handle virtual request
+		rc.handle("", subCtx, selector, false)
+
+		// check that all referenced resources have been returned
+		if len(subCtx.Response.Data.Many) != end-start {
+			xo.Abort(jsonapi.BadRequest(fmt.Sprintf("missing references for relationship %s", rel.RelName)))
+		}
+	}
+
+	// check has-one uniqueness of to-one references
+	if !rel.ToOne {
+		return
+	}
+	for _, field := range rc.meta.Relationships {
+		if !field.HasOne || field.RelType != c.meta.PluralName || field.RelInverse != rel.RelName {
+			continue
+		}
+
+		// prepare query
+		query := bson.M{
+			"_id": bson.M{
+				"$ne": ctx.Model.ID(),
+			},
+			rel.Name: refs[0],
+		}
+
+		// exclude soft deleted documents if enabled
+		if c.SoftDelete {
+			softDeleteField := coal.L(c.Model, "fire-soft-delete", true)
+			query[softDeleteField] = nil
+		}
+
+		// count other documents with the same reference
+		count, err := ctx.Store.M(c.Model).Count(ctx, query, 0, 1, false)
+		xo.AbortIf(err)
+
+		// check count
+		if count > 0 {
+			xo.Abort(jsonapi.BadRequest(fmt.Sprintf("reference for relationship %s is not unique", rel.RelName)))
+		}
+	}
+}
+
 func (c *Controller) handleCollectionAction(ctx *Context) {
 	// trace
 	ctx.Tracer.Push("fire/Controller.handleCollectionAction")
@@ -1539,7 +1773,7 @@ func (c *Controller) loadModel(ctx *Context) {
 
 	// find model
 	model := c.meta.Make()
-	found, err := ctx.Store.M(c.Model).FindFirst(ctx, model, ctx.Query(), nil, 0, lock)
+	found, err := ctx.Store.M(c.Model).FindFirst(c.collate(ctx), model, ctx.Query(), nil, 0, lock)
 	xo.AbortIf(err)
 
 	// check if missing
@@ -1883,7 +2117,7 @@ func (c *Controller) loadModels(ctx *Context) {
 
 	// load documents
 	models := c.meta.MakeSlice()
-	xo.AbortIf(ctx.Store.M(c.Model).FindAll(ctx, models, query, sorting, skip, limit, false, flags))
+	xo.AbortIf(ctx.Store.M(c.Model).FindAll(c.collate(ctx), models, query, sorting, skip, limit, false, flags))
 
 	// set models
 	ctx.Models = coal.Slice(models)
@@ -2447,11 +2681,19 @@ func (c *Controller) constructResource(ctx *Context, model coal.Model, relations
 		resource.Attributes[key] = value
 	}
 
+	// add version meta
+	if c.meta.Versioned {
+		resource.Meta = jsonapi.Map{
+			"version": model.GetBase().Version,
+		}
+	}
+
 	// add score meta on search
 	if ctx.Operation == List && ctx.JSONAPIRequest.Search != "" {
-		resource.Meta = jsonapi.Map{
-			"score": model.GetBase().Score,
+		if resource.Meta == nil {
+			resource.Meta = jsonapi.Map{}
 		}
+		resource.Meta["score"] = model.GetBase().Score
 	}
 
 	return resource
@@ -2470,7 +2712,7 @@ func (c *Controller) listLinks(ctx *Context) *jsonapi.DocumentLinks {
 	// add offset pagination links
 	if !c.CursorPagination && ctx.JSONAPIRequest.PageSize > 0 {
 		// count resources
-		count, err := ctx.Store.M(c.Model).Count(ctx, ctx.Query(), 0, 0, false)
+		count, err := ctx.Store.M(c.Model).Count(c.collate(ctx), ctx.Query(), 0, 0, false)
 		xo.AbortIf(err)
 
 		// calculate last page
@@ -2608,7 +2850,9 @@ func (c *Controller) runCallbackList(ctx *Context, stage Stage, list []*Callback
 		ctx.Stage = stage
 
 		// call callback
+		start := time.Now()
 		err := xo.W(cb.Handler(ctx))
+		ctx.Group.recordCallback(ctx, cb, time.Since(start))
 		if xo.IsSafe(err) {
 			xo.Abort(jsonapi.ErrorFromStatus(errorStatus, err.Error()))
 		} else if err != nil {
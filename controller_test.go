@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/256dpi/jsonapi/v2"
@@ -16,6 +17,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 
 	"github.com/256dpi/fire/coal"
 	"github.com/256dpi/fire/stick"
@@ -7304,3 +7306,459 @@ func TestTransactions(t *testing.T) {
 		assert.Equal(t, []string{"foo", "foo"}, errs)
 	})
 }
+
+func TestVerifyReferences(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+			Authorizers: L{
+				C("TestVerifyReferences", Authorizer, All(), func(ctx *Context) error {
+					ctx.Filters = append(ctx.Filters, bson.M{
+						"Title": bson.M{"$ne": "Hidden"},
+					})
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model:            &selectionModel{},
+			VerifyReferences: true,
+			ReferenceLimits: map[string]int{
+				"Posts": 2,
+			},
+		}, &Controller{
+			Model:            &noteModel{},
+			VerifyReferences: true,
+		})
+
+		post1 := tester.Insert(&postModel{Title: "Post 1"}).ID()
+		post2 := tester.Insert(&postModel{Title: "Post 2"}).ID()
+		post3 := tester.Insert(&postModel{Title: "Post 3"}).ID()
+		hidden := tester.Insert(&postModel{Title: "Hidden"}).ID()
+
+		selection := tester.Insert(&selectionModel{Name: "Selection"}).ID()
+
+		// set missing reference
+		tester.Request("PATCH", "selections/"+selection+"/relationships/posts", `{
+			"data": [
+				{ "type": "posts", "id": "`+post1+`" },
+				{ "type": "posts", "id": "`+coal.New()+`" }
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "missing references for relationship posts"
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// set unreadable reference
+		tester.Request("PATCH", "selections/"+selection+"/relationships/posts", `{
+			"data": [
+				{ "type": "posts", "id": "`+hidden+`" }
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "missing references for relationship posts"
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// set valid references
+		tester.Request("PATCH", "selections/"+selection+"/relationships/posts", `{
+			"data": [
+				{ "type": "posts", "id": "`+post1+`" },
+				{ "type": "posts", "id": "`+post2+`" }
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// append too many references
+		tester.Request("POST", "selections/"+selection+"/relationships/posts", `{
+			"data": [
+				{ "type": "posts", "id": "`+post3+`" }
+			]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "too many references for relationship posts"
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		assert.Equal(t, []coal.ID{post1, post2}, tester.Fetch(&selectionModel{}, selection).(*selectionModel).Posts)
+
+		tester.Insert(&noteModel{Title: "Note 1", Post: post1})
+		note2 := tester.Insert(&noteModel{Title: "Note 2", Post: post2}).ID()
+
+		// set duplicate has-one reference
+		tester.Request("PATCH", "notes/"+note2+"/relationships/post", `{
+			"data": { "type": "posts", "id": "`+post1+`" }
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "reference for relationship post is not unique"
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// set unique has-one reference
+		tester.Request("PATCH", "notes/"+note2+"/relationships/post", `{
+			"data": { "type": "posts", "id": "`+post3+`" }
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+	})
+}
+
+func TestVerifyReferencesListLimit(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model:     &postModel{},
+			ListLimit: 2,
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model:            &selectionModel{},
+			VerifyReferences: true,
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		var refs []string
+		for i := 0; i < 5; i++ {
+			post := tester.Insert(&postModel{Title: fmt.Sprintf("Post %d", i)}).ID()
+			refs = append(refs, `{ "type": "posts", "id": "`+post+`" }`)
+		}
+
+		selection := tester.Insert(&selectionModel{Name: "Selection"}).ID()
+
+		// set more references than the list limit
+		tester.Request("PATCH", "selections/"+selection+"/relationships/posts", `{
+			"data": [`+strings.Join(refs, ",")+`]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		assert.Len(t, tester.Fetch(&selectionModel{}, selection).(*selectionModel).Posts, 5)
+
+		// set missing reference in last batch
+		tester.Request("PATCH", "selections/"+selection+"/relationships/posts", `{
+			"data": [`+strings.Join(refs[:4], ",")+`, { "type": "posts", "id": "`+coal.New()+`" }]
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [{
+					"status": "400",
+					"title": "bad request",
+					"detail": "missing references for relationship posts"
+				}]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+	})
+}
+
+func TestVerifyReferencesMissingGroup(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		controller := &Controller{
+			Model:            &selectionModel{},
+			VerifyReferences: true,
+		}
+
+		field := coal.GetMeta(&selectionModel{}).Fields["Posts"]
+
+		var err error
+		_ = tester.WithContext(&Context{Model: &selectionModel{}}, func(ctx *Context) error {
+			defer xo.Resume(func(e error) {
+				err = e
+			})
+			controller.verifyReferences(ctx, field, []coal.ID{coal.New()})
+			return nil
+		})
+		assert.Error(t, err)
+		assert.Equal(t, "missing group to verify references for posts", err.Error())
+	})
+}
+
+func TestListReadPreference(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+			Authorizers: L{
+				C("TestReadPreference", Authorizer, All(), func(ctx *Context) error {
+					if ctx.Operation == List {
+						assert.False(t, coal.HasTransaction(ctx))
+						assert.True(t, coal.HasSession(ctx))
+						assert.Equal(t, readpref.SecondaryPreferred(), coal.GetReadPreference(ctx))
+					} else {
+						assert.True(t, coal.HasTransaction(ctx))
+						assert.Nil(t, coal.GetReadPreference(ctx))
+					}
+					return nil
+				}),
+			},
+			ListReadPreference: readpref.SecondaryPreferred(),
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		post := tester.Insert(&postModel{
+			Title: "Post 1",
+		})
+		tester.Insert(&postModel{
+			Title: "Post 2",
+		})
+
+		// list posts
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(2), gjson.Get(r.Body.String(), "data.#").Int(), tester.DebugRequest(rq, r))
+		})
+
+		// find post
+		tester.Request("GET", "posts/"+post.ID(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, post.ID(), gjson.Get(r.Body.String(), "data.id").String(), tester.DebugRequest(rq, r))
+		})
+	})
+}
+
+func TestListReadPreferencePrimary(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+			Authorizers: L{
+				C("TestListReadPreferencePrimary", Authorizer, All(), func(ctx *Context) error {
+					assert.True(t, coal.HasTransaction(ctx))
+					assert.Nil(t, coal.GetReadPreference(ctx))
+					return nil
+				}),
+			},
+			ListReadPreference: readpref.Primary(),
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		tester.Insert(&postModel{
+			Title: "Post 1",
+		})
+
+		// list posts
+		tester.Request("GET", "posts", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(1), gjson.Get(r.Body.String(), "data.#").Int(), tester.DebugRequest(rq, r))
+		})
+	})
+}
+
+func TestCollation(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+			Authorizers: L{
+				C("TestCollation", Authorizer, All(), func(ctx *Context) error {
+					assert.Nil(t, coal.GetCollation(ctx))
+					assert.Equal(t, coal.CaseInsensitive("en"), coal.GetCollation(ctx.Controller.collate(ctx)))
+					return nil
+				}),
+			},
+			Sorters:   []string{"Title"},
+			Collation: coal.CaseInsensitive("en"),
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+		})
+
+		tester.Insert(&postModel{
+			Title: "b",
+		})
+		tester.Insert(&postModel{
+			Title: "a",
+		})
+
+		// list posts
+		tester.Request("GET", "posts?sort=title", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "a", gjson.Get(r.Body.String(), "data.0.attributes.title").String(), tester.DebugRequest(rq, r))
+		})
+	})
+}
+
+type versionedModel struct {
+	coal.Base `json:"-" bson:",inline" coal:"versioned-posts,versioned"`
+	Title     string `json:"title"`
+	stick.NoValidation
+}
+
+func TestVersionConflict(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		var modify bool
+		tester.Assign("", &Controller{
+			Model: &versionedModel{},
+			Validators: L{
+				C("TestVersionConflict", Validator, All(), func(ctx *Context) error {
+					if modify {
+						_, err := ctx.Store.M(ctx.Model).Update(ctx, nil, ctx.Model.ID(), bson.M{
+							"$set": bson.M{"Title": "Concurrent"},
+						}, false)
+						return err
+					}
+					return nil
+				}),
+			},
+		})
+
+		post := &versionedModel{
+			Title: "Hello",
+		}
+		err := tester.Store.M(post).Insert(nil, post)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), post.Version)
+
+		// update post
+		tester.Request("PATCH", "versioned-posts/"+post.ID(), `{
+			"data": {
+				"type": "versioned-posts",
+				"id": "`+post.ID()+`",
+				"attributes": {
+					"title": "World"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		found, err := tester.Store.M(post).Find(nil, post, post.ID(), false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "World", post.Title)
+		assert.Equal(t, int64(2), post.Version)
+
+		// update post concurrently
+		modify = true
+		tester.Request("PATCH", "versioned-posts/"+post.ID(), `{
+			"data": {
+				"type": "versioned-posts",
+				"id": "`+post.ID()+`",
+				"attributes": {
+					"title": "Foo"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusConflict, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [
+					{
+						"status": "409",
+						"title": "conflict",
+						"detail": "existing document has been modified concurrently"
+					}
+				]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// concurrent update has been rolled back with the transaction
+		found, err = tester.Store.M(post).Find(nil, post, post.ID(), false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "World", post.Title)
+		assert.Equal(t, int64(2), post.Version)
+
+		// get post
+		modify = false
+		tester.Request("GET", "versioned-posts/"+post.ID(), "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(2), gjson.Get(r.Body.String(), "data.meta.version").Int(), tester.DebugRequest(rq, r))
+		})
+
+		// update post with stale version
+		tester.Request("PATCH", "versioned-posts/"+post.ID(), `{
+			"data": {
+				"type": "versioned-posts",
+				"id": "`+post.ID()+`",
+				"attributes": {
+					"title": "Bar"
+				},
+				"meta": {
+					"version": 1
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusConflict, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"errors": [
+					{
+						"status": "409",
+						"title": "conflict",
+						"detail": "existing document has a different version"
+					}
+				]
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// update post with invalid version
+		tester.Request("PATCH", "versioned-posts/"+post.ID(), `{
+			"data": {
+				"type": "versioned-posts",
+				"id": "`+post.ID()+`",
+				"attributes": {
+					"title": "Bar"
+				},
+				"meta": {
+					"version": "2"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusBadRequest, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+
+		// update post with current version
+		tester.Request("PATCH", "versioned-posts/"+post.ID(), `{
+			"data": {
+				"type": "versioned-posts",
+				"id": "`+post.ID()+`",
+				"attributes": {
+					"title": "Bar"
+				},
+				"meta": {
+					"version": 2
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, int64(3), gjson.Get(r.Body.String(), "data.meta.version").Int(), tester.DebugRequest(rq, r))
+		})
+
+		found, err = tester.Store.M(post).Find(nil, post, post.ID(), false)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "Bar", post.Title)
+		assert.Equal(t, int64(3), post.Version)
+	})
+}
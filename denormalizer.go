@@ -0,0 +1,267 @@
+package fire
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/256dpi/xo"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+// Denormalization declares that a field of a model mirrors a field of a source
+// model that is referenced through a to-one relationship.
+//
+//	&fire.Denormalization{
+//		Model:        &Post{},
+//		Field:        "AuthorName",
+//		Relationship: "Author",
+//		Source:       &User{},
+//		SourceField:  "Name",
+//	}
+type Denormalization struct {
+	// The dependent model and the field that holds the mirrored value.
+	Model coal.Model
+	Field string
+
+	// The to-one relationship of the dependent model that references the
+	// source model.
+	Relationship string
+
+	// The source model and the field that is mirrored.
+	Source      coal.Model
+	SourceField string
+}
+
+// Denormalizer keeps denormalized fields in sync with their sources.
+type Denormalizer struct {
+	list []*Denormalization
+}
+
+// NewDenormalizer creates and returns a new denormalizer.
+//
+// Note: This method panics if a denormalization is invalid.
+func NewDenormalizer(denormalizations ...*Denormalization) *Denormalizer {
+	// check denormalizations
+	for _, d := range denormalizations {
+		// get metas
+		meta := coal.GetMeta(d.Model)
+		sourceMeta := coal.GetMeta(d.Source)
+
+		// check relationship
+		rel := meta.Fields[d.Relationship]
+		if rel == nil || !rel.ToOne || rel.RelType != sourceMeta.PluralName {
+			panic(fmt.Sprintf(`fire: invalid denormalization relationship "%s" for model "%s"`, d.Relationship, meta.Name))
+		}
+
+		// check fields
+		field := meta.Fields[d.Field]
+		sourceField := sourceMeta.Fields[d.SourceField]
+		if field == nil || sourceField == nil || field.Type != sourceField.Type {
+			panic(fmt.Sprintf(`fire: invalid denormalization field "%s" for model "%s"`, d.Field, meta.Name))
+		}
+	}
+
+	return &Denormalizer{
+		list: denormalizations,
+	}
+}
+
+// Apply will set all denormalized fields of the provided model using the
+// currently referenced source documents.
+func (d *Denormalizer) Apply(ctx context.Context, store *coal.Store, model coal.Model) error {
+	// trace
+	ctx, span := xo.Trace(ctx, "fire/Denormalizer.Apply")
+	defer span.End()
+
+	// get meta
+	meta := coal.GetMeta(model)
+
+	// apply denormalizations
+	for _, dn := range d.list {
+		if coal.GetMeta(dn.Model) == meta {
+			err := d.apply(ctx, store, dn, model)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Propagate will update the denormalized fields of all documents that
+// reference the provided source model. It will return the number of matched
+// documents.
+func (d *Denormalizer) Propagate(ctx context.Context, store *coal.Store, source coal.Model) (int64, error) {
+	// trace
+	ctx, span := xo.Trace(ctx, "fire/Denormalizer.Propagate")
+	defer span.End()
+
+	// get meta
+	meta := coal.GetMeta(source)
+
+	// propagate denormalizations
+	var total int64
+	for _, dn := range d.list {
+		if coal.GetMeta(dn.Source) == meta {
+			n, err := d.propagate(ctx, store, dn, source)
+			if err != nil {
+				return 0, err
+			}
+			total += n
+		}
+	}
+
+	return total, nil
+}
+
+// PropagateID will load the source document with the specified resource type
+// and id and propagate its fields. Missing documents are ignored.
+func (d *Denormalizer) PropagateID(ctx context.Context, store *coal.Store, typ string, id coal.ID) (int64, error) {
+	// find source model
+	var source coal.Model
+	for _, dn := range d.list {
+		if coal.GetMeta(dn.Source).PluralName == typ {
+			source = coal.GetMeta(dn.Source).Make()
+			break
+		}
+	}
+	if source == nil {
+		return 0, xo.F("unknown denormalization source %s", typ)
+	}
+
+	// load source
+	found, err := store.M(source).Find(ctx, source, id, false)
+	if err != nil {
+		return 0, err
+	} else if !found {
+		return 0, nil
+	}
+
+	return d.Propagate(ctx, store, source)
+}
+
+// Modifier returns a callback that applies the denormalized fields of created
+// models and updated models with a changed relationship.
+func (d *Denormalizer) Modifier() *Callback {
+	return C("fire/Denormalizer.Modifier", Modifier, Only(Create|Update), func(ctx *Context) error {
+		// get meta
+		meta := coal.GetMeta(ctx.Model)
+
+		// apply denormalizations
+		for _, dn := range d.list {
+			if coal.GetMeta(dn.Model) != meta {
+				continue
+			}
+
+			// check relationship
+			if ctx.Operation == Update && !ctx.Modified(dn.Relationship) {
+				continue
+			}
+
+			// apply denormalization
+			err := d.apply(ctx, ctx.Store, dn, ctx.Model)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Propagator returns a callback that propagates changed source fields to all
+// dependent documents. If async is missing, the documents are updated as part
+// of the request transaction. Otherwise, the async handler is called to
+// propagate the changes later e.g. using a job.
+func (d *Denormalizer) Propagator(async Handler) *Callback {
+	return C("fire/Denormalizer.Propagator", Notifier, Only(Update), func(ctx *Context) error {
+		// get meta
+		meta := coal.GetMeta(ctx.Model)
+
+		// check if a source field has been modified
+		var modified bool
+		for _, dn := range d.list {
+			if coal.GetMeta(dn.Source) == meta && ctx.Modified(dn.SourceField) {
+				modified = true
+				break
+			}
+		}
+		if !modified {
+			return nil
+		}
+
+		// propagate asynchronously if available
+		if async != nil {
+			return async(ctx)
+		}
+
+		// propagate changes
+		_, err := d.Propagate(ctx, ctx.Store, ctx.Model)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d *Denormalizer) apply(ctx context.Context, store *coal.Store, dn *Denormalization, model coal.Model) error {
+	// get referenced id
+	var id coal.ID
+	switch ref := stick.MustGet(model, dn.Relationship).(type) {
+	case coal.ID:
+		id = ref
+	case *coal.ID:
+		if ref != nil {
+			id = *ref
+		}
+	}
+
+	// unset field if no source is referenced
+	if id == "" {
+		field := coal.GetMeta(model).Fields[dn.Field]
+		stick.MustSet(model, dn.Field, reflect.Zero(field.Type).Interface())
+		return nil
+	}
+
+	// load source
+	source := coal.GetMeta(dn.Source).Make()
+	found, err := store.M(source).Find(ctx, source, id, false)
+	if err != nil {
+		return err
+	} else if !found {
+		return xo.SF("missing source for field " + dn.Field)
+	}
+
+	// set field
+	stick.MustSet(model, dn.Field, stick.MustGet(source, dn.SourceField))
+
+	return nil
+}
+
+func (d *Denormalizer) propagate(ctx context.Context, store *coal.Store, dn *Denormalization, source coal.Model) (int64, error) {
+	// get value
+	value := stick.MustGet(source, dn.SourceField)
+
+	// update dependent documents
+	n, err := store.M(dn.Model).UpdateAll(ctx, bson.M{
+		dn.Relationship: source.ID(),
+		dn.Field: bson.M{
+			"$ne": value,
+		},
+	}, bson.M{
+		"$set": bson.M{
+			dn.Field: value,
+		},
+	}, false)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,129 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDenormalizer(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		denormalizer := NewDenormalizer(&Denormalization{
+			Model:        &noteModel{},
+			Field:        "Title",
+			Relationship: "Post",
+			Source:       &postModel{},
+			SourceField:  "Title",
+		})
+
+		post := tester.Insert(&postModel{Title: "Hello"}).(*postModel)
+
+		note := &noteModel{Post: post.ID()}
+		err := denormalizer.Apply(nil, tester.Store, note)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello", note.Title)
+		tester.Insert(note)
+
+		post.Title = "World"
+		n, err := denormalizer.Propagate(nil, tester.Store, post)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		assert.Equal(t, "World", tester.Fetch(&noteModel{}, note.ID()).(*noteModel).Title)
+
+		tester.Update(post, bson.M{"$set": bson.M{"Title": "Hey"}})
+		n, err = denormalizer.PropagateID(nil, tester.Store, "posts", post.ID())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		assert.Equal(t, "Hey", tester.Fetch(&noteModel{}, note.ID()).(*noteModel).Title)
+
+		assert.PanicsWithValue(t, `fire: invalid denormalization relationship "Title" for model "fire.noteModel"`, func() {
+			NewDenormalizer(&Denormalization{
+				Model:        &noteModel{},
+				Field:        "Title",
+				Relationship: "Title",
+				Source:       &postModel{},
+				SourceField:  "Title",
+			})
+		})
+
+		assert.PanicsWithValue(t, `fire: invalid denormalization field "Title" for model "fire.noteModel"`, func() {
+			NewDenormalizer(&Denormalization{
+				Model:        &noteModel{},
+				Field:        "Title",
+				Relationship: "Post",
+				Source:       &postModel{},
+				SourceField:  "Published",
+			})
+		})
+	})
+}
+
+func TestDenormalizerCallbacks(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		denormalizer := NewDenormalizer(&Denormalization{
+			Model:        &noteModel{},
+			Field:        "Title",
+			Relationship: "Post",
+			Source:       &postModel{},
+			SourceField:  "Title",
+		})
+
+		tester.Assign("", &Controller{
+			Model: &postModel{},
+			Notifiers: L{
+				denormalizer.Propagator(nil),
+			},
+		}, &Controller{
+			Model: &commentModel{},
+		}, &Controller{
+			Model: &selectionModel{},
+		}, &Controller{
+			Model: &noteModel{},
+			Modifiers: L{
+				denormalizer.Modifier(),
+			},
+		})
+
+		post1 := tester.Insert(&postModel{Title: "Post 1"}).ID()
+		post2 := tester.Insert(&postModel{Title: "Post 2"}).ID()
+
+		var note string
+		tester.Request("POST", "notes", `{
+			"data": {
+				"type": "notes",
+				"relationships": {
+					"post": {
+						"data": { "type": "posts", "id": "`+post1+`" }
+					}
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusCreated, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			note = tester.FindLast(&noteModel{}).ID()
+		})
+		assert.Equal(t, "Post 1", tester.Fetch(&noteModel{}, note).(*noteModel).Title)
+
+		tester.Request("PATCH", "notes/"+note+"/relationships/post", `{
+			"data": { "type": "posts", "id": "`+post2+`" }
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+		assert.Equal(t, "Post 2", tester.Fetch(&noteModel{}, note).(*noteModel).Title)
+
+		tester.Request("PATCH", "posts/"+post2, `{
+			"data": {
+				"type": "posts",
+				"id": "`+post2+`",
+				"attributes": {
+					"title": "Changed"
+				}
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+		})
+		assert.Equal(t, "Changed", tester.Fetch(&noteModel{}, note).(*noteModel).Title)
+	})
+}
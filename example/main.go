@@ -32,7 +32,7 @@ func main() {
 	xo.Debug(xo.DebugConfig{})
 
 	// visualize models
-	err := coal.Visualize("Example", "models.pdf", models.All()...)
+	err := os.WriteFile("models.svg", []byte(coal.VisualizeSVG("Example", models.All()...)), 0644)
 	if err != nil {
 		panic(err)
 	}
@@ -199,6 +199,14 @@ func createHandler(store *coal.Store) http.Handler {
 		Action: watcher.Action(),
 	})
 
+	// add schema action
+	g.Handle("schema", &fire.GroupAction{
+		Authorizers: fire.L{
+			flame.Callback(true),
+		},
+		Action: g.SchemaAction(),
+	})
+
 	// add upload action
 	g.Handle("upload", &fire.GroupAction{
 		Authorizers: fire.L{
@@ -0,0 +1,72 @@
+package glut
+
+import (
+	"context"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+// Checkpoint is a value that stores the resume token of a named stream.
+type Checkpoint struct {
+	Base `bson:"-" glut:"checkpoint,0"`
+
+	// The name of the stream.
+	Name string `bson:"name"`
+
+	// The resume token of the stream.
+	Token []byte `bson:"token"`
+}
+
+// Validate implements the Value interface.
+func (c *Checkpoint) Validate() error {
+	return stick.Validate(c, func(v *stick.Validator) {
+		v.Value("Name", false, stick.IsNotZero)
+	})
+}
+
+// GetExtension implements the ExtendedValue interface.
+func (c *Checkpoint) GetExtension() string {
+	return "/" + c.Name
+}
+
+// Checkpointer implements the coal.Checkpointer interface by storing the
+// resume tokens of named streams as values. It should not be used for streams
+// on the values collection itself.
+type Checkpointer struct {
+	store *coal.Store
+}
+
+// NewCheckpointer creates and returns a new checkpointer.
+func NewCheckpointer(store *coal.Store) *Checkpointer {
+	return &Checkpointer{
+		store: store,
+	}
+}
+
+// Load implements the coal.Checkpointer interface.
+func (c *Checkpointer) Load(ctx context.Context, name string) ([]byte, error) {
+	// get checkpoint
+	checkpoint := &Checkpoint{Name: name}
+	found, err := Get(ctx, c.store, checkpoint)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return checkpoint.Token, nil
+}
+
+// Save implements the coal.Checkpointer interface.
+func (c *Checkpointer) Save(ctx context.Context, name string, token []byte) error {
+	// delete checkpoint if token is missing
+	checkpoint := &Checkpoint{Name: name, Token: token}
+	if token == nil {
+		_, err := Delete(ctx, c.store, checkpoint)
+		return err
+	}
+
+	// set checkpoint
+	_, err := Set(ctx, c.store, checkpoint)
+
+	return err
+}
@@ -0,0 +1,37 @@
+package glut
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/256dpi/fire/coal"
+)
+
+func TestCheckpointer(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *coal.Tester) {
+		checkpointer := NewCheckpointer(tester.Store)
+
+		token, err := checkpointer.Load(nil, "foo")
+		assert.NoError(t, err)
+		assert.Nil(t, token)
+
+		err = checkpointer.Save(nil, "foo", []byte("bar"))
+		assert.NoError(t, err)
+
+		model := tester.FindLast(&Model{}).(*Model)
+		assert.Equal(t, "checkpoint/foo", model.Key)
+
+		token, err = checkpointer.Load(nil, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("bar"), token)
+
+		err = checkpointer.Save(nil, "foo", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, tester.Count(&Model{}))
+
+		token, err = checkpointer.Load(nil, "foo")
+		assert.NoError(t, err)
+		assert.Nil(t, token)
+	})
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/256dpi/xo"
 
 	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/smoke"
 	"github.com/256dpi/fire/stick"
 )
 
@@ -32,8 +33,10 @@ type GroupAction struct {
 // A Group manages access to multiple controllers and their interconnections.
 type Group struct {
 	reporter    func(error)
+	recorder    smoke.Recorder
 	controllers map[string]*Controller
 	actions     map[string]*GroupAction
+	renderers   map[string]Renderer
 }
 
 // NewGroup creates and returns a new group.
@@ -42,6 +45,7 @@ func NewGroup(reporter func(error)) *Group {
 		reporter:    reporter,
 		controllers: make(map[string]*Controller),
 		actions:     make(map[string]*GroupAction),
+		renderers:   make(map[string]Renderer),
 	}
 }
 
@@ -64,6 +68,28 @@ func (g *Group) Add(controllers ...*Controller) {
 	}
 }
 
+// Instrument will set the recorder that is used to record request and callback
+// metrics. It must be called before the group is used to serve requests.
+func (g *Group) Instrument(recorder smoke.Recorder) {
+	g.recorder = recorder
+}
+
+// Render will register a renderer for the specified media type. The renderer
+// is used for List and Find operations if the media type is listed in the
+// request "Accept" header. The accepted media types are considered by their
+// quality value and order. The JSON:API media type is always available and
+// selects the default JSON:API rendering. Request documents and errors are
+// always handled using JSON:API.
+func (g *Group) Render(mediaType string, renderer Renderer) {
+	// check existence
+	if g.renderers[mediaType] != nil {
+		panic(fmt.Sprintf(`fire: renderer for media type "%s" already exists`, mediaType))
+	}
+
+	// add renderer
+	g.renderers[mediaType] = renderer
+}
+
 // Handle allows to add an action as a group action. Group actions will only be
 // run when no controller matches the request.
 func (g *Group) Handle(name string, a *GroupAction) {
@@ -103,6 +129,29 @@ func (g *Group) Endpoint(prefix string) http.Handler {
 		defer tracer.End()
 		r = r.WithContext(tc)
 
+		// prepare resource and context
+		var resource string
+		var ctx *Context
+
+		// record request metrics
+		if g.recorder != nil {
+			sw := &statusWriter{ResponseWriter: w}
+			w = sw
+			start := time.Now()
+			defer func() {
+				// determine operation
+				var operation string
+				if ctx != nil && ctx.Controller != nil {
+					operation = ctx.Operation.String()
+				} else if resource != "" {
+					operation = "GroupAction"
+				}
+
+				// record request
+				g.recordRequest(resource, operation, sw.Status(), time.Since(start))
+			}()
+		}
+
 		// recover any panic
 		defer xo.Recover(func(err error) {
 			// record error
@@ -152,7 +201,7 @@ func (g *Group) Endpoint(prefix string) http.Handler {
 		s := strings.Split(path, "/")
 
 		// prepare context
-		ctx := &Context{
+		ctx = &Context{
 			Context:        r.Context(),
 			Data:           stick.Map{},
 			HTTPRequest:    r,
@@ -164,7 +213,8 @@ func (g *Group) Endpoint(prefix string) http.Handler {
 		// get controller
 		controller, ok := g.controllers[s[0]]
 		if ok {
-			// set controller
+			// set resource and controller
+			resource = s[0]
 			ctx.Controller = controller
 
 			// handle request
@@ -178,6 +228,9 @@ func (g *Group) Endpoint(prefix string) http.Handler {
 		if ok {
 			// check if action is allowed
 			if stick.Contains(action.Action.Methods, r.Method) {
+				// set resource
+				resource = s[0]
+
 				// run authorizers and handle errors
 				for _, cb := range action.Authorizers {
 					// check if callback should be run
@@ -7,6 +7,8 @@ import (
 
 	"github.com/256dpi/xo"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/256dpi/fire/smoke"
 )
 
 func TestGroupAdd(t *testing.T) {
@@ -167,3 +169,47 @@ func TestGroupAction(t *testing.T) {
 		})
 	})
 }
+
+func TestGroupInstrument(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		collector := smoke.NewCollector()
+
+		group := NewGroup(xo.Panic)
+		group.Instrument(collector)
+
+		group.Add(&Controller{
+			Model: &fooModel{},
+			Store: tester.Store,
+			Authorizers: L{
+				C("Allow", Authorizer, All(), func(*Context) error {
+					return nil
+				}),
+			},
+		}, &Controller{
+			Model: &barModel{},
+			Store: tester.Store,
+		})
+
+		tester.Handler = group.Endpoint("")
+
+		tester.Request("GET", "foos", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode)
+		})
+
+		assert.Equal(t, 1.0, collector.Value(RequestsMetric, smoke.Labels{
+			"resource":  "foos",
+			"operation": "List",
+			"status":    "200",
+		}))
+		assert.Equal(t, 1.0, collector.Value(RequestDurationMetric, smoke.Labels{
+			"resource":  "foos",
+			"operation": "List",
+		}))
+		assert.Equal(t, 1.0, collector.Value(CallbackDurationMetric, smoke.Labels{
+			"resource":  "foos",
+			"operation": "List",
+			"stage":     "Authorizer",
+			"callback":  "Allow",
+		}))
+	})
+}
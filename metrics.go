@@ -0,0 +1,140 @@
+package fire
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/256dpi/xo"
+
+	"github.com/256dpi/fire/smoke"
+)
+
+// The metrics recorded by a group.
+const (
+	// RequestsMetric counts the handled requests by resource, operation and
+	// response status.
+	RequestsMetric = "fire_requests_total"
+
+	// RequestDurationMetric observes the duration of handled requests in
+	// seconds by resource and operation.
+	RequestDurationMetric = "fire_request_duration_seconds"
+
+	// CallbackDurationMetric observes the duration of controller callbacks in
+	// seconds by resource, operation, stage and callback name.
+	CallbackDurationMetric = "fire_callback_duration_seconds"
+)
+
+// String returns the name of the stage.
+func (s Stage) String() string {
+	switch s {
+	case Authorizer:
+		return "Authorizer"
+	case Verifier:
+		return "Verifier"
+	case Modifier:
+		return "Modifier"
+	case Validator:
+		return "Validator"
+	case Decorator:
+		return "Decorator"
+	case Notifier:
+		return "Notifier"
+	case Mutator:
+		return "Mutator"
+	}
+
+	return ""
+}
+
+func (g *Group) recordRequest(resource, operation string, status int, duration time.Duration) {
+	// prepare labels
+	labels := smoke.Labels{
+		"resource":  resource,
+		"operation": operation,
+	}
+
+	// record duration
+	g.recorder.Observe(RequestDurationMetric, labels, duration.Seconds())
+
+	// count request
+	labels["status"] = strconv.Itoa(status)
+	g.recorder.Count(RequestsMetric, labels, 1)
+}
+
+func (g *Group) recordCallback(ctx *Context, cb *Callback, duration time.Duration) {
+	// check recorder
+	if g == nil || g.recorder == nil {
+		return
+	}
+
+	// get resource
+	var resource string
+	if ctx.Controller != nil {
+		resource = ctx.Controller.meta.PluralName
+	}
+
+	// record duration
+	g.recorder.Observe(CallbackDurationMetric, smoke.Labels{
+		"resource":  resource,
+		"operation": ctx.Operation.String(),
+		"stage":     ctx.Stage.String(),
+		"callback":  cb.Name,
+	}, duration.Seconds())
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	// capture first status
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(bytes []byte) (int, error) {
+	// capture implicit status
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(bytes)
+}
+
+func (w *statusWriter) Status() int {
+	// net/http writes an implicit ok status
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+func (w *statusWriter) Flush() {
+	// flush if supported
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	// check support
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, xo.F("hijacking not supported")
+	}
+
+	// mark connection as switched
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return hijacker.Hijack()
+}
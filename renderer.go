@@ -0,0 +1,163 @@
+package fire
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/256dpi/xo"
+)
+
+// Renderer renders the response documents of List and Find operations using
+// a specific media type. Renderers are registered on a group and selected
+// using the request "Accept" header.
+type Renderer interface {
+	Render(w http.ResponseWriter, status int, doc *jsonapi.Document) error
+}
+
+// JSONAPIRenderer renders documents as JSON:API documents.
+type JSONAPIRenderer struct{}
+
+// Render implements the Renderer interface.
+func (r *JSONAPIRenderer) Render(w http.ResponseWriter, status int, doc *jsonapi.Document) error {
+	return jsonapi.WriteResponse(w, status, doc)
+}
+
+// PlainRenderer renders documents as plain objects or arrays of objects. The
+// objects contain the resource id, attributes and the ids of the referenced
+// resources.
+type PlainRenderer struct {
+	// The media type set as the response content type.
+	MediaType string
+
+	// The function used to encode the plain objects.
+	Encode func(interface{}) ([]byte, error)
+}
+
+// JSONRenderer returns a plain renderer that encodes objects as JSON.
+func JSONRenderer() *PlainRenderer {
+	return &PlainRenderer{
+		MediaType: "application/json",
+		Encode:    json.Marshal,
+	}
+}
+
+// Render implements the Renderer interface.
+func (r *PlainRenderer) Render(w http.ResponseWriter, status int, doc *jsonapi.Document) error {
+	// convert data
+	var value interface{}
+	if doc.Data != nil && doc.Data.Many != nil {
+		list := make([]map[string]interface{}, 0, len(doc.Data.Many))
+		for _, res := range doc.Data.Many {
+			list = append(list, plainResource(res))
+		}
+		value = list
+	} else if doc.Data != nil && doc.Data.One != nil {
+		value = plainResource(doc.Data.One)
+	}
+
+	// encode value
+	bytes, err := r.Encode(value)
+	if err != nil {
+		return xo.W(err)
+	}
+
+	// write response
+	w.Header().Set("Content-Type", r.MediaType)
+	w.WriteHeader(status)
+	_, err = w.Write(bytes)
+	if err != nil {
+		return xo.W(err)
+	}
+
+	return nil
+}
+
+func plainResource(res *jsonapi.Resource) map[string]interface{} {
+	// prepare object
+	obj := make(map[string]interface{}, len(res.Attributes)+len(res.Relationships)+1)
+	obj["id"] = res.ID
+
+	// add attributes
+	for key, value := range res.Attributes {
+		obj[key] = value
+	}
+
+	// add references
+	for name, rel := range res.Relationships {
+		switch {
+		case rel.Data == nil:
+			continue
+		case rel.Data.Many != nil:
+			ids := make([]string, 0, len(rel.Data.Many))
+			for _, ref := range rel.Data.Many {
+				ids = append(ids, ref.ID)
+			}
+			obj[name] = ids
+		case rel.Data.One != nil:
+			obj[name] = rel.Data.One.ID
+		default:
+			obj[name] = nil
+		}
+	}
+
+	return obj
+}
+
+func (g *Group) negotiate(r *http.Request) Renderer {
+	// check renderers
+	if len(g.renderers) == 0 {
+		return nil
+	}
+
+	// parse accepted media types
+	type accepted struct {
+		mediaType string
+		quality   float64
+	}
+	var list []accepted
+	for _, item := range strings.Split(r.Header.Get("Accept"), ",") {
+		// get media type and quality
+		params := strings.Split(item, ";")
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+
+		// skip rejected media types
+		if quality <= 0 {
+			continue
+		}
+
+		list = append(list, accepted{
+			mediaType: strings.TrimSpace(params[0]),
+			quality:   quality,
+		})
+	}
+
+	// order by quality and keep listed order otherwise
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].quality > list[j].quality
+	})
+
+	// find first accepted media type with a renderer
+	for _, item := range list {
+		if renderer, ok := g.renderers[item.mediaType]; ok {
+			return renderer
+		} else if item.mediaType == jsonapi.MediaType {
+			return &JSONAPIRenderer{}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package fire
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/256dpi/jsonapi/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderer(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := tester.Assign("", &Controller{
+			Model: &fooModel{},
+		}, &Controller{
+			Model: &barModel{},
+		})
+
+		group.Render("application/json", JSONRenderer())
+
+		assert.PanicsWithValue(t, `fire: renderer for media type "application/json" already exists`, func() {
+			group.Render("application/json", &JSONAPIRenderer{})
+		})
+
+		foo := tester.Insert(&fooModel{}).ID()
+		bar := tester.Insert(&barModel{Foo: foo}).ID()
+
+		tester.Header["Accept"] = "text/html, application/json;q=0.9"
+
+		// list as plain json
+		tester.Request("GET", "bars", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "application/json", r.Header().Get("Content-Type"))
+			assert.JSONEq(t, `[
+				{
+					"id": "`+bar+`",
+					"foo": "`+foo+`"
+				}
+			]`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// find as plain json
+		tester.Request("GET", "bars/"+bar, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.JSONEq(t, `{
+				"id": "`+bar+`",
+				"foo": "`+foo+`"
+			}`, r.Body.String(), tester.DebugRequest(rq, r))
+		})
+
+		// errors remain json api
+		tester.Request("GET", "bars/"+foo, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusNotFound, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, jsonapi.MediaType, r.Header().Get("Content-Type"))
+		})
+
+		// writes remain json api
+		tester.Request("PATCH", "bars/"+bar, `{
+			"data": {
+				"type": "bars",
+				"id": "`+bar+`"
+			}
+		}`, func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, jsonapi.MediaType, r.Header().Get("Content-Type"))
+		})
+
+		// prefer json api if listed first
+		tester.Header["Accept"] = jsonapi.MediaType + ", application/json"
+		tester.Request("GET", "bars/"+bar, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, jsonapi.MediaType, r.Header().Get("Content-Type"))
+		})
+
+		// respect quality values
+		tester.Header["Accept"] = jsonapi.MediaType + ";q=0.5, application/json"
+		tester.Request("GET", "bars/"+bar, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, "application/json", r.Header().Get("Content-Type"))
+		})
+
+		// skip rejected media types
+		tester.Header["Accept"] = "application/json;q=0, " + jsonapi.MediaType + ";q=0.1"
+		tester.Request("GET", "bars/"+bar, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, jsonapi.MediaType, r.Header().Get("Content-Type"))
+		})
+
+		// default to json api
+		tester.Header["Accept"] = jsonapi.MediaType
+		tester.Request("GET", "bars/"+bar, "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode, tester.DebugRequest(rq, r))
+			assert.Equal(t, jsonapi.MediaType, r.Header().Get("Content-Type"))
+		})
+	})
+}
@@ -0,0 +1,279 @@
+package fire
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/256dpi/fire/coal"
+	"github.com/256dpi/fire/stick"
+)
+
+// Schema is a machine-readable description of the resources served by a
+// group. It can be used to generate client side models.
+type Schema struct {
+	Resources []SchemaResource `json:"resources"`
+}
+
+// SchemaResource describes a single resource served by a controller.
+type SchemaResource struct {
+	// The JSON:API type e.g. "car-wheels".
+	Type string `json:"type"`
+
+	// The Go struct type name e.g. "CarWheel".
+	Model string `json:"model"`
+
+	// The resource fields.
+	Attributes    []SchemaAttribute    `json:"attributes"`
+	Relationships []SchemaRelationship `json:"relationships"`
+	Properties    []SchemaAttribute    `json:"properties"`
+
+	// The filterable and sortable JSON keys or relationship names.
+	Filters []string `json:"filters"`
+	Sorters []string `json:"sorters"`
+
+	// The custom actions.
+	CollectionActions []SchemaAction `json:"collectionActions"`
+	ResourceActions   []SchemaAction `json:"resourceActions"`
+}
+
+// SchemaAttribute describes an attribute or property of a resource.
+type SchemaAttribute struct {
+	// The JSON object key e.g. "tire-size".
+	Name string `json:"name"`
+
+	// The Go field or method name e.g. "TireSize".
+	Field string `json:"field"`
+
+	// The Go kind and type of the value e.g. "int" and "int". Pointers are
+	// dereferenced and reported as optional.
+	Kind string `json:"kind"`
+	Type string `json:"type"`
+
+	// The Go kind of the elements of slices and arrays e.g. "string".
+	// Pointers are dereferenced.
+	Elem string `json:"elem,omitempty"`
+
+	// Whether the value is optional.
+	Optional bool `json:"optional"`
+}
+
+// SchemaRelationship describes a relationship of a resource.
+type SchemaRelationship struct {
+	// The relationship name e.g. "car".
+	Name string `json:"name"`
+
+	// The Go field name e.g. "Car".
+	Field string `json:"field"`
+
+	// The related JSON:API type e.g. "cars".
+	Type string `json:"type"`
+
+	// The relationship kind: "to-one", "to-many", "has-one" or "has-many".
+	Kind string `json:"kind"`
+
+	// Whether a to-one relationship is optional.
+	Optional bool `json:"optional"`
+
+	// The name of the inverse relationship on the related resource, if known.
+	Inverse string `json:"inverse,omitempty"`
+}
+
+// SchemaAction describes a collection or resource action.
+type SchemaAction struct {
+	Name    string   `json:"name"`
+	Methods []string `json:"methods"`
+}
+
+// Schema will return the schema of all controllers added to the group. The
+// resources are sorted by type to produce a stable output.
+func (g *Group) Schema() *Schema {
+	// sort names
+	names := make([]string, 0, len(g.controllers))
+	for name := range g.controllers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// prepare schema
+	schema := &Schema{
+		Resources: make([]SchemaResource, 0, len(names)),
+	}
+
+	// add resources
+	for _, name := range names {
+		schema.Resources = append(schema.Resources, g.describe(g.controllers[name]))
+	}
+
+	return schema
+}
+
+// SchemaAction returns an action that responds with the schema of the group.
+// It should be registered in the group under the "schema" name.
+func (g *Group) SchemaAction() *Action {
+	return A("fire/Group.SchemaAction", []string{"GET"}, 0, func(ctx *Context) error {
+		// set content type
+		ctx.ResponseWriter.Header().Set("Content-Type", "application/json")
+
+		return ctx.Respond(g.Schema())
+	})
+}
+
+func (g *Group) describe(controller *Controller) SchemaResource {
+	// get meta
+	meta := controller.meta
+
+	// prepare resource
+	resource := SchemaResource{
+		Type:              meta.PluralName,
+		Model:             meta.Type.Name(),
+		Attributes:        []SchemaAttribute{},
+		Relationships:     []SchemaRelationship{},
+		Properties:        []SchemaAttribute{},
+		Filters:           []string{},
+		Sorters:           []string{},
+		CollectionActions: describeActions(controller.CollectionActions),
+		ResourceActions:   describeActions(controller.ResourceActions),
+	}
+
+	// add fields
+	for _, field := range meta.OrderedFields {
+		// handle attributes
+		if field.JSONKey != "" {
+			kind, typ, elem, _ := describeType(field.Type)
+			resource.Attributes = append(resource.Attributes, SchemaAttribute{
+				Name:     field.JSONKey,
+				Field:    field.Name,
+				Kind:     kind,
+				Type:     typ,
+				Elem:     elem,
+				Optional: field.Optional,
+			})
+		}
+
+		// handle relationships
+		if field.RelName != "" {
+			resource.Relationships = append(resource.Relationships, SchemaRelationship{
+				Name:     field.RelName,
+				Field:    field.Name,
+				Type:     field.RelType,
+				Kind:     relationshipKind(field),
+				Optional: field.Optional,
+				Inverse:  g.inverse(meta, field),
+			})
+		}
+
+		// get key
+		key := field.JSONKey
+		if key == "" {
+			key = field.RelName
+		}
+
+		// add filters and sorters
+		if stick.Contains(controller.Filters, field.Name) {
+			resource.Filters = append(resource.Filters, key)
+		}
+		if stick.Contains(controller.Sorters, field.Name) {
+			resource.Sorters = append(resource.Sorters, key)
+		}
+	}
+
+	// sort property names
+	methods := make([]string, 0, len(controller.Properties))
+	for method := range controller.Properties {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	// add properties
+	ptrType := reflect.PtrTo(meta.Type)
+	for _, method := range methods {
+		fn, _ := ptrType.MethodByName(method)
+		kind, typ, elem, optional := describeType(fn.Type.Out(0))
+		resource.Properties = append(resource.Properties, SchemaAttribute{
+			Name:     controller.Properties[method],
+			Field:    method,
+			Kind:     kind,
+			Type:     typ,
+			Elem:     elem,
+			Optional: optional,
+		})
+	}
+
+	return resource
+}
+
+func (g *Group) inverse(meta *coal.Meta, field *coal.Field) string {
+	// has-one and has-many relationships name their inverse
+	if field.HasOne || field.HasMany {
+		return field.RelInverse
+	}
+
+	// find a related has-one or has-many relationship
+	related := g.controllers[field.RelType]
+	if related == nil {
+		return ""
+	}
+	for _, relField := range related.meta.OrderedFields {
+		if (relField.HasOne || relField.HasMany) && relField.RelType == meta.PluralName && relField.RelInverse == field.RelName {
+			return relField.RelName
+		}
+	}
+
+	return ""
+}
+
+func describeActions(actions map[string]*Action) []SchemaAction {
+	// sort names
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// collect actions
+	list := make([]SchemaAction, 0, len(names))
+	for _, name := range names {
+		list = append(list, SchemaAction{
+			Name:    name,
+			Methods: actions[name].Methods,
+		})
+	}
+
+	return list
+}
+
+func describeType(typ reflect.Type) (string, string, string, bool) {
+	// get name
+	name := typ.String()
+
+	// dereference pointers
+	optional := typ.Kind() == reflect.Ptr
+	if optional {
+		typ = typ.Elem()
+	}
+
+	// get element kind
+	var elem string
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		elemType := typ.Elem()
+		if elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		elem = elemType.Kind().String()
+	}
+
+	return typ.Kind().String(), name, elem, optional
+}
+
+func relationshipKind(field *coal.Field) string {
+	switch {
+	case field.ToOne:
+		return "to-one"
+	case field.ToMany:
+		return "to-many"
+	case field.HasOne:
+		return "has-one"
+	default:
+		return "has-many"
+	}
+}
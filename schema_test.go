@@ -0,0 +1,131 @@
+package fire
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/256dpi/xo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupSchema(t *testing.T) {
+	group := NewGroup(xo.Panic)
+
+	group.Add(&Controller{
+		Model:   &postModel{},
+		Filters: []string{"Title", "Published"},
+		Sorters: []string{"Title"},
+		Properties: map[string]string{
+			"Virtual": "virtual",
+		},
+		CollectionActions: M{
+			"clear": A("clear", []string{"DELETE"}, 0, func(*Context) error {
+				return nil
+			}),
+		},
+	}, &Controller{
+		Model:   &commentModel{},
+		Filters: []string{"Post"},
+	}, &Controller{
+		Model: &selectionModel{},
+	}, &Controller{
+		Model: &noteModel{},
+	})
+
+	schema := group.Schema()
+	assert.Len(t, schema.Resources, 4)
+	assert.Equal(t, []string{"comments", "notes", "posts", "selections"}, []string{
+		schema.Resources[0].Type,
+		schema.Resources[1].Type,
+		schema.Resources[2].Type,
+		schema.Resources[3].Type,
+	})
+
+	assert.Equal(t, SchemaResource{
+		Type:  "posts",
+		Model: "postModel",
+		Attributes: []SchemaAttribute{
+			{Name: "title", Field: "Title", Kind: "string", Type: "string"},
+			{Name: "published", Field: "Published", Kind: "bool", Type: "bool"},
+			{Name: "text-body", Field: "TextBody", Kind: "string", Type: "string"},
+		},
+		Relationships: []SchemaRelationship{
+			{Name: "comments", Field: "Comments", Type: "comments", Kind: "has-many", Inverse: "post"},
+			{Name: "selections", Field: "Selections", Type: "selections", Kind: "has-many", Inverse: "posts"},
+			{Name: "note", Field: "Note", Type: "notes", Kind: "has-one", Inverse: "post"},
+		},
+		Properties: []SchemaAttribute{
+			{Name: "virtual", Field: "Virtual", Kind: "int64", Type: "int64"},
+		},
+		Filters: []string{"title", "published"},
+		Sorters: []string{"title"},
+		CollectionActions: []SchemaAction{
+			{Name: "clear", Methods: []string{"DELETE"}},
+		},
+		ResourceActions: []SchemaAction{},
+	}, schema.Resources[2])
+
+	assert.Contains(t, schema.Resources[0].Relationships, SchemaRelationship{
+		Name: "post", Field: "Post", Type: "posts", Kind: "to-one", Inverse: "comments",
+	})
+	assert.Equal(t, []string{"post"}, schema.Resources[0].Filters)
+}
+
+func TestGroupSchemaAction(t *testing.T) {
+	withTester(t, func(t *testing.T, tester *Tester) {
+		group := NewGroup(xo.Panic)
+
+		group.Add(&Controller{
+			Model: &fooModel{},
+			Store: tester.Store,
+		}, &Controller{
+			Model: &barModel{},
+			Store: tester.Store,
+		})
+
+		group.Handle("schema", &GroupAction{
+			Action: group.SchemaAction(),
+		})
+
+		tester.Handler = group.Endpoint("")
+
+		tester.Request("GET", "schema", "", func(r *httptest.ResponseRecorder, rq *http.Request) {
+			assert.Equal(t, http.StatusOK, r.Result().StatusCode)
+			assert.Equal(t, "application/json", r.Header().Get("Content-Type"))
+
+			var schema Schema
+			err := json.Unmarshal(r.Body.Bytes(), &schema)
+			assert.NoError(t, err)
+			assert.Equal(t, *group.Schema(), schema)
+		})
+	})
+}
+
+func TestDescribeType(t *testing.T) {
+	type state string
+
+	for _, item := range []struct {
+		typ      reflect.Type
+		kind     string
+		name     string
+		elem     string
+		optional bool
+	}{
+		{typ: reflect.TypeOf(""), kind: "string", name: "string"},
+		{typ: reflect.TypeOf(new(int)), kind: "int", name: "*int", optional: true},
+		{typ: reflect.TypeOf(time.Time{}), kind: "struct", name: "time.Time"},
+		{typ: reflect.TypeOf([]byte{}), kind: "slice", name: "[]uint8", elem: "uint8"},
+		{typ: reflect.TypeOf([]state{}), kind: "slice", name: "[]fire.state", elem: "string"},
+		{typ: reflect.TypeOf([]*float64{}), kind: "slice", name: "[]*float64", elem: "float64"},
+	} {
+		kind, name, elem, optional := describeType(item.typ)
+		assert.Equal(t, item.kind, kind)
+		assert.Equal(t, item.name, name)
+		assert.Equal(t, item.elem, elem)
+		assert.Equal(t, item.optional, optional)
+	}
+}
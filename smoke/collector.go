@@ -0,0 +1,331 @@
+package smoke
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default histogram buckets in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type kind string
+
+const (
+	counter   kind = "counter"
+	gauge     kind = "gauge"
+	histogram kind = "histogram"
+)
+
+type series struct {
+	labels Labels
+	value  float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type family struct {
+	kind   kind
+	help   string
+	series map[string]*series
+}
+
+// Collector is an in-memory Recorder that aggregates all measurements and
+// exposes them using the Prometheus text exposition format.
+type Collector struct {
+	buckets  []float64
+	families map[string]*family
+	mutex    sync.Mutex
+}
+
+// NewCollector creates and returns a new collector. If no buckets are provided
+// the DefaultBuckets are used for histograms.
+func NewCollector(buckets ...float64) *Collector {
+	// set default buckets
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	// copy and sort buckets
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	return &Collector{
+		buckets:  buckets,
+		families: map[string]*family{},
+	}
+}
+
+// Describe will set the help text of the specified metric.
+func (c *Collector) Describe(name, help string) {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// get family
+	f, ok := c.families[name]
+	if !ok {
+		f = &family{
+			series: map[string]*series{},
+		}
+		c.families[name] = f
+	}
+
+	// set help
+	f.help = help
+}
+
+// Count implements the Recorder interface.
+func (c *Collector) Count(name string, labels Labels, delta float64) {
+	// check delta
+	if delta < 0 {
+		panic(fmt.Sprintf(`smoke: negative delta for counter "%s"`, name))
+	}
+
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// add delta
+	c.get(name, counter, labels).value += delta
+}
+
+// Gauge implements the Recorder interface.
+func (c *Collector) Gauge(name string, labels Labels, delta float64) {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// add delta
+	c.get(name, gauge, labels).value += delta
+}
+
+// Observe implements the Recorder interface.
+func (c *Collector) Observe(name string, labels Labels, value float64) {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// get series
+	s := c.get(name, histogram, labels)
+
+	// increment buckets
+	for i, bound := range c.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+
+	// update count and sum
+	s.count++
+	s.sum += value
+}
+
+// Value returns the current value of a counter or gauge or the number of
+// observations of a histogram.
+func (c *Collector) Value(name string, labels Labels) float64 {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// get family
+	f, ok := c.families[name]
+	if !ok {
+		return 0
+	}
+
+	// get series
+	s, ok := f.series[encodeLabels(labels)]
+	if !ok {
+		return 0
+	}
+
+	// handle histograms
+	if f.kind == histogram {
+		return float64(s.count)
+	}
+
+	return s.value
+}
+
+// Reset will remove all collected metrics.
+func (c *Collector) Reset() {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// reset families
+	c.families = map[string]*family{}
+}
+
+// Expose returns all collected metrics in the Prometheus text exposition
+// format. Metrics and series are sorted to produce a stable output.
+func (c *Collector) Expose() []byte {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// sort names
+	names := make([]string, 0, len(c.families))
+	for name := range c.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// prepare buffer
+	var buf bytes.Buffer
+
+	// write families
+	for _, name := range names {
+		// get family
+		f := c.families[name]
+
+		// skip descriptions without data
+		if f.kind == "" {
+			continue
+		}
+
+		// write header
+		if f.help != "" {
+			buf.WriteString("# HELP " + name + " " + escapeHelp(f.help) + "\n")
+		}
+		buf.WriteString("# TYPE " + name + " " + string(f.kind) + "\n")
+
+		// sort series
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		// write series
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != histogram {
+				buf.WriteString(name + key + " " + formatFloat(s.value) + "\n")
+				continue
+			}
+
+			// write buckets
+			for i, bound := range c.buckets {
+				buf.WriteString(name + "_bucket" + encodeLabels(s.labels, "le", formatFloat(bound)) + " " + strconv.FormatUint(s.counts[i], 10) + "\n")
+			}
+			buf.WriteString(name + "_bucket" + encodeLabels(s.labels, "le", "+Inf") + " " + strconv.FormatUint(s.count, 10) + "\n")
+
+			// write sum and count
+			buf.WriteString(name + "_sum" + key + " " + formatFloat(s.sum) + "\n")
+			buf.WriteString(name + "_count" + key + " " + strconv.FormatUint(s.count, 10) + "\n")
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// ServeHTTP implements the http.Handler interface and serves the collected
+// metrics to a Prometheus compatible scraper.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// check method
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// write metrics
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(c.Expose())
+	}
+}
+
+func (c *Collector) get(name string, kind kind, labels Labels) *series {
+	// get family
+	f, ok := c.families[name]
+	if !ok {
+		f = &family{
+			series: map[string]*series{},
+		}
+		c.families[name] = f
+	}
+
+	// check kind
+	if f.kind == "" {
+		f.kind = kind
+	} else if f.kind != kind {
+		panic(fmt.Sprintf(`smoke: metric "%s" is a %s not a %s`, name, f.kind, kind))
+	}
+
+	// get series
+	key := encodeLabels(labels)
+	s, ok := f.series[key]
+	if !ok {
+		// copy labels
+		copied := make(Labels, len(labels))
+		for k, v := range labels {
+			copied[k] = v
+		}
+
+		// create series
+		s = &series{
+			labels: copied,
+		}
+		if kind == histogram {
+			s.counts = make([]uint64, len(c.buckets))
+		}
+		f.series[key] = s
+	}
+
+	return s
+}
+
+func encodeLabels(labels Labels, extra ...string) string {
+	// collect pairs
+	pairs := make([]string, 0, len(labels)+1)
+	for key, value := range labels {
+		pairs = append(pairs, key+`="`+escapeValue(value)+`"`)
+	}
+	sort.Strings(pairs)
+
+	// add extra pair
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escapeValue(extra[1])+`"`)
+	}
+
+	// check length
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var valueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeValue(str string) string {
+	return valueEscaper.Replace(str)
+}
+
+func escapeHelp(str string) string {
+	return helpEscaper.Replace(str)
+}
+
+func formatFloat(f float64) string {
+	// handle special values
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
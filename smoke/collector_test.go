@@ -0,0 +1,82 @@
+package smoke
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector(0.1, 1)
+	c.Describe("requests_total", "The total requests.")
+
+	c.Count("requests_total", Labels{"code": "200"}, 1)
+	c.Count("requests_total", Labels{"code": "200"}, 2)
+	c.Count("requests_total", Labels{"code": "500"}, 1)
+	c.Gauge("connections", nil, 2)
+	c.Gauge("connections", nil, -1)
+	c.Observe("duration_seconds", Labels{"op": "find"}, 0.05)
+	c.Observe("duration_seconds", Labels{"op": "find"}, 0.5)
+	c.Observe("duration_seconds", Labels{"op": "find"}, 5)
+
+	assert.Equal(t, 3.0, c.Value("requests_total", Labels{"code": "200"}))
+	assert.Equal(t, 1.0, c.Value("connections", nil))
+	assert.Equal(t, 3.0, c.Value("duration_seconds", Labels{"op": "find"}))
+	assert.Equal(t, 0.0, c.Value("missing", nil))
+
+	assert.Equal(t, `# TYPE connections gauge
+connections 1
+# TYPE duration_seconds histogram
+duration_seconds_bucket{op="find",le="0.1"} 1
+duration_seconds_bucket{op="find",le="1"} 2
+duration_seconds_bucket{op="find",le="+Inf"} 3
+duration_seconds_sum{op="find"} 5.55
+duration_seconds_count{op="find"} 3
+# HELP requests_total The total requests.
+# TYPE requests_total counter
+requests_total{code="200"} 3
+requests_total{code="500"} 1
+`, string(c.Expose()))
+
+	assert.PanicsWithValue(t, `smoke: metric "connections" is a gauge not a counter`, func() {
+		c.Count("connections", nil, 1)
+	})
+
+	assert.PanicsWithValue(t, `smoke: negative delta for counter "requests_total"`, func() {
+		c.Count("requests_total", nil, -1)
+	})
+
+	c.Reset()
+	assert.Empty(t, c.Expose())
+}
+
+func TestCollectorEscaping(t *testing.T) {
+	c := NewCollector()
+	c.Count("foo", Labels{"bar": "a\"b\\c\nd"}, 1)
+	assert.Equal(t, "# TYPE foo counter\nfoo{bar=\"a\\\"b\\\\c\\nd\"} 1\n", string(c.Expose()))
+}
+
+func TestCollectorServeHTTP(t *testing.T) {
+	c := NewCollector()
+	c.Count("foo", nil, 1)
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "# TYPE foo counter\nfoo 1\n", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("POST", "/metrics", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestMeasure(t *testing.T) {
+	Measure(nil, "foo", nil)()
+
+	c := NewCollector()
+	Measure(c, "foo", Labels{"bar": "baz"})()
+	assert.Equal(t, 1.0, c.Value("foo", Labels{"bar": "baz"}))
+}
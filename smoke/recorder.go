@@ -0,0 +1,43 @@
+// Package smoke provides a minimal metrics abstraction that is used to
+// instrument the other packages of the framework.
+package smoke
+
+import "time"
+
+// Labels are the dimensions of a single metric series.
+type Labels map[string]string
+
+// Recorder is the interface implemented by metric backends. Instrumented
+// components only depend on this interface so that applications may forward
+// the measurements to any metrics system.
+type Recorder interface {
+	// Count adds the provided delta to the counter with the specified name
+	// and labels.
+	Count(name string, labels Labels, delta float64)
+
+	// Gauge adds the provided delta to the gauge with the specified name and
+	// labels. Negative deltas decrease the gauge.
+	Gauge(name string, labels Labels, delta float64)
+
+	// Observe records the provided value in the histogram with the specified
+	// name and labels.
+	Observe(name string, labels Labels, value float64)
+}
+
+// Measure returns a function that when called records the elapsed time in
+// seconds as an observation. It is a no-op if the recorder is nil.
+//
+//	defer smoke.Measure(recorder, "foo_duration_seconds", labels)()
+func Measure(recorder Recorder, name string, labels Labels) func() {
+	// check recorder
+	if recorder == nil {
+		return func() {}
+	}
+
+	// get time
+	start := time.Now()
+
+	return func() {
+		recorder.Observe(name, labels, time.Since(start).Seconds())
+	}
+}
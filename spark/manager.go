@@ -11,6 +11,7 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/256dpi/fire"
+	"github.com/256dpi/fire/smoke"
 )
 
 const (
@@ -133,6 +134,12 @@ func (m *manager) handle(ctx *fire.Context) error {
 	// ensure the connections gets closed
 	defer conn.Close()
 
+	// track connection if available
+	if m.watcher.recorder != nil {
+		m.watcher.recorder.Gauge(ConnectionsMetric, nil, 1)
+		defer m.watcher.recorder.Gauge(ConnectionsMetric, nil, -1)
+	}
+
 	// prepare queue
 	queue := make(chan *Event, 10)
 
@@ -297,6 +304,13 @@ func (m *manager) handle(ctx *fire.Context) error {
 			if err != nil {
 				return err
 			}
+
+			// count event if available
+			if m.watcher.recorder != nil {
+				m.watcher.recorder.Count(EventsMetric, smoke.Labels{
+					"stream": evt.Stream.Name(),
+				}, 1)
+			}
 		// handle pings
 		case <-pinger.C:
 			// set write deadline
@@ -4,11 +4,22 @@ import (
 	"fmt"
 
 	"github.com/256dpi/fire"
+	"github.com/256dpi/fire/smoke"
+)
+
+// The metrics recorded by a watcher.
+const (
+	// ConnectionsMetric gauges the currently open connections.
+	ConnectionsMetric = "spark_connections"
+
+	// EventsMetric counts the events sent to clients by stream.
+	EventsMetric = "spark_events_total"
 )
 
 // Watcher will watch multiple collections and serve watch requests by clients.
 type Watcher struct {
 	reporter func(error)
+	recorder smoke.Recorder
 	manager  *manager
 	streams  map[string]*Stream
 }
@@ -27,6 +38,12 @@ func NewWatcher(reporter func(error)) *Watcher {
 	return w
 }
 
+// Instrument will set the recorder that receives connection and event
+// metrics. It must be called before the watcher is used.
+func (w *Watcher) Instrument(recorder smoke.Recorder) {
+	w.recorder = recorder
+}
+
 // Add will add a stream to the watcher.
 func (w *Watcher) Add(stream *Stream) {
 	// check existence